@@ -0,0 +1,48 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// lastAccess records when each user was last read, keyed by user ID.
+// Each entry is an atomic so concurrent GETs never contend on a shared lock;
+// sync.Map is optimized for this mostly-read, disjoint-key pattern.
+var lastAccess sync.Map // map[string]*atomic.Int64 (unix nanoseconds)
+
+// recordAccess marks userID as read at t
+func recordAccess(userID string, t time.Time) {
+	v, _ := lastAccess.LoadOrStore(userID, new(atomic.Int64))
+	v.(*atomic.Int64).Store(t.UnixNano())
+}
+
+// lastAccessedAt returns when userID was last read, or nil if never
+func lastAccessedAt(userID string) *time.Time {
+	v, ok := lastAccess.Load(userID)
+	if !ok {
+		return nil
+	}
+	t := time.Unix(0, v.(*atomic.Int64).Load()).UTC()
+	return &t
+}
+
+// forgetAccess drops the access record for a deleted user
+func forgetAccess(userID string) {
+	lastAccess.Delete(userID)
+}
+
+// includeAccess reports whether the client asked for access timestamps (?include_access=true)
+func includeAccess(r *http.Request) bool {
+	include, _ := strconv.ParseBool(r.URL.Query().Get("include_access"))
+	return include
+}
+
+// moveAccess carries a user's access record over when their ID changes
+func moveAccess(oldID, newID string) {
+	if v, ok := lastAccess.LoadAndDelete(oldID); ok {
+		lastAccess.Store(newID, v)
+	}
+}
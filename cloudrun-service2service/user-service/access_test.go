@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLastAccessedAt(t *testing.T) {
+	useTestStore(t)
+	forgetAccess("user-003")
+	t.Cleanup(func() { forgetAccess("user-003") })
+
+	get := func(query string) UsersResponse {
+		t.Helper()
+		rec := serveHandler(userByIDHandler, http.MethodGet, "/users/user-003"+query, "")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, want 200", rec.Code)
+		}
+		var resp UsersResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	before := time.Now()
+	if resp := get(""); resp.User.LastAccessedAt != nil {
+		t.Error("last_accessed_at returned without ?include_access=true")
+	}
+	resp := get("?include_access=true")
+	if resp.User.LastAccessedAt == nil || resp.User.LastAccessedAt.Before(before) {
+		t.Fatalf("last_accessed_at = %v, want at least %s", resp.User.LastAccessedAt, before)
+	}
+
+	serveHandler(userByIDHandler, http.MethodDelete, "/users/user-003?hard=true", "")
+	if lastAccessedAt("user-003") != nil {
+		t.Error("access record kept after the user was removed")
+	}
+}
+
+func TestRecordAccessConcurrently(t *testing.T) {
+	t.Cleanup(func() { forgetAccess("user-race") })
+
+	var wg sync.WaitGroup
+	latest := time.Now().Add(time.Hour)
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			recordAccess("user-race", latest.Add(-time.Duration(i+1)*time.Second))
+			lastAccessedAt("user-race")
+		}(i)
+	}
+	wg.Wait()
+	recordAccess("user-race", latest)
+	if got := lastAccessedAt("user-race"); got == nil || !got.Equal(latest) {
+		t.Errorf("last access = %v, want %s", got, latest)
+	}
+}
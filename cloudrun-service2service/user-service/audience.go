@@ -0,0 +1,47 @@
+package main
+
+import (
+	"os"
+	"strings"
+)
+
+// Audience override configuration.
+// An OIDC token's audience is normally the target's scheme://host, which is
+// what Cloud Run expects when it's called on its run.app URL. Behind a custom
+// domain or load balancer the service still expects its own URL (or, behind
+// IAP, the OAuth client ID), so AUDIENCE_OVERRIDES maps the called backend to
+// the audience to request instead:
+//
+//	AUDIENCE_OVERRIDES="https://orders.example.com=https://order-service-xxxxx-uc.a.run.app"
+//
+// Backends not listed use the derived audience.
+var audienceOverrides = parseAudienceOverrides(os.Getenv("AUDIENCE_OVERRIDES"))
+
+// outboundAudience returns the ID token audience for calls to backend (scheme://host)
+func outboundAudience(backend string) string {
+	if audience, ok := audienceOverrides[backend]; ok {
+		return audience
+	}
+	return backend
+}
+
+// parseAudienceOverrides parses "backend=audience" pairs separated by commas
+func parseAudienceOverrides(raw string) map[string]string {
+	overrides := make(map[string]string)
+	for _, pair := range strings.Split(raw, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		// Backends are scheme://host, so the first "=" ends the key
+		backend, audience, ok := strings.Cut(pair, "=")
+		backend = strings.TrimRight(strings.TrimSpace(backend), "/")
+		audience = strings.TrimSpace(audience)
+		if !ok || !strings.Contains(backend, "://") || audience == "" {
+			logger.Warn("Ignoring invalid audience override", "value", pair)
+			continue
+		}
+		overrides[backend] = audience
+	}
+	return overrides
+}
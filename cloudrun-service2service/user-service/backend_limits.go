@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Outbound concurrency configuration.
+// OUTBOUND_MAX_CONCURRENCY caps simultaneous calls to any single backend (0 = unlimited).
+// OUTBOUND_MAX_CONCURRENCY_OVERRIDES sets per-backend caps, e.g.
+// "https://order-service-xxxxx-uc.a.run.app=5,https://other-service-xxxxx-uc.a.run.app=2".
+// OUTBOUND_CONCURRENCY_MODE is "queue" (wait for a slot) or "fail" (fast-fail when full).
+var (
+	outboundMaxConcurrency       = envInt("OUTBOUND_MAX_CONCURRENCY", 0)
+	outboundConcurrencyOverrides = parseBackendLimits(os.Getenv("OUTBOUND_MAX_CONCURRENCY_OVERRIDES"))
+	outboundConcurrencyMode      = envString("OUTBOUND_CONCURRENCY_MODE", "queue")
+)
+
+// errBackendBusy is returned when a backend is at its concurrency cap in "fail" mode
+var errBackendBusy = errors.New("backend concurrency limit reached")
+
+// backendLimiter hands out per-backend concurrency slots
+type backendLimiter struct {
+	mu       sync.Mutex
+	slots    map[string]chan struct{}
+	limitFor func(backend string) int
+	failFast bool
+}
+
+// outboundLimiter limits concurrent calls made by makeAuthenticatedRequest and AuthenticatedProxy
+var outboundLimiter = newBackendLimiter(func(backend string) int {
+	if limit, ok := outboundConcurrencyOverrides[backend]; ok {
+		return limit
+	}
+	return outboundMaxConcurrency
+}, strings.EqualFold(outboundConcurrencyMode, "fail"))
+
+func newBackendLimiter(limitFor func(backend string) int, failFast bool) *backendLimiter {
+	return &backendLimiter{
+		slots:    make(map[string]chan struct{}),
+		limitFor: limitFor,
+		failFast: failFast,
+	}
+}
+
+// acquire reserves a slot for backend and returns a function that releases it.
+// In queue mode it waits until a slot frees up or ctx is done; in fail mode it
+// returns errBackendBusy immediately when the backend is at capacity.
+func (l *backendLimiter) acquire(ctx context.Context, backend string) (func(), error) {
+	limit := l.limitFor(backend)
+	if limit <= 0 {
+		return func() {}, nil
+	}
+
+	l.mu.Lock()
+	slots, ok := l.slots[backend]
+	if !ok {
+		slots = make(chan struct{}, limit)
+		l.slots[backend] = slots
+	}
+	l.mu.Unlock()
+
+	release := func() { <-slots }
+
+	if l.failFast {
+		select {
+		case slots <- struct{}{}:
+			return release, nil
+		default:
+			return nil, fmt.Errorf("%w: %s (limit %d)", errBackendBusy, backend, limit)
+		}
+	}
+
+	// Track how many callers are queued for a slot
+	outboundQueueDepth.Add(1)
+	defer outboundQueueDepth.Add(-1)
+
+	select {
+	case slots <- struct{}{}:
+		return release, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// parseBackendLimits parses "backend=limit" pairs separated by commas
+func parseBackendLimits(raw string) map[string]int {
+	limits := make(map[string]int)
+	for _, pair := range strings.Split(raw, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		i := strings.LastIndex(pair, "=")
+		if i <= 0 {
+			logger.Warn("Ignoring invalid concurrency override", "value", pair)
+			continue
+		}
+		limit, err := strconv.Atoi(strings.TrimSpace(pair[i+1:]))
+		if err != nil || limit < 0 {
+			logger.Warn("Ignoring invalid concurrency override", "value", pair)
+			continue
+		}
+		limits[strings.TrimRight(strings.TrimSpace(pair[:i]), "/")] = limit
+	}
+	return limits
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestOutboundConcurrencyCapQueues(t *testing.T) {
+	var current, peak atomic.Int64
+	backend := newBackend(t, func(w http.ResponseWriter, r *http.Request) {
+		n := current.Add(1)
+		defer current.Add(-1)
+		for {
+			old := peak.Load()
+			if n <= old || peak.CompareAndSwap(old, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+	})
+	useCachedIDToken(t, backend.URL)
+	setForTest(t, &outboundLimiter, newBackendLimiter(func(string) int { return 2 }, false))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := makeAuthenticatedGet(context.Background(), backend.URL+"/orders"); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if peak.Load() > 2 {
+		t.Errorf("backend saw %d concurrent calls, want at most 2", peak.Load())
+	}
+}
+
+func TestOutboundConcurrencyCapFailMode(t *testing.T) {
+	limiter := newBackendLimiter(func(string) int { return 1 }, true)
+	release, err := limiter.acquire(context.Background(), "https://backend")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+
+	if _, err := limiter.acquire(context.Background(), "https://backend"); !errors.Is(err, errBackendBusy) {
+		t.Fatalf("second acquire: err = %v, want errBackendBusy", err)
+	}
+	// Each backend has its own slots
+	other, err := limiter.acquire(context.Background(), "https://other")
+	if err != nil {
+		t.Fatalf("other backend: %v", err)
+	}
+	other()
+}
+
+func TestParseBackendLimits(t *testing.T) {
+	limits := parseBackendLimits("https://a.run.app/=5, https://b.run.app=2,bad,https://c.run.app=-1")
+	if len(limits) != 2 || limits["https://a.run.app"] != 5 || limits["https://b.run.app"] != 2 {
+		t.Fatalf("limits = %v, want a=5 and b=2", limits)
+	}
+}
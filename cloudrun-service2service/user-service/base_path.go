@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// Base path configuration.
+// Behind an API gateway the service is often mounted at a subpath. With
+// BASE_PATH=/api/v1 every route is served under it (/api/v1/users, ...) and
+// the URLs the service hands out (Location headers, links, the OpenAPI
+// servers entry) include it. Public routes - the health checks - are served
+// at the root as well, so platform probes keep working unchanged.
+var basePath = parseBasePath(os.Getenv("BASE_PATH"))
+
+// parseBasePath normalizes BASE_PATH to "/segment[/segment...]" with no
+// trailing slash, or "" for none
+func parseBasePath(raw string) string {
+	trimmed := strings.Trim(strings.TrimSpace(raw), "/")
+	if trimmed == "" {
+		return ""
+	}
+	return "/" + trimmed
+}
+
+// selfURL returns the path clients should use to reach path on this service
+func selfURL(path string) string {
+	return basePath + path
+}
+
+// stripBasePath serves requests under BASE_PATH with the prefix removed, so
+// routes, auth exemptions and metrics all see the unprefixed path. Requests
+// outside it get 404, except for public routes.
+func stripBasePath(next http.Handler) http.Handler {
+	if basePath == "" {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rest, ok := strings.CutPrefix(r.URL.Path, basePath)
+		if ok && (rest == "" || strings.HasPrefix(rest, "/")) {
+			r2 := r.Clone(r.Context())
+			r2.URL.Path = "/" + strings.TrimPrefix(rest, "/")
+			if rawRest, ok := strings.CutPrefix(r.URL.RawPath, basePath); ok {
+				r2.URL.RawPath = "/" + strings.TrimPrefix(rawRest, "/")
+			}
+			next.ServeHTTP(w, r2)
+			return
+		}
+		if authExemptPaths[r.URL.Path] {
+			next.ServeHTTP(w, r)
+			return
+		}
+		writeError(w, r, http.StatusNotFound, "route_not_found", r.URL.Path)
+	})
+}
+
+// withServersEntry returns spec with a servers entry for BASE_PATH, so
+// generated clients and Swagger UI call the prefixed paths
+func withServersEntry(spec []byte) []byte {
+	if basePath == "" {
+		return spec
+	}
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(spec, &doc); err != nil {
+		panic(fmt.Sprintf("invalid embedded OpenAPI spec: %v", err))
+	}
+	servers, _ := json.Marshal([]map[string]string{{"url": basePath}})
+	doc["servers"] = servers
+	out, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		panic(fmt.Sprintf("failed to add servers to OpenAPI spec: %v", err))
+	}
+	return out
+}
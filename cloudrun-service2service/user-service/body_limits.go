@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+)
+
+// MAX_REQUEST_BODY_BYTES caps request bodies (default 1MB) so a huge or
+// endless upload can't exhaust memory while it's being decoded
+var maxRequestBodyBytes = int64(envInt("MAX_REQUEST_BODY_BYTES", 1<<20))
+
+// limitRequestBody is a middleware that caps every request body at maxRequestBodyBytes
+func limitRequestBody(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if maxRequestBodyBytes > 0 && r.Body != nil {
+			r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodyBytes)
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// decodeJSONBody decodes a JSON request body into v, writing 413 if the body
+// is over the size limit, or 400 if it isn't valid JSON or (for objects) has
+// unknown or mistyped fields. It reports whether v was decoded.
+func decodeJSONBody(w http.ResponseWriter, r *http.Request, body io.Reader, v interface{}) bool {
+	fields, ok := decodeJSONFields(w, r, body, v)
+	if ok && len(fields) > 0 {
+		writeFieldErrors(w, r, fields)
+		return false
+	}
+	return ok
+}
+
+// decodeJSONFields decodes a JSON request body into v. Objects are decoded
+// field by field (see unmarshalFields) and their field errors returned, so
+// the caller can report them together with its own validation. ok is false
+// if a response has already been written: 413 if the body is over the size
+// limit, 400 if it isn't valid JSON of the right shape.
+func decodeJSONFields(w http.ResponseWriter, r *http.Request, body io.Reader, v interface{}) (map[string]fieldError, bool) {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		writeBodyReadError(w, r, err)
+		return nil, false
+	}
+
+	if isStructPointer(v) {
+		fields, err := unmarshalFields(data, v)
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, "invalid_json")
+			return nil, false
+		}
+		return fields, true
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		writeError(w, r, http.StatusBadRequest, "invalid_json")
+		return nil, false
+	}
+	return nil, true
+}
+
+// writeBodyReadError writes 413 if reading the body failed because it's over
+// the limit, and 400 otherwise
+func writeBodyReadError(w http.ResponseWriter, r *http.Request, err error) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		writeError(w, r, http.StatusRequestEntityTooLarge, "body_too_large", tooLarge.Limit)
+		return
+	}
+	writeError(w, r, http.StatusBadRequest, "invalid_json")
+}
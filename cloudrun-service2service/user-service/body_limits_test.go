@@ -0,0 +1,39 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestRequestBodyOverLimit(t *testing.T) {
+	useTestStore(t)
+	setForTest(t, &maxRequestBodyBytes, 256)
+	server := newTestServer(t)
+
+	padding := strings.Repeat("x", 300)
+	for _, tc := range []struct {
+		method, path, body string
+	}{
+		{http.MethodPost, "/users", `{"name":"` + padding + `","email":"big@example.com","role":"viewer"}`},
+		// Bulk creates go through the same limit
+		{http.MethodPost, "/users", `[{"name":"` + padding + `","email":"big@example.com","role":"viewer"}]`},
+		{http.MethodPut, "/users/user-002", `{"name":"` + padding + `","email":"bob@example.com","role":"viewer"}`},
+	} {
+		resp, body := doRequest(t, server, tc.method, tc.path, tc.body, "Content-Type", "application/json")
+		if resp.StatusCode != http.StatusRequestEntityTooLarge || !strings.Contains(body, `"code":"body_too_large"`) {
+			t.Errorf("%s %s with a %d byte body: status = %d, want 413 body_too_large: %s", tc.method, tc.path, len(tc.body), resp.StatusCode, body)
+		}
+	}
+}
+
+func TestRequestBodyUnderLimit(t *testing.T) {
+	useTestStore(t)
+	setForTest(t, &maxRequestBodyBytes, 256)
+	server := newTestServer(t)
+
+	resp, body := doRequest(t, server, http.MethodPost, "/users", `{"name":"Dan Brown","email":"dan@example.com","role":"viewer"}`, "Content-Type", "application/json")
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("status = %d, want 201: %s", resp.StatusCode, body)
+	}
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// Body logging configuration.
+// DEBUG_LOG_BODIES=true logs the body and headers of every request and
+// response, and of every call made to another service, to debug the
+// service-to-service flow. Bodies are cut at DEBUG_LOG_BODY_MAX_BYTES. JSON
+// fields named in DEBUG_LOG_REDACT_FIELDS and credential headers are replaced
+// with "[REDACTED]". Off by default: bodies hold PII that shouldn't reach
+// production logs.
+var (
+	debugLogBodies       = envBool("DEBUG_LOG_BODIES", false)
+	debugLogBodyMaxBytes = envInt("DEBUG_LOG_BODY_MAX_BYTES", 2048)
+	debugLogRedactFields = parseRedactFields(envString("DEBUG_LOG_REDACT_FIELDS", "email,password,token,secret"))
+)
+
+// redactedValue replaces sensitive values in logged bodies and headers
+const redactedValue = "[REDACTED]"
+
+// redactedHeaders carry credentials and are never logged as is
+var redactedHeaders = map[string]bool{
+	"Authorization":              true,
+	"Proxy-Authorization":        true,
+	"Cookie":                     true,
+	"Set-Cookie":                 true,
+	"X-Admin-Token":              true,
+	"X-Serverless-Authorization": true,
+	"X-Signature":                true,
+}
+
+// parseRedactFields parses a comma-separated list of JSON field names, which
+// are matched case-insensitively
+func parseRedactFields(raw string) map[string]bool {
+	fields := make(map[string]bool)
+	for _, name := range strings.Split(raw, ",") {
+		if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+			fields[name] = true
+		}
+	}
+	return fields
+}
+
+// redactedFieldPattern matches a redacted field and its value in JSON text
+// that doesn't parse (e.g. a body cut off at the size limit), so a truncated
+// body can't leak what redaction would have hidden
+var redactedFieldPattern = buildRedactedFieldPattern(debugLogRedactFields)
+
+func buildRedactedFieldPattern(fields map[string]bool) *regexp.Regexp {
+	if len(fields) == 0 {
+		return nil
+	}
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, regexp.QuoteMeta(name))
+	}
+	return regexp.MustCompile(`(?i)("(?:` + strings.Join(names, "|") + `)"\s*:\s*)("(?:[^"\\]|\\.)*"?|[^,}\]\s]*)`)
+}
+
+// loggableBody returns body for a log line: JSON with the redacted fields
+// replaced (at any depth), cut to DEBUG_LOG_BODY_MAX_BYTES. size is the
+// body's full length, which may be more than was captured.
+func loggableBody(body []byte, size int) string {
+	var text string
+	var doc interface{}
+	if err := json.Unmarshal(body, &doc); err == nil {
+		redacted, _ := json.Marshal(redactJSON(doc))
+		text = string(redacted)
+	} else {
+		text = string(body)
+		if redactedFieldPattern != nil {
+			text = redactedFieldPattern.ReplaceAllString(text, `${1}"`+redactedValue+`"`)
+		}
+	}
+	if len(text) > debugLogBodyMaxBytes || size > len(body) {
+		text = text[:min(len(text), debugLogBodyMaxBytes)] + fmt.Sprintf("...(truncated, %d bytes)", size)
+	}
+	return text
+}
+
+// redactJSON replaces the values of redacted fields in a decoded JSON value
+func redactJSON(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for key, value := range t {
+			if debugLogRedactFields[strings.ToLower(key)] {
+				t[key] = redactedValue
+			} else {
+				t[key] = redactJSON(value)
+			}
+		}
+	case []interface{}:
+		for i, value := range t {
+			t[i] = redactJSON(value)
+		}
+	}
+	return v
+}
+
+// loggableHeaders flattens headers for a log line, with credentials redacted
+func loggableHeaders(h http.Header) map[string]string {
+	out := make(map[string]string, len(h))
+	for name, values := range h {
+		if redactedHeaders[http.CanonicalHeaderKey(name)] {
+			out[name] = redactedValue
+			continue
+		}
+		out[name] = strings.Join(values, ", ")
+	}
+	return out
+}
+
+// bodyCapture keeps the first max bytes written through it and counts the rest
+type bodyCapture struct {
+	buf  bytes.Buffer
+	max  int
+	size int
+}
+
+func (c *bodyCapture) capture(p []byte) {
+	c.size += len(p)
+	if room := c.max - c.buf.Len(); room > 0 {
+		c.buf.Write(p[:min(room, len(p))])
+	}
+}
+
+// captureRequestBody reads up to max bytes of r's body for logging and puts
+// them back in front of the rest, so the handler still reads the whole body
+// (and body limits still apply to it)
+func captureRequestBody(r *http.Request, max int) []byte {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil
+	}
+	prefix, _ := io.ReadAll(io.LimitReader(r.Body, int64(max)+1))
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(prefix), r.Body), r.Body}
+	return prefix
+}
+
+// bodyLoggingWriter captures the response body as it's written
+type bodyLoggingWriter struct {
+	http.ResponseWriter
+	status int
+	body   bodyCapture
+}
+
+func (bw *bodyLoggingWriter) WriteHeader(status int) {
+	if bw.status == 0 {
+		bw.status = status
+	}
+	bw.ResponseWriter.WriteHeader(status)
+}
+
+func (bw *bodyLoggingWriter) Write(p []byte) (int, error) {
+	if bw.status == 0 {
+		bw.status = http.StatusOK
+	}
+	bw.body.capture(p)
+	return bw.ResponseWriter.Write(p)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (bw *bodyLoggingWriter) Unwrap() http.ResponseWriter {
+	return bw.ResponseWriter
+}
+
+// Flush passes flushes through so streamed responses aren't held back
+func (bw *bodyLoggingWriter) Flush() {
+	if flusher, ok := bw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// logBodies is a middleware that logs each request's and response's headers
+// and body when DEBUG_LOG_BODIES is on
+func logBodies(handler http.Handler) http.Handler {
+	if !debugLogBodies {
+		return handler
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestBody := captureRequestBody(r, debugLogBodyMaxBytes)
+		requestSize := len(requestBody)
+		if r.ContentLength > int64(requestSize) {
+			requestSize = int(r.ContentLength)
+		}
+
+		bw := &bodyLoggingWriter{ResponseWriter: w, body: bodyCapture{max: debugLogBodyMaxBytes}}
+		handler.ServeHTTP(bw, r)
+
+		logger.InfoContext(r.Context(), "request bodies",
+			"method", r.Method,
+			"path", r.URL.EscapedPath(),
+			"request_headers", loggableHeaders(r.Header),
+			"request_body", loggableBody(requestBody, requestSize),
+			"status", bw.status,
+			"response_headers", loggableHeaders(w.Header()),
+			"response_body", loggableBody(bw.body.buf.Bytes(), bw.body.size),
+		)
+	})
+}
+
+// logOutboundBodies logs an outbound call's headers and bodies when
+// DEBUG_LOG_BODIES is on. resp is nil if the call got no response.
+func logOutboundBodies(ctx context.Context, req *http.Request, reqBody []byte, resp *http.Response, respBody []byte) {
+	if !debugLogBodies {
+		return
+	}
+	args := []interface{}{
+		"method", req.Method,
+		"url", req.URL.String(),
+		"request_headers", loggableHeaders(req.Header),
+		"request_body", loggableBody(reqBody, len(reqBody)),
+	}
+	if resp != nil {
+		args = append(args,
+			"status", resp.StatusCode,
+			"response_headers", loggableHeaders(resp.Header),
+			"response_body", loggableBody(respBody, len(respBody)),
+		)
+	}
+	logger.InfoContext(ctx, "outbound bodies", args...)
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestLoggableBodyRedactsFields(t *testing.T) {
+	body := `{"name":"Dave","Email":"dave@example.com","auth":{"token":"abc123"},"items":[{"secret":"s3"}]}`
+	got := loggableBody([]byte(body), len(body))
+	for _, leaked := range []string{"dave@example.com", "abc123", "s3"} {
+		if strings.Contains(got, leaked) {
+			t.Errorf("loggableBody leaked %q: %s", leaked, got)
+		}
+	}
+	if !strings.Contains(got, `"name":"Dave"`) || strings.Count(got, redactedValue) != 3 {
+		t.Errorf("loggableBody = %s, want name kept and 3 fields redacted", got)
+	}
+}
+
+func TestLoggableBodyTruncated(t *testing.T) {
+	setForTest(t, &debugLogBodyMaxBytes, 40)
+
+	// Cut off mid-document, so it no longer parses as JSON
+	full := `{"name":"Dave","email":"dave@example.com","role":"` + strings.Repeat("x", 100) + `"}`
+	got := loggableBody([]byte(full[:41]), len(full))
+	if strings.Contains(got, "dave@example.com") {
+		t.Errorf("truncated body leaked the email: %s", got)
+	}
+	if !strings.HasSuffix(got, fmt.Sprintf("...(truncated, %d bytes)", len(full))) {
+		t.Errorf("loggableBody = %s, want it marked truncated with the full size", got)
+	}
+
+	// A cut-off redacted value is still hidden
+	got = loggableBody([]byte(`{"name":"Dave","email":"dave@exa`), 60)
+	if strings.Contains(got, "dave@exa") {
+		t.Errorf("partial email leaked: %s", got)
+	}
+}
+
+func TestLoggableHeadersRedactsCredentials(t *testing.T) {
+	got := loggableHeaders(http.Header{
+		"Authorization": {"Bearer secret-token"},
+		"Cookie":        {"session=abc"},
+		"X-Admin-Token": {"admin"},
+		"Accept":        {"application/json", "text/plain"},
+	})
+	for _, name := range []string{"Authorization", "Cookie", "X-Admin-Token"} {
+		if got[name] != redactedValue {
+			t.Errorf("%s = %q, want %s", name, got[name], redactedValue)
+		}
+	}
+	if got["Accept"] != "application/json, text/plain" {
+		t.Errorf("Accept = %q, want both values", got["Accept"])
+	}
+}
+
+func TestRequestBodiesLogged(t *testing.T) {
+	useTestStore(t)
+	setForTest(t, &debugLogBodies, true)
+	logs := captureLogs(t, slog.LevelInfo)
+
+	server := newTestServer(t)
+	resp, body := doRequest(t, server, http.MethodPost, "/users",
+		`{"id":"user-004","name":"Dave Jones","email":"dave@example.com","role":"viewer"}`,
+		"Content-Type", "application/json", "Authorization", "Bearer secret-token")
+	// The handler still reads the whole body after it's been captured
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("status = %d, want 201: %s", resp.StatusCode, body)
+	}
+	// The bodies are logged once the handler returns, which can be after the
+	// client has the response; Close waits for it
+	server.Close()
+	for _, want := range []string{`"message":"request bodies"`, `Dave Jones`, `"status":201`, redactedValue} {
+		if !strings.Contains(logs.String(), want) {
+			t.Errorf("logs don't contain %s:\n%s", want, logs)
+		}
+	}
+	for _, leaked := range []string{"dave@example.com", "secret-token"} {
+		if strings.Contains(logs.String(), leaked) {
+			t.Errorf("logs contain %s:\n%s", leaked, logs)
+		}
+	}
+}
+
+func TestOutboundBodiesLogged(t *testing.T) {
+	useTestStore(t)
+	setForTest(t, &debugLogBodies, true)
+	backend := useOrderService(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(testOrdersJSON("user-001")))
+	})
+	token := useCachedIDToken(t, backend.URL)
+	logs := captureLogs(t, slog.LevelInfo)
+
+	if resp, body := doRequest(t, newTestServer(t), http.MethodGet, "/users/user-001/orders", ""); resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", resp.StatusCode, body)
+	}
+	for _, want := range []string{`"message":"outbound bodies"`, `/orders/user/user-001`, `order-1`} {
+		if !strings.Contains(logs.String(), want) {
+			t.Errorf("logs don't contain %s:\n%s", want, logs)
+		}
+	}
+	if strings.Contains(logs.String(), token) {
+		t.Error("the ID token was logged")
+	}
+}
+
+func TestBodiesNotLoggedByDefault(t *testing.T) {
+	useTestStore(t)
+	setForTest(t, &debugLogBodies, false)
+	logs := captureLogs(t, slog.LevelDebug)
+
+	doRequest(t, newTestServer(t), http.MethodGet, "/users/user-001", "")
+	if strings.Contains(logs.String(), "request bodies") || strings.Contains(logs.String(), "alice@") {
+		t.Errorf("bodies logged without DEBUG_LOG_BODIES:\n%s", logs)
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"unicode"
+)
+
+// BULK_CREATE_MAX_USERS caps how many users one POST /users array may hold
+var bulkCreateMaxUsers = envInt("BULK_CREATE_MAX_USERS", 1000)
+
+// BulkItemError is the error for one rejected entry of a bulk create
+type BulkItemError struct {
+	Index int `json:"index"`
+	ErrorResponse
+}
+
+// BulkCreateResponse represents the response for POST /users with an array body
+type BulkCreateResponse struct {
+	Service string          `json:"service"`
+	Created int             `json:"created"`
+	Failed  int             `json:"failed"`
+	Users   []User          `json:"users"`
+	Errors  []BulkItemError `json:"errors,omitempty"`
+	Message string          `json:"message"`
+}
+
+// isJSONArray reports whether the body's first non-whitespace byte is '['
+// without consuming anything the JSON decoder needs
+func isJSONArray(body *bufio.Reader) bool {
+	for {
+		b, err := body.ReadByte()
+		if err != nil {
+			return false
+		}
+		if !unicode.IsSpace(rune(b)) {
+			body.UnreadByte()
+			return b == '['
+		}
+	}
+}
+
+// createUsers handles POST /users with a JSON array. Each entry is validated
+// and inserted on its own, so bad entries are reported without aborting the
+// rest: 201 when every user was created, 207 Multi-Status otherwise.
+func createUsers(w http.ResponseWriter, r *http.Request, body *bufio.Reader) {
+	var batch []json.RawMessage
+	if !decodeJSONBody(w, r, body, &batch) {
+		return
+	}
+	if len(batch) == 0 {
+		writeError(w, r, http.StatusBadRequest, "empty_batch")
+		return
+	}
+	if len(batch) > bulkCreateMaxUsers {
+		writeError(w, r, http.StatusRequestEntityTooLarge, "batch_too_large", len(batch), bulkCreateMaxUsers)
+		return
+	}
+
+	lang := requestLanguage(r)
+	response := BulkCreateResponse{
+		Service: "user-service (Go)",
+		Users:   []User{},
+	}
+	for i, raw := range batch {
+		var newUser User
+		fields, err := unmarshalFields(raw, &newUser)
+		if err != nil {
+			response.Errors = append(response.Errors, BulkItemError{Index: i, ErrorResponse: localizedError(lang, "invalid_json")})
+			continue
+		}
+		if len(fields) > 0 {
+			fields = mergeFieldErrors(fields, validateUserFields(&newUser.Name, &newUser.Email, &newUser.Role))
+			response.Errors = append(response.Errors, BulkItemError{Index: i, ErrorResponse: fieldErrorsResponse(lang, fields)})
+			continue
+		}
+		created, _, errResp := insertUser(r.Context(), lang, newUser)
+		if errResp != nil {
+			response.Errors = append(response.Errors, BulkItemError{Index: i, ErrorResponse: *errResp})
+			continue
+		}
+		response.Users = append(response.Users, created)
+	}
+	response.Created = len(response.Users)
+	response.Failed = len(response.Errors)
+	response.Message = fmt.Sprintf("Created %d of %d users", response.Created, len(batch))
+
+	status := http.StatusCreated
+	if response.Failed > 0 {
+		status = http.StatusMultiStatus
+	}
+	w.Header().Set("Content-Language", lang.String())
+	writeJSON(w, status, response)
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// Per-caller rate limit configuration.
+// CALLER_RATE_PER_SECOND gives each caller its own token bucket (0 = unlimited)
+// so one abusive client can't starve the rest. Callers are identified by their
+// verified token (REQUIRE_AUTH) or, unauthenticated, by client IP. Buckets
+// idle for CALLER_LIMITER_IDLE_TTL are dropped.
+var (
+	callerRatePerSecond  = envFloat("CALLER_RATE_PER_SECOND", 0)
+	callerRateBurst      = envInt("CALLER_RATE_BURST", 20)
+	callerLimiterIdleTTL = envDuration("CALLER_LIMITER_IDLE_TTL", 10*time.Minute)
+)
+
+// callerLimiter is one caller's token bucket and when it was last used
+type callerLimiter struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// callerLimiters holds a token bucket per caller key
+var callerLimiters = struct {
+	sync.Mutex
+	limiters  map[string]*callerLimiter
+	lastPrune time.Time
+}{limiters: make(map[string]*callerLimiter)}
+
+// limiterForCaller returns key's token bucket, creating it on first use.
+// Idle buckets are pruned at most once per CALLER_LIMITER_IDLE_TTL, so the
+// map only holds callers seen recently.
+func limiterForCaller(key string, now time.Time) *rate.Limiter {
+	callerLimiters.Lock()
+	defer callerLimiters.Unlock()
+
+	if now.Sub(callerLimiters.lastPrune) >= callerLimiterIdleTTL {
+		for k, cl := range callerLimiters.limiters {
+			if now.Sub(cl.lastSeen) >= callerLimiterIdleTTL {
+				delete(callerLimiters.limiters, k)
+			}
+		}
+		callerLimiters.lastPrune = now
+	}
+
+	cl, ok := callerLimiters.limiters[key]
+	if !ok {
+		burst := callerRateBurst
+		if burst < 1 {
+			burst = 1
+		}
+		cl = &callerLimiter{limiter: rate.NewLimiter(rate.Limit(callerRatePerSecond), burst)}
+		callerLimiters.limiters[key] = cl
+	}
+	cl.lastSeen = now
+	return cl.limiter
+}
+
+// callerRateKey returns the identity a request is rate limited by: the verified
+// caller's email or subject, else the client IP
+func callerRateKey(r *http.Request) string {
+	if caller := callerFromContext(r.Context()); caller != nil {
+		if caller.Email != "" {
+			return "email:" + caller.Email
+		}
+		if caller.Subject != "" {
+			return "sub:" + caller.Subject
+		}
+	}
+	return "ip:" + clientIP(r)
+}
+
+// clientIP returns the address the request came from. Cloud Run's front end
+// appends the client address to X-Forwarded-For, so the last entry is the one
+// a client can't forge; locally there's no proxy and RemoteAddr is used.
+func clientIP(r *http.Request) string {
+	if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+		entries := strings.Split(forwarded, ",")
+		if ip := strings.TrimSpace(entries[len(entries)-1]); ip != "" {
+			return ip
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// limitCallerRate is a middleware that rejects a caller's requests over
+// CALLER_RATE_PER_SECOND with 429 and Retry-After. It runs after authenticate
+// so verified callers are limited by identity. Health checks are exempt.
+func limitCallerRate(handler http.Handler) http.Handler {
+	if callerRatePerSecond <= 0 {
+		return handler
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if authExemptPaths[r.URL.Path] {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		if !allowRate(w, r, limiterForCaller(callerRateKey(r), time.Now()), "caller_rate_limited") {
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+// useCallerRateLimit limits each caller to burst requests, refilled slowly
+// enough that none come back during the test
+func useCallerRateLimit(t *testing.T, burst int) {
+	t.Helper()
+	setForTest(t, &callerRatePerSecond, 0.01)
+	setForTest(t, &callerRateBurst, burst)
+	t.Cleanup(func() {
+		callerLimiters.Lock()
+		clear(callerLimiters.limiters)
+		callerLimiters.lastPrune = time.Time{}
+		callerLimiters.Unlock()
+	})
+}
+
+func TestCallerRateLimitPerClientIP(t *testing.T) {
+	useTestStore(t)
+	useCallerRateLimit(t, 2)
+	server := newTestServer(t)
+
+	get := func(ip string) *http.Response {
+		resp, _ := doRequest(t, server, http.MethodGet, "/users/user-001", "", "X-Forwarded-For", "spoofed, "+ip)
+		return resp
+	}
+	for i := 0; i < 2; i++ {
+		if resp := get("203.0.113.1"); resp.StatusCode != http.StatusOK {
+			t.Fatalf("request %d: status = %d, want 200 within the burst", i+1, resp.StatusCode)
+		}
+	}
+	resp := get("203.0.113.1")
+	if resp.StatusCode != http.StatusTooManyRequests || resp.Header.Get("Retry-After") == "" {
+		t.Fatalf("over the burst: status = %d, Retry-After %q; want 429 with Retry-After", resp.StatusCode, resp.Header.Get("Retry-After"))
+	}
+
+	// Another client has its own bucket
+	if resp := get("203.0.113.2"); resp.StatusCode != http.StatusOK {
+		t.Errorf("other client: status = %d, want 200", resp.StatusCode)
+	}
+	// Health checks are never limited
+	if resp, _ := doRequest(t, server, http.MethodGet, "/health", "", "X-Forwarded-For", "203.0.113.1"); resp.StatusCode != http.StatusOK {
+		t.Errorf("/health: status = %d, want 200", resp.StatusCode)
+	}
+}
+
+func TestCallerRateLimitByVerifiedIdentity(t *testing.T) {
+	useTestStore(t)
+	useAuth(t)
+	useCallerRateLimit(t, 1)
+	server := newTestServer(t)
+	alice := bearer(map[string]interface{}{"email": "alice@example.com"})
+	bob := bearer(map[string]interface{}{"email": "bob@example.com"})
+
+	doRequest(t, server, http.MethodGet, "/users/user-001", "", "Authorization", alice)
+	// Same IP, different caller: identity decides the bucket
+	if resp, _ := doRequest(t, server, http.MethodGet, "/users/user-001", "", "Authorization", bob); resp.StatusCode != http.StatusOK {
+		t.Errorf("bob: status = %d, want 200", resp.StatusCode)
+	}
+	if resp, _ := doRequest(t, server, http.MethodGet, "/users/user-001", "", "Authorization", alice); resp.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("alice's second request: status = %d, want 429", resp.StatusCode)
+	}
+}
+
+func TestCallerLimitersPruned(t *testing.T) {
+	useCallerRateLimit(t, 1)
+	setForTest(t, &callerLimiterIdleTTL, time.Minute)
+	start := time.Now()
+
+	limiterForCaller("ip:203.0.113.1", start)
+	limiterForCaller("ip:203.0.113.2", start.Add(50*time.Second))
+	limiterForCaller("ip:203.0.113.3", start.Add(90*time.Second))
+
+	callerLimiters.Lock()
+	defer callerLimiters.Unlock()
+	if _, ok := callerLimiters.limiters["ip:203.0.113.1"]; ok {
+		t.Error("idle caller's bucket wasn't pruned")
+	}
+	if len(callerLimiters.limiters) != 2 {
+		t.Errorf("%d buckets, want the 2 recently used", len(callerLimiters.limiters))
+	}
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+)
+
+// Capability describes an optional feature and whether it's enabled on this deployment
+type Capability struct {
+	Enabled    bool                   `json:"enabled"`
+	Parameters map[string]interface{} `json:"parameters,omitempty"`
+}
+
+// CapabilitiesResponse represents the response for GET /capabilities
+type CapabilitiesResponse struct {
+	Service  string                `json:"service"`
+	Version  string                `json:"version"`
+	Features map[string]Capability `json:"features"`
+}
+
+// currentCapabilities builds the capability document from the live configuration,
+// so it always reflects what this instance will actually do
+func currentCapabilities() map[string]Capability {
+	hmacBackends := make([]string, 0, len(outboundAuthModes))
+	for backend, mode := range outboundAuthModes {
+		if mode == authModeHMAC {
+			hmacBackends = append(hmacBackends, backend)
+		}
+	}
+	sort.Strings(hmacBackends)
+
+	overriddenBackends := make([]string, 0, len(audienceOverrides))
+	for backend := range audienceOverrides {
+		overriddenBackends = append(overriddenBackends, backend)
+	}
+	sort.Strings(overriddenBackends)
+
+	corsOrigins := make([]string, 0, len(corsAllowedOrigins))
+	for origin := range corsAllowedOrigins {
+		corsOrigins = append(corsOrigins, origin)
+	}
+	sort.Strings(corsOrigins)
+
+	return map[string]Capability{
+		"pagination": {
+			Enabled: true,
+			Parameters: map[string]interface{}{
+				"default_page_size": defaultPageSize,
+				"max_page_size":     maxPageSize,
+			},
+		},
+		"max_response_size": {
+			Enabled: maxResponseBytes > 0,
+			Parameters: map[string]interface{}{
+				"max_bytes": maxResponseBytes,
+				"mode":      responseSizeMode,
+			},
+		},
+		"outbound_retries": {
+			Enabled: outboundMaxRetries > 0,
+			Parameters: map[string]interface{}{
+				"max_retries": outboundMaxRetries,
+				"base_delay":  outboundRetryBaseDelay.String(),
+				"max_delay":   outboundRetryMaxDelay.String(),
+			},
+		},
+		"id_migration": {
+			Enabled: allowIDMigration && storeBackend == "memory",
+			Parameters: map[string]interface{}{
+				"grace_period": idMigrationGracePeriod.String(),
+			},
+		},
+		"context_propagation": {
+			Enabled: len(propagatedHeaders) > 0,
+			Parameters: map[string]interface{}{
+				"headers":   propagatedHeaders,
+				"max_bytes": propagatedHeaderMaxBytes,
+			},
+		},
+		"error_rate_readiness": {
+			Enabled: errorRateThreshold > 0,
+			Parameters: map[string]interface{}{
+				"threshold":    errorRateThreshold,
+				"window":       errorRateWindow.String(),
+				"min_requests": errorRateMinRequests,
+			},
+		},
+		"circuit_breaker": {
+			Enabled: circuitBreakerFailureThreshold > 0,
+			Parameters: map[string]interface{}{
+				"failure_threshold": circuitBreakerFailureThreshold,
+				"open_timeout":      circuitBreakerOpenTimeout.String(),
+			},
+		},
+		"response_signing": {
+			Enabled: signResponses && responseSigningKey != "",
+			Parameters: map[string]interface{}{
+				"header":    "X-Signature",
+				"algorithm": "hmac-sha256",
+			},
+		},
+		"demo_reset": {
+			Enabled: allowDemoReset && storeBackend == "memory",
+		},
+		"conditional_requests": {
+			Enabled: true,
+			Parameters: map[string]interface{}{
+				"etag":    "strong",
+				"headers": []string{"If-None-Match", "If-Match"},
+			},
+		},
+		"json_patch": {
+			Enabled: true,
+			Parameters: map[string]interface{}{
+				"media_types": []string{mediaTypeMergePatch, mediaTypeJSONPatch},
+			},
+		},
+		"orders_cache": {
+			Enabled: ordersCacheTTL > 0,
+			Parameters: map[string]interface{}{
+				"ttl_seconds": ordersCacheTTL.Seconds(),
+				"max_entries": ordersCacheMaxEntries,
+			},
+		},
+		"cors": {
+			Enabled: len(corsAllowedOrigins) > 0,
+			Parameters: map[string]interface{}{
+				"allowed_origins":   corsOrigins,
+				"allow_credentials": corsCredentialsAllowed(),
+			},
+		},
+		"firestore_store": {
+			Enabled: storeBackend == "firestore",
+			Parameters: map[string]interface{}{
+				"collection": firestoreCollection,
+			},
+		},
+		"order_service": {
+			Enabled: getOrderServiceURL() != "" || mockOrderService(),
+			Parameters: map[string]interface{}{
+				"mode": orderServiceMode,
+			},
+		},
+		"config_reload": {
+			Enabled: configFile != "" && configReloadInterval > 0,
+			Parameters: map[string]interface{}{
+				"interval_seconds": configReloadInterval.Seconds(),
+			},
+		},
+		"snapshots": {
+			Enabled: snapshotDir != "",
+			Parameters: map[string]interface{}{
+				"periodic":         snapshotInterval > 0,
+				"interval_seconds": snapshotInterval.Seconds(),
+				"on_demand":        allowSnapshot,
+			},
+		},
+		"mesh_health": {
+			Enabled: true,
+			Parameters: map[string]interface{}{
+				"dependencies":      len(meshDependencies),
+				"cache_ttl_seconds": meshHealthCacheTTL.Seconds(),
+			},
+		},
+		"outbound_concurrency_limit": {
+			Enabled: outboundMaxConcurrency > 0 || len(outboundConcurrencyOverrides) > 0,
+			Parameters: map[string]interface{}{
+				"default_limit": outboundMaxConcurrency,
+				"mode":          outboundConcurrencyMode,
+			},
+		},
+		"hmac_signing": {
+			Enabled: len(hmacBackends) > 0,
+			Parameters: map[string]interface{}{
+				"backends": hmacBackends,
+			},
+		},
+		"caller_rate_limit": {
+			Enabled: callerRatePerSecond > 0,
+			Parameters: map[string]interface{}{
+				"per_second": callerRatePerSecond,
+				"burst":      callerRateBurst,
+			},
+		},
+		"tracing": {
+			Enabled: tracingEnabled(),
+			Parameters: map[string]interface{}{
+				"exporter":     traceExporter,
+				"sample_ratio": traceSampleRatio,
+			},
+		},
+		"audience_overrides": {
+			Enabled: len(audienceOverrides) > 0,
+			Parameters: map[string]interface{}{
+				"backends": overriddenBackends,
+			},
+		},
+		"strict_contract": {
+			Enabled: strictContract,
+		},
+		"buffered_json": {
+			Enabled: bufferJSONResponses,
+			Parameters: map[string]interface{}{
+				"max_buffer_bytes": jsonBufferMaxBytes,
+			},
+		},
+		"role_mapping": {
+			Enabled: len(groupRoleMapping) > 0,
+			Parameters: map[string]interface{}{
+				"enforce_roles": enforceRoles,
+			},
+		},
+		"unique_names": {
+			Enabled: uniqueNames,
+			Parameters: map[string]interface{}{
+				"ignore_case": uniqueNamesIgnoreCase,
+			},
+		},
+		"validation_warnings": {
+			Enabled: len(enabledWarningRules) > 0,
+			Parameters: map[string]interface{}{
+				"rules": enabledWarningRules,
+			},
+		},
+		"user_id_pattern": {
+			Enabled: true,
+			Parameters: map[string]interface{}{
+				"pattern": userIDPattern.String(),
+			},
+		},
+		"deterministic_demo": {
+			Enabled: deterministicDemo,
+		},
+	}
+}
+
+// capabilitiesHandler handles GET /capabilities
+func capabilitiesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, r, http.MethodGet)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, CapabilitiesResponse{
+		Service:  "user-service (Go)",
+		Version:  version,
+		Features: currentCapabilities(),
+	})
+}
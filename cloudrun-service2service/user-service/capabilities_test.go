@@ -0,0 +1,52 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+// getCapabilities calls GET /capabilities and decodes the document
+func getCapabilities(t *testing.T) CapabilitiesResponse {
+	t.Helper()
+	rec := serveHandler(capabilitiesHandler, http.MethodGet, "/capabilities", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+	var resp CapabilitiesResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func TestCapabilitiesReflectConfiguration(t *testing.T) {
+	setForTest(t, &uniqueNames, false)
+	setForTest(t, &outboundAuthModes, map[string]string{})
+	resp := getCapabilities(t)
+	if resp.Version != version || resp.Features["unique_names"].Enabled || resp.Features["hmac_signing"].Enabled {
+		t.Fatalf("capabilities = %+v, want unique_names and hmac_signing off", resp)
+	}
+	if !resp.Features["pagination"].Enabled {
+		t.Error("pagination isn't advertised")
+	}
+
+	setForTest(t, &uniqueNames, true)
+	setForTest(t, &outboundAuthModes, map[string]string{"https://b.example.com": authModeHMAC, "https://a.example.com": authModeHMAC})
+	resp = getCapabilities(t)
+	if !resp.Features["unique_names"].Enabled {
+		t.Error("unique_names not advertised with UNIQUE_NAMES on")
+	}
+	hmacSigning := resp.Features["hmac_signing"]
+	backends, _ := hmacSigning.Parameters["backends"].([]interface{})
+	if !hmacSigning.Enabled || len(backends) != 2 || backends[0] != "https://a.example.com" {
+		t.Errorf("hmac_signing = %+v, want both backends, sorted", hmacSigning)
+	}
+}
+
+func TestCapabilitiesMethodNotAllowed(t *testing.T) {
+	rec := serveHandler(capabilitiesHandler, http.MethodPost, "/capabilities", "")
+	if rec.Code != http.StatusMethodNotAllowed || rec.Header().Get("Allow") != http.MethodGet {
+		t.Fatalf("status = %d, Allow %q; want 405 with Allow: GET", rec.Code, rec.Header().Get("Allow"))
+	}
+}
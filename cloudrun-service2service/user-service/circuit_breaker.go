@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Circuit breaker configuration.
+// After CIRCUIT_BREAKER_FAILURE_THRESHOLD consecutive failed calls to a backend
+// (0 disables the breaker), calls fail fast for CIRCUIT_BREAKER_OPEN_TIMEOUT
+// instead of waiting out timeouts and retries. Then a single probe call is let
+// through: success closes the circuit, failure opens it again.
+var (
+	circuitBreakerFailureThreshold = envInt("CIRCUIT_BREAKER_FAILURE_THRESHOLD", 5)
+	circuitBreakerOpenTimeout      = envDuration("CIRCUIT_BREAKER_OPEN_TIMEOUT", 30*time.Second)
+)
+
+// errCircuitOpen is returned (wrapped in a *circuitOpenError) while a backend's circuit is open
+var errCircuitOpen = errors.New("circuit breaker open")
+
+// circuitOpenError reports which backend is unavailable and when to try again
+type circuitOpenError struct {
+	Backend    string
+	RetryAfter time.Duration
+}
+
+func (e *circuitOpenError) Error() string {
+	return fmt.Sprintf("%v: %s (retry in %s)", errCircuitOpen, e.Backend, e.RetryAfter.Round(time.Second))
+}
+
+func (e *circuitOpenError) Is(target error) bool {
+	return target == errCircuitOpen
+}
+
+// Circuit states, as reported in /readyz and the circuit_breaker_state metric
+const (
+	circuitClosed   = "closed"
+	circuitOpen     = "open"
+	circuitHalfOpen = "half_open"
+)
+
+// breakerResult is how a call's outcome counts towards the circuit
+type breakerResult int
+
+const (
+	breakerSuccess breakerResult = iota
+	breakerFailure
+	// breakerIgnored is for calls that say nothing about the backend's health
+	// (e.g. the caller went away)
+	breakerIgnored
+)
+
+// circuitBreaker tracks one backend
+type circuitBreaker struct {
+	state    string
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+// circuitBreakers holds a breaker per backend
+type circuitBreakers struct {
+	mu          sync.Mutex
+	breakers    map[string]*circuitBreaker
+	threshold   int
+	openTimeout time.Duration
+	now         func() time.Time
+}
+
+// outboundBreakers guards calls made by doAuthenticatedRequest
+var outboundBreakers = newCircuitBreakers(circuitBreakerFailureThreshold, circuitBreakerOpenTimeout)
+
+func newCircuitBreakers(threshold int, openTimeout time.Duration) *circuitBreakers {
+	return &circuitBreakers{
+		breakers:    make(map[string]*circuitBreaker),
+		threshold:   threshold,
+		openTimeout: openTimeout,
+		now:         time.Now,
+	}
+}
+
+// allow reports whether a call to backend may proceed. If so, the returned
+// function must be called with the call's result; otherwise the error is a
+// *circuitOpenError.
+func (c *circuitBreakers) allow(backend string) (func(breakerResult), error) {
+	if c.threshold <= 0 {
+		return func(breakerResult) {}, nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	b, ok := c.breakers[backend]
+	if !ok {
+		b = &circuitBreaker{state: circuitClosed}
+		c.breakers[backend] = b
+		observeCircuitState(backend, circuitClosed)
+	}
+
+	switch b.state {
+	case circuitOpen:
+		if wait := c.openTimeout - c.now().Sub(b.openedAt); wait > 0 {
+			return nil, &circuitOpenError{Backend: backend, RetryAfter: wait}
+		}
+		// Open long enough: let this call through as the probe
+		c.setState(backend, b, circuitHalfOpen)
+		b.probing = true
+	case circuitHalfOpen:
+		// Only one probe at a time; everyone else keeps failing fast
+		if b.probing {
+			return nil, &circuitOpenError{Backend: backend, RetryAfter: c.openTimeout}
+		}
+		b.probing = true
+	}
+
+	probe := b.state == circuitHalfOpen
+	return func(result breakerResult) { c.record(backend, b, probe, result) }, nil
+}
+
+// record updates backend's circuit with a call's result
+func (c *circuitBreakers) record(backend string, b *circuitBreaker, probe bool, result breakerResult) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if probe {
+		b.probing = false
+	}
+
+	switch result {
+	case breakerSuccess:
+		b.failures = 0
+		if b.state != circuitClosed {
+			c.setState(backend, b, circuitClosed)
+		}
+	case breakerFailure:
+		b.failures++
+		if probe || (b.state == circuitClosed && b.failures >= c.threshold) {
+			b.openedAt = c.now()
+			c.setState(backend, b, circuitOpen)
+		}
+	}
+}
+
+// setState moves b to state, logging and exporting the transition. Caller must hold c.mu.
+func (c *circuitBreakers) setState(backend string, b *circuitBreaker, state string) {
+	logger.Warn("Circuit breaker state changed", "backend", backend, "from", b.state, "to", state, "consecutive_failures", b.failures)
+	b.state = state
+	observeCircuitState(backend, state)
+}
+
+// states returns each known backend's circuit state
+func (c *circuitBreakers) states() map[string]string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	states := make(map[string]string, len(c.breakers))
+	for backend, b := range c.breakers {
+		states[backend] = b.state
+	}
+	return states
+}
+
+// breakerResultFor classifies the outcome of an outbound call. Only failures
+// that suggest the backend is down count against it: 4xx responses mean it's
+// up, and cancellations or local concurrency limits say nothing either way.
+func breakerResultFor(ctx context.Context, err error) breakerResult {
+	switch {
+	case err == nil:
+		return breakerSuccess
+	case ctx.Err() != nil, errors.Is(err, errBackendBusy):
+		return breakerIgnored
+	case retryable(ctx, err):
+		return breakerFailure
+	default:
+		return breakerSuccess
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestBreakers returns breakers whose clock the test moves with *now
+func newTestBreakers(threshold int, openTimeout time.Duration, now *time.Time) *circuitBreakers {
+	breakers := newCircuitBreakers(threshold, openTimeout)
+	breakers.now = func() time.Time { return *now }
+	return breakers
+}
+
+// callThrough makes one call through breakers, reporting result if it's allowed
+func callThrough(breakers *circuitBreakers, result breakerResult) error {
+	done, err := breakers.allow("https://orders.run.app")
+	if err == nil {
+		done(result)
+	}
+	return err
+}
+
+func TestCircuitOpensAfterThreshold(t *testing.T) {
+	now := time.Now()
+	breakers := newTestBreakers(3, 30*time.Second, &now)
+
+	callThrough(breakers, breakerFailure)
+	callThrough(breakers, breakerFailure)
+	// A success resets the count of consecutive failures
+	callThrough(breakers, breakerSuccess)
+	callThrough(breakers, breakerFailure)
+	callThrough(breakers, breakerFailure)
+	if err := callThrough(breakers, breakerFailure); err != nil {
+		t.Fatalf("third consecutive failure was refused: %v", err)
+	}
+
+	err := callThrough(breakers, breakerSuccess)
+	var openErr *circuitOpenError
+	if !errors.As(err, &openErr) || !errors.Is(err, errCircuitOpen) || openErr.RetryAfter != 30*time.Second {
+		t.Fatalf("err = %v, want the circuit open for 30s", err)
+	}
+	if state := breakers.states()["https://orders.run.app"]; state != circuitOpen {
+		t.Errorf("state = %s, want open", state)
+	}
+}
+
+func TestCircuitHalfOpenProbe(t *testing.T) {
+	now := time.Now()
+	breakers := newTestBreakers(1, 30*time.Second, &now)
+	callThrough(breakers, breakerFailure)
+
+	now = now.Add(31 * time.Second)
+	probeDone, err := breakers.allow("https://orders.run.app")
+	if err != nil {
+		t.Fatalf("probe refused after the open timeout: %v", err)
+	}
+	// Only one probe at a time
+	if err := callThrough(breakers, breakerSuccess); !errors.Is(err, errCircuitOpen) {
+		t.Fatalf("second call during the probe: err = %v, want circuit open", err)
+	}
+
+	// A failed probe opens the circuit again
+	probeDone(breakerFailure)
+	if err := callThrough(breakers, breakerSuccess); !errors.Is(err, errCircuitOpen) {
+		t.Fatalf("after a failed probe: err = %v, want circuit open", err)
+	}
+
+	// A successful one closes it
+	now = now.Add(31 * time.Second)
+	callThrough(breakers, breakerSuccess)
+	if state := breakers.states()["https://orders.run.app"]; state != circuitClosed {
+		t.Fatalf("state = %s after a successful probe, want closed", state)
+	}
+}
+
+func TestCircuitIgnoresClientErrors(t *testing.T) {
+	now := time.Now()
+	breakers := newTestBreakers(1, 30*time.Second, &now)
+	// A 404 means the backend is up
+	callThrough(breakers, breakerResultFor(context.Background(), &statusError{StatusCode: http.StatusNotFound}))
+	if err := callThrough(breakers, breakerSuccess); err != nil {
+		t.Fatalf("circuit opened on a 4xx: %v", err)
+	}
+}
+
+func TestOrdersFailFastWhileCircuitOpen(t *testing.T) {
+	useTestStore(t)
+	setForTest(t, &outboundMaxRetries, 0)
+	setForTest(t, &outboundBreakers, newCircuitBreakers(2, time.Minute))
+	var calls atomic.Int32
+	useOrderService(t, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		http.Error(w, "down", http.StatusInternalServerError)
+	})
+	server := newTestServer(t)
+
+	for i := 0; i < 2; i++ {
+		doRequest(t, server, http.MethodGet, "/users/user-001/orders", "")
+	}
+	resp, body := doRequest(t, server, http.MethodGet, "/users/user-001/orders", "")
+	if resp.StatusCode != http.StatusServiceUnavailable || resp.Header.Get("Retry-After") == "" {
+		t.Fatalf("status = %d, Retry-After %q; want 503 with Retry-After: %s", resp.StatusCode, resp.Header.Get("Retry-After"), body)
+	}
+	if got := calls.Load(); got != 2 {
+		t.Errorf("backend called %d times, want 2 (the third call fails fast)", got)
+	}
+}
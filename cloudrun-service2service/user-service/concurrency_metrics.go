@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// metricsSampleInterval controls how often the in-flight and queue-depth
+// gauges are sampled and published (METRICS_SAMPLE_INTERVAL). Sampling on our
+// own schedule keeps gauge values independent of scrape or push timing.
+var metricsSampleInterval = envDuration("METRICS_SAMPLE_INTERVAL", 10*time.Second)
+
+// Live gauge values, updated on every request / outbound call. /metrics
+// exports the latest sample of them, not these directly (see metrics.go).
+var (
+	inFlightRequests   atomic.Int64
+	outboundQueueDepth atomic.Int64
+)
+
+// trackInFlight is a middleware that counts requests currently being served
+func trackInFlight(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		inFlightRequests.Add(1)
+		defer inFlightRequests.Add(-1)
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// sampleConcurrency publishes the current gauge values
+func sampleConcurrency() {
+	inFlightRequestsGauge.Set(float64(inFlightRequests.Load()))
+	outboundQueueDepthGauge.Set(float64(outboundQueueDepth.Load()))
+	concurrencySamplesTotal.Inc()
+}
+
+// runConcurrencySampler samples the gauges every interval until ctx is cancelled
+func runConcurrencySampler(ctx context.Context, interval time.Duration) {
+	sampleConcurrency()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			sampleConcurrency()
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestInFlightRequestsGauge(t *testing.T) {
+	var during float64
+	handler := trackInFlight(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sampleConcurrency()
+		during = scrapeMetric(t, "user_service_inflight_requests")
+	}))
+	sampleConcurrency()
+	before := scrapeMetric(t, "user_service_inflight_requests")
+
+	serveHandler(handler.ServeHTTP, http.MethodGet, "/users", "")
+	if during != before+1 {
+		t.Errorf("user_service_inflight_requests = %v while serving, want %v", during, before+1)
+	}
+	// Scrapes see the latest sample until the next one is taken
+	if got := scrapeMetric(t, "user_service_inflight_requests"); got != during {
+		t.Errorf("user_service_inflight_requests = %v before the next sample, want %v", got, during)
+	}
+	sampleConcurrency()
+	if after := scrapeMetric(t, "user_service_inflight_requests"); after != before {
+		t.Errorf("user_service_inflight_requests = %v after the request, want %v", after, before)
+	}
+}
+
+func TestOutboundQueueDepthGauge(t *testing.T) {
+	limiter := newBackendLimiter(func(string) int { return 1 }, false)
+	release, err := limiter.acquire(context.Background(), "backend")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		limiter.acquire(ctx, "backend")
+	}()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for outboundQueueDepth.Load() != 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("the queued call was never counted")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	sampleConcurrency()
+	if depth := scrapeMetric(t, "user_service_outbound_queue_depth"); depth != 1 {
+		t.Errorf("user_service_outbound_queue_depth = %v with a call queued, want 1", depth)
+	}
+
+	cancel()
+	<-done
+	release()
+	sampleConcurrency()
+	if depth := scrapeMetric(t, "user_service_outbound_queue_depth"); depth != 0 {
+		t.Errorf("user_service_outbound_queue_depth = %v once the queue emptied, want 0", depth)
+	}
+}
+
+func TestConcurrencySamplerInterval(t *testing.T) {
+	const runFor = 300 * time.Millisecond
+
+	for _, interval := range []time.Duration{20 * time.Millisecond, 100 * time.Millisecond} {
+		before := scrapeMetric(t, "user_service_concurrency_samples_total")
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		start := time.Now()
+		go func() {
+			defer close(done)
+			runConcurrencySampler(ctx, interval)
+		}()
+		time.Sleep(runFor)
+		cancel()
+		<-done
+		elapsed := time.Since(start)
+
+		// One sample straight away, then one per interval: never more, and
+		// at worst a few ticks dropped on a busy machine
+		samples := scrapeMetric(t, "user_service_concurrency_samples_total") - before
+		most := 1 + float64(elapsed/interval)
+		least := 1 + float64(runFor/interval)/2
+		if samples < least || samples > most {
+			t.Errorf("interval %v: %v samples published in %v, want %v to %v", interval, samples, elapsed, least, most)
+		}
+	}
+}
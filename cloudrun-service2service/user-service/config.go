@@ -0,0 +1,72 @@
+package main
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// envString returns the value of the environment variable key, or def if unset
+func envString(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+// envInt parses an integer environment variable, falling back to def if unset or invalid
+func envInt(key string, def int) int {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(v))
+	if err != nil {
+		logger.Warn("Invalid config value, using default", "key", key, "value", v, "default", def)
+		return def
+	}
+	return n
+}
+
+// envBool parses a boolean environment variable, falling back to def if unset or invalid
+func envBool(key string, def bool) bool {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	b, err := strconv.ParseBool(strings.TrimSpace(v))
+	if err != nil {
+		logger.Warn("Invalid config value, using default", "key", key, "value", v, "default", def)
+		return def
+	}
+	return b
+}
+
+// envDuration parses a duration environment variable (e.g. "30s"), falling back to def if unset or invalid
+func envDuration(key string, def time.Duration) time.Duration {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(strings.TrimSpace(v))
+	if err != nil || d < 0 {
+		logger.Warn("Invalid config value, using default", "key", key, "value", v, "default", def.String())
+		return def
+	}
+	return d
+}
+
+// envFloat parses a floating-point environment variable, falling back to def if unset or invalid
+func envFloat(key string, def float64) float64 {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+	if err != nil || f < 0 {
+		logger.Warn("Invalid config value, using default", "key", key, "value", v, "default", def)
+		return def
+	}
+	return f
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Config reload configuration.
+// Platforms that mount config as a file (a Secret Manager volume, for
+// example) can update it without a restart. When CONFIG_FILE is set, it is
+// polled every CONFIG_RELOAD_INTERVAL and the outbound settings it contains
+// are swapped in. The file uses KEY=VALUE lines; currently ORDER_SERVICE_URL
+// is the only reloadable key.
+var (
+	configFile           = os.Getenv("CONFIG_FILE")
+	configReloadInterval = envDuration("CONFIG_RELOAD_INTERVAL", 10*time.Second)
+)
+
+// orderServiceURL is the current Order Service URL (initially ORDER_SERVICE_URL).
+// Always access it through getOrderServiceURL/setOrderServiceURL.
+var orderServiceURL = struct {
+	sync.RWMutex
+	value string
+}{value: os.Getenv("ORDER_SERVICE_URL")}
+
+// getOrderServiceURL returns the Order Service URL currently in effect
+func getOrderServiceURL() string {
+	orderServiceURL.RLock()
+	defer orderServiceURL.RUnlock()
+	return orderServiceURL.value
+}
+
+// setOrderServiceURL swaps in a new Order Service URL and returns the previous one
+func setOrderServiceURL(value string) string {
+	orderServiceURL.Lock()
+	defer orderServiceURL.Unlock()
+	previous := orderServiceURL.value
+	orderServiceURL.value = value
+	return previous
+}
+
+// validateServiceURL checks that raw is an absolute http(s) URL
+func validateServiceURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("invalid URL %q: %v", raw, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("invalid URL %q: scheme must be http or https", raw)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("invalid URL %q: missing host", raw)
+	}
+	return nil
+}
+
+// parseConfigFile parses KEY=VALUE lines, ignoring blank lines and # comments
+func parseConfigFile(data []byte) map[string]string {
+	values := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		values[strings.TrimSpace(key)] = strings.Trim(strings.TrimSpace(value), `"'`)
+	}
+	return values
+}
+
+// applyConfig validates and applies reloadable settings from values
+func applyConfig(values map[string]string) {
+	newURL, ok := values["ORDER_SERVICE_URL"]
+	if !ok {
+		return
+	}
+	newURL = strings.TrimRight(newURL, "/")
+	if newURL == getOrderServiceURL() {
+		return
+	}
+	if err := validateServiceURL(newURL); err != nil {
+		logger.Warn("Config reload: keeping current ORDER_SERVICE_URL", "error", err)
+		return
+	}
+	previous := setOrderServiceURL(newURL)
+	logger.Info("Config reload: ORDER_SERVICE_URL changed", "previous", previous, "current", newURL)
+}
+
+// watchConfigFile polls path for changes until ctx is cancelled
+func watchConfigFile(ctx context.Context, path string, interval time.Duration) {
+	var lastModified time.Time
+	var lastSize int64 = -1
+
+	reload := func() {
+		info, err := os.Stat(path)
+		if err != nil {
+			logger.Warn("Config reload: cannot stat config file", "path", path, "error", err)
+			return
+		}
+		if info.ModTime().Equal(lastModified) && info.Size() == lastSize {
+			return
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			logger.Warn("Config reload: cannot read config file", "path", path, "error", err)
+			return
+		}
+		lastModified, lastSize = info.ModTime(), info.Size()
+		applyConfig(parseConfigFile(data))
+	}
+
+	reload()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			reload()
+		}
+	}
+}
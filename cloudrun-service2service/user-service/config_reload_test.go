@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// keepOrderServiceURL restores ORDER_SERVICE_URL when the test ends
+func keepOrderServiceURL(t *testing.T) {
+	previous := getOrderServiceURL()
+	t.Cleanup(func() { setOrderServiceURL(previous) })
+}
+
+func TestApplyConfig(t *testing.T) {
+	keepOrderServiceURL(t)
+	setOrderServiceURL("https://orders-a.run.app")
+
+	applyConfig(parseConfigFile([]byte("# rotated\nORDER_SERVICE_URL = \"https://orders-b.run.app/\"\nOTHER=1\n")))
+	if got := getOrderServiceURL(); got != "https://orders-b.run.app" {
+		t.Fatalf("ORDER_SERVICE_URL = %q, want https://orders-b.run.app", got)
+	}
+
+	for _, bad := range []string{"ORDER_SERVICE_URL=ftp://orders.run.app", "ORDER_SERVICE_URL=orders.run.app", "ORDER_SERVICE_URL=https://"} {
+		applyConfig(parseConfigFile([]byte(bad)))
+		if got := getOrderServiceURL(); got != "https://orders-b.run.app" {
+			t.Fatalf("%s replaced the URL with %q", bad, got)
+		}
+	}
+}
+
+func TestWatchConfigFileReroutesOrders(t *testing.T) {
+	useTestStore(t)
+	keepOrderServiceURL(t)
+	setForTest(t, &orderServiceMode, "live")
+	setForTest(t, &ordersCacheTTL, 0)
+	first := newBackend(t, func(w http.ResponseWriter, r *http.Request) { w.Write([]byte(testOrdersJSON("user-001"))) })
+	var reachedSecond atomic.Bool
+	second := newBackend(t, func(w http.ResponseWriter, r *http.Request) {
+		reachedSecond.Store(true)
+		w.Write([]byte(testOrdersJSON("user-001")))
+	})
+	useCachedIDToken(t, first.URL)
+	useCachedIDToken(t, second.URL)
+
+	path := filepath.Join(t.TempDir(), "config.env")
+	if err := os.WriteFile(path, []byte("ORDER_SERVICE_URL="+first.URL+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go watchConfigFile(ctx, path, 10*time.Millisecond)
+
+	waitFor := func(want string) {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Second)
+		for getOrderServiceURL() != want {
+			if time.Now().After(deadline) {
+				t.Fatalf("ORDER_SERVICE_URL = %q, want %q", getOrderServiceURL(), want)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+	waitFor(first.URL)
+
+	if err := os.WriteFile(path, []byte("# moved\nORDER_SERVICE_URL="+second.URL+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(second.URL)
+
+	rec := serveHandler(userByIDHandler, http.MethodGet, "/users/user-001/orders", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("orders after reload: status = %d, want 200: %s", rec.Code, rec.Body)
+	}
+	if !reachedSecond.Load() {
+		t.Error("orders weren't fetched from the reloaded ORDER_SERVICE_URL")
+	}
+}
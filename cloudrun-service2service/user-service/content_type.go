@@ -0,0 +1,39 @@
+package main
+
+import (
+	"mime"
+	"net/http"
+)
+
+// Request content types.
+// Endpoints that take a body require it to be declared as JSON, so a form or
+// text body gets an unambiguous 415 rather than a confusing invalid_json.
+// Parameters such as charset are ignored.
+
+// requestMediaType returns the request's Content-Type without parameters,
+// or "" if it has none
+func requestMediaType(r *http.Request) string {
+	contentType := r.Header.Get("Content-Type")
+	if contentType == "" {
+		return ""
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return contentType
+	}
+	return mediaType
+}
+
+// requireJSONContentType writes 415 and returns false unless the request's
+// Content-Type is application/json
+func requireJSONContentType(w http.ResponseWriter, r *http.Request) bool {
+	switch mediaType := requestMediaType(r); mediaType {
+	case "application/json":
+		return true
+	case "":
+		writeError(w, r, http.StatusUnsupportedMediaType, "content_type_required", "application/json")
+	default:
+		writeError(w, r, http.StatusUnsupportedMediaType, "unsupported_content_type", mediaType, "application/json")
+	}
+	return false
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// jsonWriteEndpoints are the endpoints that only take JSON bodies
+var jsonWriteEndpoints = []struct{ method, path, body string }{
+	{http.MethodPost, "/users", `{"id":"user-004","name":"Dave Jones","email":"dave@example.com","role":"viewer"}`},
+	{http.MethodPut, "/users/user-002", `{"name":"Robert Smith","email":"bob@example.com","role":"developer"}`},
+	{http.MethodPost, "/users/orders/batch", `{"user_ids":["user-001"]}`},
+}
+
+func TestWriteEndpointsRequireJSON(t *testing.T) {
+	store := useTestStore(t)
+	server := newTestServer(t)
+
+	for _, endpoint := range jsonWriteEndpoints {
+		for _, tc := range []struct{ contentType, wantCode string }{
+			{"", "content_type_required"},
+			{"text/plain", "unsupported_content_type"},
+			{"application/x-www-form-urlencoded", "unsupported_content_type"},
+			{"application/merge-patch+json", "unsupported_content_type"},
+		} {
+			var headers []string
+			if tc.contentType != "" {
+				headers = []string{"Content-Type", tc.contentType}
+			}
+			resp, body := doRequest(t, server, endpoint.method, endpoint.path, endpoint.body, headers...)
+			if resp.StatusCode != http.StatusUnsupportedMediaType || !strings.Contains(body, `"code":"`+tc.wantCode+`"`) {
+				t.Errorf("%s %s with Content-Type %q: status = %d, want 415 %s: %s", endpoint.method, endpoint.path, tc.contentType, resp.StatusCode, tc.wantCode, body)
+			}
+		}
+	}
+
+	// Nothing was written
+	if _, err := store.Get(context.Background(), "user-004"); err == nil {
+		t.Error("user-004 was created by a request with the wrong Content-Type")
+	}
+	if user, _ := store.Get(context.Background(), "user-002"); user.Name != "Bob Smith" {
+		t.Errorf("user-002 renamed to %q by a request with the wrong Content-Type", user.Name)
+	}
+}
+
+func TestWriteEndpointsAcceptJSONWithParameters(t *testing.T) {
+	useTestStore(t)
+	useOrderService(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(testOrdersJSON("user-001")))
+	})
+	server := newTestServer(t)
+
+	for _, endpoint := range jsonWriteEndpoints {
+		resp, body := doRequest(t, server, endpoint.method, endpoint.path, endpoint.body, "Content-Type", "application/json; charset=utf-8")
+		if resp.StatusCode >= 300 {
+			t.Errorf("%s %s: status = %d, want success: %s", endpoint.method, endpoint.path, resp.StatusCode, body)
+		}
+	}
+}
+
+func TestPatchRequiresContentType(t *testing.T) {
+	useTestStore(t)
+
+	resp, body := doRequest(t, newTestServer(t), http.MethodPatch, "/users/user-002", `{"name":"Robert Smith"}`)
+	if resp.StatusCode != http.StatusUnsupportedMediaType || !strings.Contains(body, `"code":"content_type_required"`) {
+		t.Fatalf("status = %d, want 415 content_type_required: %s", resp.StatusCode, body)
+	}
+	if resp.Header.Get("Accept-Patch") == "" {
+		t.Error("415 for PATCH has no Accept-Patch header")
+	}
+}
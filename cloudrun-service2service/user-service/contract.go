@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// The Order Service is written in Node.js, so nothing at compile time stops
+// its response shape from drifting away from what this service expects.
+// We validate its responses against an embedded schema (a small subset of
+// JSON Schema: type, required, properties, items and enum) to catch
+// cross-language contract drift early.
+
+// strictContract makes contract violations fail the request (502) instead of only logging a warning
+var strictContract = envBool("STRICT_CONTRACT", false)
+
+//go:embed contracts/orders-by-user.schema.json
+var ordersByUserSchemaJSON []byte
+
+// ordersByUserSchema is the expected shape of GET /orders/user/{userId}
+var ordersByUserSchema = mustParseSchema(ordersByUserSchemaJSON)
+
+// contractSchema is the subset of JSON Schema we validate against
+type contractSchema struct {
+	Type       string                     `json:"type"`
+	Required   []string                   `json:"required"`
+	Properties map[string]*contractSchema `json:"properties"`
+	Items      *contractSchema            `json:"items"`
+	Enum       []interface{}              `json:"enum"`
+}
+
+func mustParseSchema(data []byte) *contractSchema {
+	var schema contractSchema
+	if err := json.Unmarshal(data, &schema); err != nil {
+		panic(fmt.Sprintf("invalid embedded contract schema: %v", err))
+	}
+	return &schema
+}
+
+// validateContract checks a decoded JSON value against schema and returns
+// every violation found, each prefixed with its JSON path
+func validateContract(schema *contractSchema, value interface{}) []string {
+	var violations []string
+	schema.validate("$", value, &violations)
+	return violations
+}
+
+func (s *contractSchema) validate(path string, value interface{}, violations *[]string) {
+	if s.Type != "" && !matchesType(s.Type, value) {
+		*violations = append(*violations, fmt.Sprintf("%s: expected %s, got %s", path, s.Type, jsonTypeOf(value)))
+		return
+	}
+
+	if len(s.Enum) > 0 {
+		found := false
+		for _, allowed := range s.Enum {
+			if allowed == value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			*violations = append(*violations, fmt.Sprintf("%s: value %v is not one of %v", path, value, s.Enum))
+		}
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for _, field := range s.Required {
+			if _, ok := v[field]; !ok {
+				*violations = append(*violations, fmt.Sprintf("%s: missing required field %q", path, field))
+			}
+		}
+		// Walk properties in a stable order so error messages are deterministic
+		names := make([]string, 0, len(s.Properties))
+		for name := range s.Properties {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if field, ok := v[name]; ok {
+				s.Properties[name].validate(path+"."+name, field, violations)
+			}
+		}
+	case []interface{}:
+		if s.Items != nil {
+			for i, item := range v {
+				s.Items.validate(fmt.Sprintf("%s[%d]", path, i), item, violations)
+			}
+		}
+	}
+}
+
+// matchesType reports whether a value decoded by encoding/json has the given JSON Schema type
+func matchesType(schemaType string, value interface{}) bool {
+	switch schemaType {
+	case "integer":
+		n, ok := value.(float64)
+		return ok && n == math.Trunc(n)
+	case "number":
+		_, ok := value.(float64)
+		return ok
+	default:
+		return jsonTypeOf(value) == schemaType
+	}
+}
+
+// jsonTypeOf returns the JSON Schema type name of a value decoded by encoding/json
+func jsonTypeOf(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+// checkOrdersContract validates an Order Service response. It returns an
+// error only in strict mode; otherwise violations are logged as warnings.
+func checkOrdersContract(ctx context.Context, ordersResponse interface{}) error {
+	violations := validateContract(ordersByUserSchema, ordersResponse)
+	if len(violations) == 0 {
+		return nil
+	}
+
+	summary := strings.Join(violations, "; ")
+	if featureEnabled(ctx, "strict_contract", strictContract) {
+		return fmt.Errorf("Order Service response violates contract: %s", summary)
+	}
+	logger.WarnContext(ctx, "Order Service response violates contract", "violations", summary)
+	return nil
+}
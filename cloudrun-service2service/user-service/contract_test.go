@@ -0,0 +1,56 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestValidateContract(t *testing.T) {
+	var valid interface{}
+	if err := json.Unmarshal([]byte(testOrdersJSON("user-001")), &valid); err != nil {
+		t.Fatal(err)
+	}
+	if violations := validateContract(ordersByUserSchema, valid); len(violations) != 0 {
+		t.Fatalf("valid response has violations: %v", violations)
+	}
+
+	var drifted interface{}
+	json.Unmarshal([]byte(`{"service":"order-service","userId":"user-001","count":1.5,"orders":[{"id":7,"status":"lost"}]}`), &drifted)
+	got := strings.Join(validateContract(ordersByUserSchema, drifted), "\n")
+	for _, want := range []string{
+		"$.count: expected integer, got number",
+		`$.orders[0]: missing required field "userId"`,
+		"$.orders[0].id: expected string, got number",
+		"$.orders[0].status: value lost is not one of",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("violations don't include %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestOrdersContractViolation(t *testing.T) {
+	useTestStore(t)
+	useOrderService(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"service":"order-service","userId":"user-001","count":"1","orders":[]}`))
+	})
+
+	// Lenient by default: the response is passed on and the drift logged
+	logs := captureLogs(t, slog.LevelWarn)
+	rec := serveHandler(userByIDHandler, http.MethodGet, "/users/user-001/orders", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("lenient: status = %d, want 200", rec.Code)
+	}
+	if !strings.Contains(logs.String(), "violates contract") {
+		t.Errorf("lenient: violation wasn't logged:\n%s", logs)
+	}
+
+	setForTest(t, &strictContract, true)
+	rec = serveHandler(userByIDHandler, http.MethodGet, "/users/user-001/orders", "")
+	if rec.Code != http.StatusBadGateway {
+		t.Fatalf("strict: status = %d, want 502", rec.Code)
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORS configuration for browser clients on other origins.
+// CORS_ALLOWED_ORIGINS is a comma-separated allowlist such as
+// "https://app.example.com,https://admin.example.com", or "*" for any origin
+// (empty = CORS disabled). CORS_ALLOW_CREDENTIALS lets browsers send cookies
+// and Authorization headers; it can't be combined with "*".
+var (
+	corsAllowedOrigins   = parseCORSOrigins(os.Getenv("CORS_ALLOWED_ORIGINS"))
+	corsAllowCredentials = envBool("CORS_ALLOW_CREDENTIALS", false)
+	corsMaxAge           = envDuration("CORS_MAX_AGE", 10*time.Minute)
+)
+
+// corsAllowedMethods and corsAllowedHeaders are what preflight requests may ask for
+const (
+	corsAllowedMethods = "GET, HEAD, POST, PUT, PATCH, DELETE"
+	corsAllowedHeaders = "Authorization, Content-Type, Accept-Language, Idempotency-Key, X-Request-Id, X-Request-Timeout, If-Match, If-None-Match"
+)
+
+// corsExposedHeaders are the response headers browser scripts may read
+const corsExposedHeaders = "Content-Language, Content-Location, ETag, Link, Location, Retry-After, X-Request-Id, X-Signature, X-Total-Count, Idempotent-Replayed"
+
+// parseCORSOrigins parses the comma-separated allowlist. Origins are compared
+// case-insensitively and without a trailing slash.
+func parseCORSOrigins(raw string) map[string]bool {
+	origins := make(map[string]bool)
+	for _, origin := range strings.Split(raw, ",") {
+		origin = strings.ToLower(strings.TrimRight(strings.TrimSpace(origin), "/"))
+		if origin == "" {
+			continue
+		}
+		if origin != "*" && !strings.HasPrefix(origin, "http://") && !strings.HasPrefix(origin, "https://") {
+			logger.Warn("Ignoring invalid CORS origin", "value", origin)
+			continue
+		}
+		origins[origin] = true
+	}
+	return origins
+}
+
+// corsCredentialsAllowed reports whether credentialed requests are allowed.
+// Browsers reject "Access-Control-Allow-Origin: *" with credentials, so a
+// wildcard allowlist turns credentials off.
+func corsCredentialsAllowed() bool {
+	return corsAllowCredentials && !corsAllowedOrigins["*"]
+}
+
+// withCORS is a middleware that adds Access-Control-* headers for allowed
+// origins and answers preflight requests itself, before authentication, since
+// browsers don't send credentials on a preflight
+func withCORS(handler http.Handler) http.Handler {
+	if len(corsAllowedOrigins) == 0 {
+		return handler
+	}
+	if corsAllowCredentials && corsAllowedOrigins["*"] {
+		logger.Warn("CORS_ALLOW_CREDENTIALS can't be used with CORS_ALLOWED_ORIGINS=* - credentials disabled")
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		w.Header().Add("Vary", "Origin")
+
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+		allowed := origin != "" && (corsAllowedOrigins["*"] || corsAllowedOrigins[strings.ToLower(origin)])
+		if !allowed {
+			if preflight {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			// Not a cross-origin request we allow: serve it without CORS headers,
+			// so the browser withholds the response from the page
+			handler.ServeHTTP(w, r)
+			return
+		}
+
+		if corsAllowedOrigins["*"] && !corsCredentialsAllowed() {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+		} else {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+		}
+		if corsCredentialsAllowed() {
+			w.Header().Set("Access-Control-Allow-Credentials", "true")
+		}
+
+		if preflight {
+			w.Header().Add("Vary", "Access-Control-Request-Method")
+			w.Header().Add("Vary", "Access-Control-Request-Headers")
+			w.Header().Set("Access-Control-Allow-Methods", corsAllowedMethods)
+			w.Header().Set("Access-Control-Allow-Headers", corsAllowedHeaders)
+			w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(corsMaxAge/time.Second)))
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		w.Header().Set("Access-Control-Expose-Headers", corsExposedHeaders)
+		handler.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestCORSPreflightBeforeAuth(t *testing.T) {
+	useTestStore(t)
+	useAuth(t)
+	setForTest(t, &corsAllowedOrigins, parseCORSOrigins("https://app.example.com/, https://ADMIN.example.com"))
+	setForTest(t, &corsAllowCredentials, true)
+	server := newTestServer(t)
+
+	// Browsers send preflights without credentials, so they're answered before auth
+	resp, _ := doRequest(t, server, http.MethodOptions, "/users", "",
+		"Origin", "https://app.example.com", "Access-Control-Request-Method", "POST")
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("preflight: status = %d, want 204", resp.StatusCode)
+	}
+	for name, want := range map[string]string{
+		"Access-Control-Allow-Origin":      "https://app.example.com",
+		"Access-Control-Allow-Credentials": "true",
+		"Access-Control-Allow-Methods":     corsAllowedMethods,
+		"Access-Control-Max-Age":           "600",
+	} {
+		if got := resp.Header.Get(name); got != want {
+			t.Errorf("preflight %s = %q, want %q", name, got, want)
+		}
+	}
+
+	resp, _ = doRequest(t, server, http.MethodOptions, "/users", "",
+		"Origin", "https://evil.example.com", "Access-Control-Request-Method", "POST")
+	if resp.StatusCode != http.StatusForbidden || resp.Header.Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("preflight from an unlisted origin: status = %d, Allow-Origin %q; want 403 without it", resp.StatusCode, resp.Header.Get("Access-Control-Allow-Origin"))
+	}
+}
+
+func TestCORSActualRequests(t *testing.T) {
+	useTestStore(t)
+	setForTest(t, &corsAllowedOrigins, parseCORSOrigins("https://app.example.com"))
+	server := newTestServer(t)
+
+	resp, _ := doRequest(t, server, http.MethodGet, "/users/user-001", "", "Origin", "https://APP.example.com")
+	if got := resp.Header.Get("Access-Control-Allow-Origin"); got != "https://APP.example.com" {
+		t.Errorf("Allow-Origin = %q, want the request's origin", got)
+	}
+	if resp.Header.Get("Access-Control-Expose-Headers") == "" || resp.Header.Get("Vary") != "Origin" {
+		t.Errorf("Expose-Headers %q, Vary %q; want both set", resp.Header.Get("Access-Control-Expose-Headers"), resp.Header.Get("Vary"))
+	}
+
+	// Unlisted origins are served without CORS headers, so the browser withholds the response
+	resp, _ = doRequest(t, server, http.MethodGet, "/users/user-001", "", "Origin", "https://evil.example.com")
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("unlisted origin: status = %d, Allow-Origin %q; want 200 without it", resp.StatusCode, resp.Header.Get("Access-Control-Allow-Origin"))
+	}
+}
+
+func TestCORSWildcardDropsCredentials(t *testing.T) {
+	useTestStore(t)
+	setForTest(t, &corsAllowedOrigins, parseCORSOrigins("*"))
+	setForTest(t, &corsAllowCredentials, true)
+	server := newTestServer(t)
+
+	resp, _ := doRequest(t, server, http.MethodGet, "/users/user-001", "", "Origin", "https://anywhere.example.com")
+	if resp.Header.Get("Access-Control-Allow-Origin") != "*" || resp.Header.Get("Access-Control-Allow-Credentials") != "" {
+		t.Errorf("Allow-Origin %q, Allow-Credentials %q; want * without credentials",
+			resp.Header.Get("Access-Control-Allow-Origin"), resp.Header.Get("Access-Control-Allow-Credentials"))
+	}
+}
+
+func TestParseCORSOrigins(t *testing.T) {
+	origins := parseCORSOrigins("https://App.example.com/, app2.example.com, http://localhost:3000,")
+	if len(origins) != 2 || !origins["https://app.example.com"] || !origins["http://localhost:3000"] {
+		t.Fatalf("origins = %v", origins)
+	}
+}
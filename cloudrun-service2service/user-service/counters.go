@@ -0,0 +1,95 @@
+package main
+
+import (
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ALLOW_ADMIN_COUNTERS (or ENABLE_ADMIN) enables /admin/counters. Off by
+// default, since DELETE resets the counters for everyone; /stats and
+// /metrics stay available.
+var allowAdminCounters = envBool("ALLOW_ADMIN_COUNTERS", envBool("ENABLE_ADMIN", false))
+
+// demoCounter is a resettable count for /stats that also feeds its
+// Prometheus counter, which isn't reset
+type demoCounter struct {
+	atomic.Int64
+	metric prometheus.Counter
+}
+
+// Add adds n to the counter and its metric
+func (c *demoCounter) Add(n int64) {
+	c.Int64.Add(n)
+	c.metric.Add(float64(n))
+}
+
+// Demo counters, incremented by the handlers on success
+var (
+	usersCreatedTotal  = demoCounter{metric: usersCreatedMetric}
+	usersDeletedTotal  = demoCounter{metric: usersDeletedMetric}
+	usersEvictedTotal  = demoCounter{metric: usersEvictedMetric}
+	ordersFetchedTotal = demoCounter{metric: ordersFetchedMetric}
+)
+
+// countersResetAt is when the counters were last reset (unix nanoseconds)
+var countersResetAt atomic.Int64
+
+func init() {
+	countersResetAt.Store(time.Now().UnixNano())
+}
+
+// Counters is a snapshot of the demo counters
+type Counters struct {
+	UsersCreated  int64     `json:"users_created"`
+	UsersDeleted  int64     `json:"users_deleted"`
+	UsersEvicted  int64     `json:"users_evicted"`
+	OrdersFetched int64     `json:"orders_fetched"`
+	Since         time.Time `json:"since"`
+}
+
+// readCounters returns the current counter values
+func readCounters() Counters {
+	return Counters{
+		UsersCreated:  usersCreatedTotal.Load(),
+		UsersDeleted:  usersDeletedTotal.Load(),
+		UsersEvicted:  usersEvictedTotal.Load(),
+		OrdersFetched: ordersFetchedTotal.Load(),
+		Since:         time.Unix(0, countersResetAt.Load()).UTC(),
+	}
+}
+
+// resetCounters zeroes the counters and returns their values beforehand.
+// Each counter is swapped atomically, so no increment is lost or double counted.
+func resetCounters() Counters {
+	previous := Counters{Since: time.Unix(0, countersResetAt.Swap(time.Now().UnixNano())).UTC()}
+	previous.UsersCreated = usersCreatedTotal.Swap(0)
+	previous.UsersDeleted = usersDeletedTotal.Swap(0)
+	previous.UsersEvicted = usersEvictedTotal.Swap(0)
+	previous.OrdersFetched = ordersFetchedTotal.Swap(0)
+	return previous
+}
+
+// statsHandler handles GET /stats
+func statsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, r, http.MethodGet)
+		return
+	}
+	writeJSON(w, http.StatusOK, readCounters())
+}
+
+// countersHandler handles GET /admin/counters and DELETE /admin/counters,
+// which resets the counters and returns the values they had
+func countersHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, readCounters())
+	case http.MethodDelete:
+		writeJSON(w, http.StatusOK, resetCounters())
+	default:
+		methodNotAllowed(w, r, http.MethodGet, http.MethodDelete)
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strconv"
+	"testing"
+)
+
+// scrapeMetric returns the value /metrics reports for an unlabelled metric
+func scrapeMetric(t *testing.T, name string) float64 {
+	t.Helper()
+	rec := serveHandler(promhttpHandler, http.MethodGet, "/metrics", "")
+	match := regexp.MustCompile(`(?m)^` + name + ` (\S+)$`).FindStringSubmatch(rec.Body.String())
+	if match == nil {
+		t.Fatalf("%s not in /metrics", name)
+	}
+	value, err := strconv.ParseFloat(match[1], 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return value
+}
+
+func TestAdminCountersDisabledByDefault(t *testing.T) {
+	setForTest(t, &allowAdminCounters, false)
+	usersCreatedTotal.Add(1)
+
+	resp, _ := doRequest(t, newTestServer(t), http.MethodDelete, "/admin/counters", "")
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("status = %d, want 404", resp.StatusCode)
+	}
+	if usersCreatedTotal.Load() == 0 {
+		t.Fatal("counters were reset while the endpoint is disabled")
+	}
+}
+
+func TestAdminCountersReset(t *testing.T) {
+	setForTest(t, &allowAdminCounters, true)
+	resetCounters()
+	usersCreatedTotal.Add(2)
+	ordersFetchedTotal.Add(3)
+	scraped := scrapeMetric(t, "user_service_users_created_total")
+
+	rec := serveHandler(countersHandler, http.MethodDelete, "/admin/counters", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+	var previous Counters
+	if err := json.Unmarshal(rec.Body.Bytes(), &previous); err != nil {
+		t.Fatal(err)
+	}
+	if previous.UsersCreated != 2 || previous.OrdersFetched != 3 {
+		t.Errorf("reset returned %+v, want 2 created and 3 fetched", previous)
+	}
+	if current := readCounters(); current.UsersCreated != 0 || current.OrdersFetched != 0 {
+		t.Errorf("counters after reset = %+v, want zero", current)
+	}
+
+	// Prometheus counters only go up, whatever /admin/counters does
+	if got := scrapeMetric(t, "user_service_users_created_total"); got != scraped {
+		t.Errorf("user_service_users_created_total = %v after reset, want %v", got, scraped)
+	}
+}
+
+func TestDemoCountersExportedToPrometheus(t *testing.T) {
+	before := scrapeMetric(t, "user_service_orders_fetched_total")
+	ordersFetchedTotal.Add(4)
+	if got := scrapeMetric(t, "user_service_orders_fetched_total"); got != before+4 {
+		t.Errorf("user_service_orders_fetched_total = %v, want %v", got, before+4)
+	}
+}
+
+func TestAdminCountersMethodNotAllowed(t *testing.T) {
+	setForTest(t, &allowAdminCounters, true)
+
+	rec := serveHandler(countersHandler, http.MethodPost, "/admin/counters", "")
+	if rec.Code != http.StatusMethodNotAllowed || rec.Header().Get("Allow") == "" {
+		t.Fatalf("status = %d, Allow = %q; want 405 with Allow", rec.Code, rec.Header().Get("Allow"))
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Cursor pagination configuration.
+// Offset pages shift when users are created or deleted between requests, so
+// a client paging with offsets can skip or repeat users. Pages listed in
+// creation order (no ?sort=, or sort=created_at / -created_at) also carry
+// pagination.next_cursor: an opaque token naming the page's last user by its
+// created_at and ID. ?cursor= resumes right after that user, wherever it now
+// is in the list, so users created meanwhile are only ever seen at the end.
+//
+// Cursors are HMAC-signed with CURSOR_SIGNING_KEY so a modified one is
+// refused with 400 rather than silently resuming somewhere else. Without a
+// key one is generated at startup: cursors then only work on the instance
+// that issued them, so set the key when running more than one instance.
+var cursorSigningKey = cursorKey(os.Getenv("CURSOR_SIGNING_KEY"))
+
+// errCursorInvalid is returned for a cursor that wasn't issued by us
+var errCursorInvalid = errors.New("invalid cursor")
+
+func cursorKey(configured string) []byte {
+	if configured != "" {
+		return []byte(configured)
+	}
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		panic("cursor key: " + err.Error())
+	}
+	return key
+}
+
+// listCursor is the position a cursor resumes after
+type listCursor struct {
+	CreatedAt  time.Time `json:"c"`
+	ID         string    `json:"i"`
+	Descending bool      `json:"d,omitempty"`
+}
+
+// encode returns the cursor as base64url(JSON) "." base64url(HMAC)
+func (c listCursor) encode() string {
+	payload, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(payload) + "." +
+		base64.RawURLEncoding.EncodeToString(cursorMAC(payload))
+}
+
+// parseCursor decodes and verifies a cursor from ?cursor=
+func parseCursor(raw string) (listCursor, error) {
+	encodedPayload, encodedMAC, ok := strings.Cut(raw, ".")
+	if !ok {
+		return listCursor{}, errCursorInvalid
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil {
+		return listCursor{}, errCursorInvalid
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(encodedMAC)
+	if err != nil || !hmac.Equal(mac, cursorMAC(payload)) {
+		return listCursor{}, errCursorInvalid
+	}
+	var c listCursor
+	if err := json.Unmarshal(payload, &c); err != nil || c.ID == "" {
+		return listCursor{}, errCursorInvalid
+	}
+	return c, nil
+}
+
+func cursorMAC(payload []byte) []byte {
+	mac := hmac.New(sha256.New, cursorSigningKey)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
+
+// cursorOrder reports whether a listing sorted by s is in creation order,
+// the only order cursors page in
+func cursorOrder(s UserSort) bool {
+	return s.Field == "" || s.Field == "created_at"
+}
+
+// parseListCursor reads ?cursor= for a listing sorted by order. It returns
+// nil without one, errCursorInvalid for a cursor we didn't issue, and an
+// invalid_parameter error for parameters a cursor can't be combined with.
+func parseListCursor(r *http.Request, order UserSort) (*listCursor, error) {
+	query := r.URL.Query()
+	raw := strings.TrimSpace(query.Get("cursor"))
+	if raw == "" {
+		return nil, nil
+	}
+	c, err := parseCursor(raw)
+	if err != nil {
+		return nil, err
+	}
+	if query.Get("offset") != "" {
+		return nil, fmt.Errorf("cursor can't be combined with offset")
+	}
+	if order.Field != "" && (!cursorOrder(order) || order.Descending != c.Descending) {
+		return nil, fmt.Errorf("cursor can't change the sort order it was issued for")
+	}
+	return &c, nil
+}
+
+// creationLess orders users by created_at, breaking ties by ID, so every
+// user has a distinct position a cursor can point at
+func creationLess(a, b User) bool {
+	if !a.CreatedAt.Equal(b.CreatedAt) {
+		return a.CreatedAt.Before(b.CreatedAt)
+	}
+	return a.ID < b.ID
+}
+
+// sortByCreation sorts list in place by creationLess, newest first if descending
+func sortByCreation(list []User, descending bool) {
+	sort.Slice(list, func(i, j int) bool {
+		if descending {
+			return creationLess(list[j], list[i])
+		}
+		return creationLess(list[i], list[j])
+	})
+}
+
+// follows reports whether user comes after the cursor's position in its order
+func (c listCursor) follows(user User) bool {
+	position := User{ID: c.ID, CreatedAt: c.CreatedAt}
+	if c.Descending {
+		return creationLess(user, position)
+	}
+	return creationLess(position, user)
+}
+
+// cursorAfter returns a cursor positioned after user in a listing sorted by s
+func cursorAfter(user User, s UserSort) string {
+	return listCursor{CreatedAt: user.CreatedAt, ID: user.ID, Descending: s.Descending}.encode()
+}
+
+// paginateAfter returns the page of list following c, with its pagination
+// metadata. list must be sorted by sortByCreation in the cursor's direction.
+// The cursor's user needn't still exist: the page starts at whoever now
+// follows it.
+func paginateAfter(list []User, limit int, c listCursor) ([]User, Pagination) {
+	start := sort.Search(len(list), func(i int) bool { return c.follows(list[i]) })
+	page := Pagination{
+		Total:  len(list),
+		Limit:  limit,
+		Offset: start,
+	}
+	end := start + limit
+	if end < len(list) {
+		page.NextCursor = cursorAfter(list[end-1], UserSort{Field: "created_at", Descending: c.Descending})
+	} else {
+		end = len(list)
+	}
+	return list[start:end], page
+}
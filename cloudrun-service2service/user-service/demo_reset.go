@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// ALLOW_DEMO_RESET (or ENABLE_ADMIN, as integration test setups set it)
+// enables POST /admin/reset. It's off by default since it throws away every
+// change made to the store.
+var allowDemoReset = envBool("ALLOW_DEMO_RESET", envBool("ENABLE_ADMIN", false))
+
+// ResetResponse represents the response for POST /admin/reset
+type ResetResponse struct {
+	Service  string `json:"service"`
+	Restored int    `json:"restored"`
+	Message  string `json:"message"`
+}
+
+// demoResetHandler handles POST /admin/reset, restoring the in-memory store to
+// the seeded demo users for tests and demos
+func demoResetHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, r, http.MethodPost)
+		return
+	}
+
+	if !requireTrustedAdmin(w, r) {
+		return
+	}
+
+	if !allowStoreMutation(w, r) {
+		return
+	}
+
+	store, ok := userStore.(*memoryStore)
+	if !ok {
+		writeErrorResponse(w, r, http.StatusNotImplemented, newErrorResponse("not_supported",
+			fmt.Sprintf("Demo reset is only supported by the memory store (STORE_BACKEND=%s)", storeBackend)))
+		return
+	}
+
+	// Migrated IDs no longer exist after the reset, so their aliases go too.
+	// idMigrationMu is taken before the store's lock, as in idMigrationHandler.
+	seed := seedUsers()
+	idMigrationMu.Lock()
+	removed := store.reset(seed)
+	clear(legacyIDAliases)
+	clear(legacyIDs)
+	idMigrationMu.Unlock()
+
+	for _, userID := range removed {
+		forgetAccess(userID)
+	}
+
+	restored := len(seed)
+	logger.InfoContext(r.Context(), "Store reset to demo data", "restored", restored)
+	writeJSON(w, http.StatusOK, ResetResponse{
+		Service:  "user-service (Go)",
+		Restored: restored,
+		Message:  fmt.Sprintf("Store reset to %d demo users", restored),
+	})
+}
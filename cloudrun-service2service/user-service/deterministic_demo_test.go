@@ -0,0 +1,47 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestDeterministicDemoSeedTimestamps(t *testing.T) {
+	setForTest(t, &deterministicDemo, true)
+
+	users := seedUsers()
+	if want := time.Date(2023, time.December, 2, 0, 0, 0, 0, time.UTC); !users[0].CreatedAt.Equal(want) {
+		t.Errorf("user-001 created_at = %s, want %s", users[0].CreatedAt, want)
+	}
+	again := seedUsers()
+	for i := range users {
+		if !users[i].CreatedAt.Equal(again[i].CreatedAt) {
+			t.Errorf("%s created_at changed between seedings", users[i].ID)
+		}
+	}
+}
+
+func TestDeterministicDemoResponsesRepeat(t *testing.T) {
+	setForTest(t, &deterministicDemo, true)
+
+	get := func() string {
+		useTestStore(t)
+		rec := serveHandler(userByIDHandler, http.MethodGet, "/users/user-002", "")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, want 200", rec.Code)
+		}
+		return rec.Body.String()
+	}
+	if first, second := get(), get(); first != second {
+		t.Errorf("responses differ between runs:\n%s\n%s", first, second)
+	}
+}
+
+func TestSeedTimestampsRelativeToNowByDefault(t *testing.T) {
+	setForTest(t, &deterministicDemo, false)
+
+	age := time.Since(seedUsers()[2].CreatedAt)
+	if age < 10*24*time.Hour-time.Minute || age > 10*24*time.Hour+time.Minute {
+		t.Errorf("user-003 is %s old, want 10 days", age)
+	}
+}
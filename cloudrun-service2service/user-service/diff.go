@@ -0,0 +1,34 @@
+package main
+
+import "net/http"
+
+// returnUpdateDiff includes a "changes" object in every update response
+// (RETURN_UPDATE_DIFF=true); clients can also ask per request with ?return=diff
+var returnUpdateDiff = envBool("RETURN_UPDATE_DIFF", false)
+
+// FieldChange is the old and new value of a changed field
+type FieldChange struct {
+	Old interface{} `json:"old"`
+	New interface{} `json:"new"`
+}
+
+// wantsDiff reports whether the update response should include changes
+func wantsDiff(r *http.Request) bool {
+	return featureEnabled(r.Context(), "update_diff", returnUpdateDiff) || r.URL.Query().Get("return") == "diff"
+}
+
+// diffUsers returns the user-editable fields that differ between before and
+// after, keyed by their JSON names. Unchanged fields are omitted.
+func diffUsers(before, after User) map[string]FieldChange {
+	changes := make(map[string]FieldChange)
+	if before.Name != after.Name {
+		changes["name"] = FieldChange{Old: before.Name, New: after.Name}
+	}
+	if before.Email != after.Email {
+		changes["email"] = FieldChange{Old: before.Email, New: after.Email}
+	}
+	if before.Role != after.Role {
+		changes["role"] = FieldChange{Old: before.Role, New: after.Role}
+	}
+	return changes
+}
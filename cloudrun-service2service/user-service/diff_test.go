@@ -0,0 +1,53 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+// patchUserForTest PATCHes path with a merge patch and decodes the response
+func patchUserForTest(t *testing.T, path, patch string) UsersResponse {
+	t.Helper()
+	rec := serveHandler(userByIDHandler, http.MethodPatch, path, patch, "Content-Type", "application/merge-patch+json")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("PATCH %s: status = %d, want 200: %s", path, rec.Code, rec.Body)
+	}
+	var resp UsersResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func TestUpdateDiffOnRequest(t *testing.T) {
+	useTestStore(t)
+	setForTest(t, &returnUpdateDiff, false)
+
+	if resp := patchUserForTest(t, "/users/user-002", `{"role":"viewer"}`); resp.Changes != nil {
+		t.Errorf("changes returned without ?return=diff: %v", resp.Changes)
+	}
+
+	resp := patchUserForTest(t, "/users/user-002?return=diff", `{"name":"Robert Smith","role":"viewer"}`)
+	if len(resp.Changes) != 1 {
+		t.Fatalf("changes = %v, want only name (role was already viewer)", resp.Changes)
+	}
+	if change := resp.Changes["name"]; change.Old != "Bob Smith" || change.New != "Robert Smith" {
+		t.Errorf("name change = %+v, want Bob Smith -> Robert Smith", change)
+	}
+}
+
+func TestUpdateDiffByDefault(t *testing.T) {
+	useTestStore(t)
+	setForTest(t, &returnUpdateDiff, true)
+
+	resp := patchUserForTest(t, "/users/user-003", `{"email":"carol.w@example.com"}`)
+	if change := resp.Changes["email"]; change.Old != "carol@example.com" || change.New != "carol.w@example.com" {
+		t.Errorf("changes = %v, want the email change", resp.Changes)
+	}
+
+	resp = patchUserForTest(t, "/users/user-003", `{"email":"carol.w@example.com"}`)
+	if len(resp.Changes) != 0 {
+		t.Errorf("no-op update changes = %v, want none", resp.Changes)
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ALLOW_DRAIN (or ENABLE_ADMIN) enables POST /admin/drain for blue-green
+// deploys: the old revision is drained - /readyz fails so the load balancer
+// stops sending it new requests, while the ones it's serving finish
+// normally - before it's sent SIGTERM. Off by default, since a drained
+// instance takes itself out of rotation.
+var allowDrain = envBool("ALLOW_DRAIN", envBool("ENABLE_ADMIN", false))
+
+// drainState records whether the instance is draining and since when
+var drainState struct {
+	sync.Mutex
+	since *time.Time
+}
+
+// DrainResponse represents the response for /admin/drain
+type DrainResponse struct {
+	Service  string     `json:"service"`
+	Draining bool       `json:"draining"`
+	Since    *time.Time `json:"since,omitempty"`
+	// InFlight is how many other requests the instance is serving
+	InFlight int64  `json:"in_flight"`
+	Message  string `json:"message"`
+}
+
+// startDraining puts the instance in the draining state, keeping the time it
+// was first drained, and stops server (if any) keeping connections alive. It
+// reports whether it wasn't draining already.
+func startDraining(server *http.Server) bool {
+	drainState.Lock()
+	defer drainState.Unlock()
+	if drainState.since != nil {
+		return false
+	}
+	now := time.Now().UTC()
+	drainState.since = &now
+	if server != nil {
+		// Clients on kept-alive connections would otherwise keep sending
+		// new requests here whatever the load balancer does
+		server.SetKeepAlivesEnabled(false)
+	}
+	return true
+}
+
+// stopDraining returns the instance to service
+func stopDraining(server *http.Server) {
+	drainState.Lock()
+	defer drainState.Unlock()
+	drainState.since = nil
+	if server != nil {
+		server.SetKeepAlivesEnabled(true)
+	}
+}
+
+// serverOf returns the server handling the request ctx belongs to, or nil
+func serverOf(ctx context.Context) *http.Server {
+	server, _ := ctx.Value(http.ServerContextKey).(*http.Server)
+	return server
+}
+
+// drainingSince returns when the instance started draining, or nil
+func drainingSince() *time.Time {
+	drainState.Lock()
+	defer drainState.Unlock()
+	return drainState.since
+}
+
+// drainHandler handles /admin/drain: POST starts draining, DELETE cancels it
+// (e.g. when a rollout is rolled back) and GET reports the state
+func drainHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodDelete:
+	default:
+		methodNotAllowed(w, r, http.MethodGet, http.MethodHead, http.MethodPost, http.MethodDelete)
+		return
+	}
+
+	// Draining takes the instance out of the load balancer
+	if !requireTrustedAdmin(w, r) {
+		return
+	}
+
+	status := http.StatusOK
+	var message string
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		message = "Serving traffic"
+		if drainingSince() != nil {
+			message = "Draining: /readyz returns 503 and in-flight requests are finishing"
+		}
+	case http.MethodPost:
+		message = "Already draining"
+		if startDraining(serverOf(r.Context())) {
+			logger.WarnContext(r.Context(), "Draining - /readyz now returns 503", "in_flight", inFlightRequests.Load()-1)
+			status = http.StatusAccepted
+			message = "Draining: /readyz returns 503 and in-flight requests are finishing"
+		}
+	case http.MethodDelete:
+		if drainingSince() != nil {
+			logger.InfoContext(r.Context(), "Draining cancelled - serving traffic again")
+		}
+		stopDraining(serverOf(r.Context()))
+		message = "Serving traffic"
+	}
+
+	since := drainingSince()
+	writeJSON(w, status, DrainResponse{
+		Service:  "user-service (Go)",
+		Draining: since != nil,
+		Since:    since,
+		InFlight: inFlightRequests.Load() - 1,
+		Message:  message,
+	})
+}
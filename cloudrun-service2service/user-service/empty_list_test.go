@@ -0,0 +1,40 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestEmptyListResponseModes(t *testing.T) {
+	useTestStore(t)
+	// No user has this role, so the listing is empty
+	const path = "/users?role=auditor"
+
+	for _, tc := range []struct {
+		mode       string
+		wantStatus int
+		// wantBody and notBody are substrings the body must and mustn't contain
+		wantBody, notBody string
+	}{
+		{"array", http.StatusOK, `"users":[]`, ""},
+		{"omit", http.StatusOK, `"pagination"`, `"users"`},
+		{"no_content", http.StatusNoContent, "", "{"},
+	} {
+		setForTest(t, &emptyListResponse, tc.mode)
+		rec := serveHandler(getAllUsers, http.MethodGet, path, "")
+		body := rec.Body.String()
+		if rec.Code != tc.wantStatus || !strings.Contains(body, tc.wantBody) || (tc.notBody != "" && strings.Contains(body, tc.notBody)) {
+			t.Errorf("EMPTY_LIST_RESPONSE=%s: status = %d, body %q", tc.mode, rec.Code, body)
+		}
+	}
+}
+
+func TestEmptyListResponseOnlyForEmptyLists(t *testing.T) {
+	useTestStore(t)
+	setForTest(t, &emptyListResponse, "no_content")
+
+	if resp := listUsersForTest(t, ""); resp.Count != 3 {
+		t.Fatalf("count = %d, want the 3 demo users", resp.Count)
+	}
+}
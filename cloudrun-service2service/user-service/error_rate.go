@@ -0,0 +1,102 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// Error rate configuration.
+// ERROR_RATE_THRESHOLD is the fraction of 5xx responses (e.g. 0.5) over the
+// last ERROR_RATE_WINDOW above which /readyz reports not ready (0 = disabled).
+// Nothing is reported until the window holds ERROR_RATE_MIN_REQUESTS requests,
+// so one failure on an idle instance doesn't take it out of rotation.
+var (
+	errorRateThreshold   = envFloat("ERROR_RATE_THRESHOLD", 0)
+	errorRateWindow      = envDuration("ERROR_RATE_WINDOW", time.Minute)
+	errorRateMinRequests = envInt("ERROR_RATE_MIN_REQUESTS", 20)
+)
+
+// ErrorRate is the rolling error rate reported in the readiness body
+type ErrorRate struct {
+	Rate          float64 `json:"rate"`
+	Requests      int64   `json:"requests"`
+	Errors        int64   `json:"errors"`
+	WindowSeconds float64 `json:"window_seconds"`
+	Threshold     float64 `json:"threshold"`
+	Exceeded      bool    `json:"exceeded"`
+}
+
+// errorRateBucket counts the requests completed during one second
+type errorRateBucket struct {
+	second   int64
+	requests int64
+	errors   int64
+}
+
+// requestOutcomes is a ring of per-second buckets covering errorRateWindow
+var requestOutcomes = newErrorRateTracker(errorRateWindow)
+
+type errorRateTracker struct {
+	mu      sync.Mutex
+	buckets []errorRateBucket
+}
+
+func newErrorRateTracker(window time.Duration) *errorRateTracker {
+	seconds := int(window / time.Second)
+	if seconds < 1 {
+		seconds = 1
+	}
+	return &errorRateTracker{buckets: make([]errorRateBucket, seconds)}
+}
+
+// record counts one completed request at now
+func (t *errorRateTracker) record(now time.Time, failed bool) {
+	second := now.Unix()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	bucket := &t.buckets[second%int64(len(t.buckets))]
+	if bucket.second != second {
+		*bucket = errorRateBucket{second: second}
+	}
+	bucket.requests++
+	if failed {
+		bucket.errors++
+	}
+}
+
+// rate sums the buckets still inside the window ending at now
+func (t *errorRateTracker) rate(now time.Time) ErrorRate {
+	oldest := now.Unix() - int64(len(t.buckets)) + 1
+	result := ErrorRate{
+		WindowSeconds: float64(len(t.buckets)),
+		Threshold:     errorRateThreshold,
+	}
+
+	t.mu.Lock()
+	for _, bucket := range t.buckets {
+		if bucket.second >= oldest {
+			result.Requests += bucket.requests
+			result.Errors += bucket.errors
+		}
+	}
+	t.mu.Unlock()
+
+	if result.Requests > 0 {
+		result.Rate = float64(result.Errors) / float64(result.Requests)
+	}
+	result.Exceeded = errorRateThreshold > 0 &&
+		result.Requests >= int64(errorRateMinRequests) &&
+		result.Rate > errorRateThreshold
+	return result
+}
+
+// recordRequestOutcome feeds a completed request into the rolling error rate.
+// Readiness probes are skipped so a failing probe can't keep itself failing.
+func recordRequestOutcome(path string, status int) {
+	if path == "/readyz" {
+		return
+	}
+	requestOutcomes.record(time.Now(), status >= 500)
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestErrorRateNeedsMinRequests(t *testing.T) {
+	setForTest(t, &errorRateThreshold, 0.5)
+	setForTest(t, &errorRateMinRequests, 4)
+	tracker := newErrorRateTracker(time.Minute)
+	now := time.Now()
+
+	for i := 0; i < 3; i++ {
+		tracker.record(now, true)
+	}
+	if rate := tracker.rate(now); rate.Exceeded {
+		t.Fatalf("rate = %+v, exceeded before ERROR_RATE_MIN_REQUESTS", rate)
+	}
+	tracker.record(now, false)
+	if rate := tracker.rate(now); !rate.Exceeded || rate.Rate != 0.75 {
+		t.Fatalf("rate = %+v, want 0.75 and exceeded", rate)
+	}
+}
+
+func TestErrorRateWindowExpires(t *testing.T) {
+	setForTest(t, &errorRateThreshold, 0.5)
+	setForTest(t, &errorRateMinRequests, 1)
+	tracker := newErrorRateTracker(10 * time.Second)
+	start := time.Now()
+
+	tracker.record(start, true)
+	tracker.record(start.Add(5*time.Second), false)
+	if rate := tracker.rate(start.Add(5 * time.Second)); rate.Requests != 2 || rate.Errors != 1 {
+		t.Fatalf("rate = %+v, want 2 requests and 1 error in the window", rate)
+	}
+	// The failure is now older than the window
+	if rate := tracker.rate(start.Add(12 * time.Second)); rate.Requests != 1 || rate.Errors != 0 || rate.Exceeded {
+		t.Fatalf("rate = %+v, want only the later success", rate)
+	}
+}
+
+func TestReadinessFailsOnErrorRate(t *testing.T) {
+	keepOrderServiceURL(t)
+	setOrderServiceURL("")
+	setForTest(t, &readinessCacheTTL, 0)
+	setForTest(t, &errorRateThreshold, 0.5)
+	setForTest(t, &errorRateMinRequests, 2)
+	setForTest(t, &requestOutcomes, newErrorRateTracker(time.Minute))
+
+	recordRequestOutcome("/users", http.StatusOK)
+	recordRequestOutcome("/users", http.StatusOK)
+	if rec := serveHandler(readyzHandler, http.MethodGet, "/readyz", ""); rec.Code != http.StatusOK {
+		t.Fatalf("status = %d with no errors, want 200: %s", rec.Code, rec.Body)
+	}
+
+	// Failed probes themselves don't count
+	for i := 0; i < 5; i++ {
+		recordRequestOutcome("/readyz", http.StatusServiceUnavailable)
+	}
+	for i := 0; i < 3; i++ {
+		recordRequestOutcome("/users/user-001/orders", http.StatusBadGateway)
+	}
+	rec := serveHandler(readyzHandler, http.MethodGet, "/readyz", "")
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d at a 60%% error rate, want 503: %s", rec.Code, rec.Body)
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestUpstreamErrorDetailHiddenInProduction(t *testing.T) {
+	const secret = "pq: connection to 10.0.0.5 refused"
+	useTestStore(t)
+	setForTest(t, &outboundMaxRetries, 0)
+	useOrderService(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, secret, http.StatusInternalServerError)
+	})
+
+	setForTest(t, &errorVerbosity, "production")
+	rec := serveHandler(userByIDHandler, http.MethodGet, "/users/user-001/orders", "")
+	if rec.Code < 500 {
+		t.Fatalf("status = %d, want a 5xx", rec.Code)
+	}
+	if strings.Contains(rec.Body.String(), "10.0.0.5") {
+		t.Errorf("upstream detail leaked to the client: %s", rec.Body)
+	}
+
+	setForTest(t, &errorVerbosity, "debug")
+	rec = serveHandler(userByIDHandler, http.MethodGet, "/users/user-001/orders", "")
+	if !strings.Contains(rec.Body.String(), "10.0.0.5") {
+		t.Errorf("ERROR_VERBOSITY=debug didn't include the detail: %s", rec.Body)
+	}
+}
+
+func TestMeshHealthErrorDetailHiddenInProduction(t *testing.T) {
+	setForTest(t, &meshHealthCacheTTL, 0)
+	setForTest(t, &errorVerbosity, "production")
+	useOrderService(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<html>nginx 1.2.3 at 10.0.0.5</html>`))
+	})
+
+	rec := serveHandler(meshHealthHandler, http.MethodGet, "/mesh/health", "")
+	if strings.Contains(rec.Body.String(), "10.0.0.5") {
+		t.Errorf("upstream body leaked into /mesh/health: %s", rec.Body)
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import "net/http"
+
+// Error model.
+// Every error response is an ErrorResponse whose code is stable and
+// language-independent, so clients branch on it rather than on the message
+// (the full set is listed in the README). Client errors use their message
+// catalog key as the code (user_not_found, validation_failed, ...); failures
+// on our side or a dependency's use the codes below. Field errors are also
+// listed in details, and request_id matches the request's log entries.
+
+// ErrorDetail describes one invalid field of a request
+type ErrorDetail struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// writeErrorResponse writes resp, tagged with the request's ID
+func writeErrorResponse(w http.ResponseWriter, r *http.Request, status int, resp ErrorResponse) {
+	if requestID := requestIDFromContext(r.Context()); requestID != "-" {
+		resp.RequestID = requestID
+	}
+	writeJSON(w, status, resp)
+}
+
+// newErrorResponse builds an ErrorResponse for a failure whose message isn't
+// localized. code is one of:
+//
+//   - invalid_parameter: a query parameter is malformed (400)
+//   - response_too_large: the response exceeds MAX_RESPONSE_BYTES (413)
+//   - internal_error: the request failed on our side (500)
+//   - not_supported: the store backend doesn't support the operation (501)
+//   - not_configured: a dependency the request needs isn't configured (503)
+//   - store_unavailable: the user store failed (503)
+//   - upstream_*: a dependency failed; see upstreamFailure
+func newErrorResponse(code, message string) ErrorResponse {
+	return ErrorResponse{Code: code, Error: message}
+}
+
+// upstreamFailure builds the ErrorResponse for a failed call to dependency,
+// coded by how it failed:
+//
+//   - upstream_unavailable: its circuit is open or it's at its concurrency limit
+//   - upstream_timeout: it didn't answer in time
+//   - upstream_bad_response: its response was unparseable or broke the contract
+//   - upstream_failed: it couldn't be reached
+//
+// An HTTP error from the dependency gets its code from ordersStatusFailure.
+func upstreamFailure(dependency, message string, err error) ErrorResponse {
+	upstream := newUpstreamError(dependency, err)
+	code := "upstream_failed"
+	switch upstream.Code {
+	case "circuit_open", "backend_busy":
+		code = "upstream_unavailable"
+	case "timeout":
+		code = "upstream_timeout"
+	case "bad_response":
+		code = "upstream_bad_response"
+	}
+	return ErrorResponse{Code: code, Error: message, Errors: []UpstreamError{upstream}}
+}
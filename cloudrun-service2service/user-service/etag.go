@@ -0,0 +1,105 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// errPreconditionFailed is returned by UserStore.Update when WriteOptions.IfMatch
+// doesn't match the stored user's current ETag
+var errPreconditionFailed = errors.New("user was modified since it was read")
+
+// userETag returns a strong ETag for the stored fields of user. Access
+// tracking isn't part of it, so reading a user doesn't change its ETag.
+// Timestamps are hashed at microsecond precision, the finest Firestore keeps,
+// so a user's ETag is the same whichever backend or read path produced it.
+func userETag(user User) string {
+	h := sha256.New()
+	for _, field := range []string{user.ID, user.Name, user.Email, user.Role, etagTime(&user.CreatedAt), etagTime(user.UpdatedAt)} {
+		h.Write([]byte(field))
+		h.Write([]byte{0})
+	}
+	return `"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`
+}
+
+func etagTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Truncate(time.Microsecond).Format(time.RFC3339Nano)
+}
+
+// etagListMatches reports whether header (an If-Match or If-None-Match value:
+// "*" or a comma-separated list of entity tags) matches etag. Weak comparison
+// ignores a W/ prefix, as If-None-Match requires; strong comparison (If-Match)
+// never matches a weak tag.
+func etagListMatches(header, etag string, weak bool) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+		if strings.HasPrefix(candidate, "W/") {
+			if !weak {
+				continue
+			}
+			candidate = candidate[2:]
+		}
+		if candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// listModificationTracker is implemented by stores that know when their users
+// last changed. The memory store does; Firestore would need a query per
+// request, so lists from it have no Last-Modified.
+type listModificationTracker interface {
+	LastModified() time.Time
+}
+
+// listNotModified sets Last-Modified on a GET or HEAD /users response and
+// writes a 304 if the request's If-Modified-Since shows the client already
+// has the current list. The header has only second precision, so while the
+// store has changed in the current second it's left off: another change in
+// the same second would otherwise go unnoticed by a client polling with it.
+// Call it before reading the list, so a change in between makes the
+// Last-Modified older than the list rather than newer.
+func listNotModified(w http.ResponseWriter, r *http.Request) bool {
+	tracker, ok := userStore.(listModificationTracker)
+	if !ok {
+		return false
+	}
+	lastModified := tracker.LastModified().UTC().Truncate(time.Second)
+	if !time.Now().Truncate(time.Second).After(lastModified) {
+		return false
+	}
+	w.Header().Set("Last-Modified", lastModified.Format(http.TimeFormat))
+
+	// If-None-Match takes precedence, and lists have no ETag to match
+	header := r.Header.Get("If-Modified-Since")
+	if header == "" || r.Header.Get("If-None-Match") != "" {
+		return false
+	}
+	since, err := http.ParseTime(header)
+	if err != nil || lastModified.After(since) {
+		return false
+	}
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// notModified writes a 304 if the request's If-None-Match matches etag
+func notModified(w http.ResponseWriter, r *http.Request, etag string) bool {
+	header := r.Header.Get("If-None-Match")
+	if header == "" || !etagListMatches(header, etag, true) {
+		return false
+	}
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// Feature overrides let QA flip feature flags for a single request with
+//
+//	X-Feature-Overrides: strict_contract=on, update_diff=off
+//
+// Only trusted callers may do this: those presenting ADMIN_TOKEN in
+// X-Admin-Token, or verified by REQUIRE_AUTH with the admin role. Overrides
+// from anyone else are ignored. Overrides live in the request context, so they never leak
+// into other requests.
+var adminToken = os.Getenv("ADMIN_TOKEN")
+
+// overridableFeatures are the flags that can be overridden per request
+var overridableFeatures = map[string]bool{
+	"strict_contract": true,
+	"unique_names":    true,
+	"update_diff":     true,
+	"warnings":        true,
+}
+
+// featureOverridesKey is the context key for per-request overrides
+type featureOverridesKey struct{}
+
+// featureEnabled returns the per-request override for name if one was
+// applied, otherwise the deployment-wide setting def
+func featureEnabled(ctx context.Context, name string, def bool) bool {
+	if overrides, ok := ctx.Value(featureOverridesKey{}).(map[string]bool); ok {
+		if enabled, ok := overrides[name]; ok {
+			return enabled
+		}
+	}
+	return def
+}
+
+// isTrustedCaller reports whether the request may override feature flags or
+// use other admin-only conveniences
+func isTrustedCaller(r *http.Request) bool {
+	if token := r.Header.Get("X-Admin-Token"); adminToken != "" && token != "" &&
+		subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) == 1 {
+		return true
+	}
+	// A role is only trusted from a token authenticate verified
+	return callerFromContext(r.Context()) != nil && callerRoleFromContext(r.Context()) == "admin"
+}
+
+// withFeatureOverrides is a middleware that applies X-Feature-Overrides for trusted callers
+func withFeatureOverrides(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raw := r.Header.Get("X-Feature-Overrides")
+		if raw == "" {
+			handler.ServeHTTP(w, r)
+			return
+		}
+
+		if !isTrustedCaller(r) {
+			logger.DebugContext(r.Context(), "Ignoring X-Feature-Overrides from untrusted caller")
+			handler.ServeHTTP(w, r)
+			return
+		}
+
+		overrides := parseFeatureOverrides(raw)
+		if len(overrides) > 0 {
+			logger.InfoContext(r.Context(), "Applying feature overrides for this request", "overrides", overrides)
+			r = r.WithContext(context.WithValue(r.Context(), featureOverridesKey{}, overrides))
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// parseFeatureOverrides parses "name=on|off" pairs separated by commas
+func parseFeatureOverrides(raw string) map[string]bool {
+	overrides := make(map[string]bool)
+	for _, pair := range strings.Split(raw, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !ok || !overridableFeatures[name] {
+			continue
+		}
+		switch strings.ToLower(strings.TrimSpace(value)) {
+		case "on", "true", "1":
+			overrides[name] = true
+		case "off", "false", "0":
+			overrides[name] = false
+		}
+	}
+	return overrides
+}
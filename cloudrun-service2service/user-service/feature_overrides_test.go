@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+// patchWithOverrides PATCHes user-002 through the full middleware chain with
+// update_diff overridden on, and reports whether the response carried a diff
+func patchWithOverrides(t *testing.T, headers ...string) bool {
+	t.Helper()
+	server := newTestServer(t)
+	headers = append(headers, "Content-Type", "application/merge-patch+json", "X-Feature-Overrides", "update_diff=on")
+	resp, body := doRequest(t, server, http.MethodPatch, "/users/user-002", `{"name":"Robert Smith"}`, headers...)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", resp.StatusCode, body)
+	}
+	var users UsersResponse
+	if err := json.Unmarshal([]byte(body), &users); err != nil {
+		t.Fatal(err)
+	}
+	return len(users.Changes) > 0
+}
+
+func TestFeatureOverridesFromTrustedCallers(t *testing.T) {
+	setForTest(t, &returnUpdateDiff, false)
+	setForTest(t, &adminToken, "test-admin-token")
+
+	t.Run("admin token", func(t *testing.T) {
+		useTestStore(t)
+		if !patchWithOverrides(t, "X-Admin-Token", "test-admin-token") {
+			t.Error("override from ADMIN_TOKEN holder was ignored")
+		}
+	})
+
+	t.Run("verified admin", func(t *testing.T) {
+		useTestStore(t)
+		useAuth(t)
+		if !patchWithOverrides(t, "Authorization", bearer(map[string]interface{}{"email": "alice@example.com"})) {
+			t.Error("override from verified admin was ignored")
+		}
+	})
+}
+
+func TestFeatureOverridesIgnoredFromUntrustedCallers(t *testing.T) {
+	setForTest(t, &returnUpdateDiff, false)
+	setForTest(t, &adminToken, "test-admin-token")
+
+	t.Run("wrong admin token", func(t *testing.T) {
+		useTestStore(t)
+		if patchWithOverrides(t, "X-Admin-Token", "guess") {
+			t.Error("override applied with the wrong admin token")
+		}
+	})
+
+	t.Run("verified non-admin", func(t *testing.T) {
+		useTestStore(t)
+		useAuth(t)
+		if patchWithOverrides(t, "Authorization", bearer(map[string]interface{}{"email": "carol@example.com"})) {
+			t.Error("override applied for a viewer")
+		}
+	})
+
+	t.Run("unverified admin claims", func(t *testing.T) {
+		useTestStore(t)
+		setForTest(t, &requireAuth, false)
+		// Without REQUIRE_AUTH nothing checks the token, so its claims grant nothing
+		if patchWithOverrides(t, "Authorization", bearer(map[string]interface{}{"email": "alice@example.com"})) {
+			t.Error("override applied for an unverified token")
+		}
+	})
+
+	t.Run("no ADMIN_TOKEN configured", func(t *testing.T) {
+		useTestStore(t)
+		setForTest(t, &adminToken, "")
+		if patchWithOverrides(t, "X-Admin-Token", "") {
+			t.Error("override applied with ADMIN_TOKEN unset")
+		}
+	})
+}
+
+func TestParseFeatureOverrides(t *testing.T) {
+	overrides := parseFeatureOverrides(" Strict_Contract=ON, update_diff=0, warnings=maybe, debug=on, broken")
+	if len(overrides) != 2 || !overrides["strict_contract"] || overrides["update_diff"] {
+		t.Fatalf("overrides = %v, want strict_contract on and update_diff off only", overrides)
+	}
+}
@@ -0,0 +1,293 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Firestore configuration (STORE_BACKEND=firestore).
+// FIRESTORE_PROJECT_ID defaults to the project the service runs in.
+var (
+	firestoreProjectID  = envString("FIRESTORE_PROJECT_ID", firestore.DetectProjectID)
+	firestoreCollection = envString("FIRESTORE_COLLECTION", "users")
+)
+
+// firestoreStore keeps each user as a document in a Firestore collection,
+// keyed by user ID. Writes run in transactions so uniqueness checks and the
+// write commit together. New users always get UUIDs.
+type firestoreStore struct {
+	client     *firestore.Client
+	collection string
+}
+
+// firestoreUser is the document shape. The lowercased fields back the
+// case-insensitive uniqueness queries, since Firestore can't compare case-insensitively.
+type firestoreUser struct {
+	Name       string     `firestore:"name"`
+	Email      string     `firestore:"email"`
+	Role       string     `firestore:"role"`
+	CreatedAt  time.Time  `firestore:"created_at"`
+	UpdatedAt  *time.Time `firestore:"updated_at,omitempty"`
+	Deleted    bool       `firestore:"deleted,omitempty"`
+	DeletedAt  *time.Time `firestore:"deleted_at,omitempty"`
+	NameLower  string     `firestore:"name_lower"`
+	EmailLower string     `firestore:"email_lower"`
+}
+
+func newFirestoreStore(ctx context.Context, projectID, collection string) (*firestoreStore, error) {
+	client, err := firestore.NewClient(ctx, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Firestore client: %v", err)
+	}
+	return &firestoreStore{client: client, collection: collection}, nil
+}
+
+// Close releases the Firestore client's connections
+func (s *firestoreStore) Close() error {
+	return s.client.Close()
+}
+
+func (s *firestoreStore) List(ctx context.Context) ([]User, error) {
+	docs, err := s.client.Collection(s.collection).OrderBy("created_at", firestore.Asc).Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+	list := make([]User, 0, len(docs))
+	for _, doc := range docs {
+		user, err := userFromDoc(doc)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, user)
+	}
+	return list, nil
+}
+
+func (s *firestoreStore) Get(ctx context.Context, id string) (User, error) {
+	ref := s.doc(id)
+	if ref == nil {
+		return User{}, errUserNotFound
+	}
+	doc, err := ref.Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return User{}, errUserNotFound
+	}
+	if err != nil {
+		return User{}, err
+	}
+	return userFromDoc(doc)
+}
+
+func (s *firestoreStore) Create(ctx context.Context, user User, opts WriteOptions) (User, error) {
+	if user.ID == "" {
+		id, err := newUUID()
+		if err != nil {
+			return User{}, fmt.Errorf("failed to generate user ID: %v", err)
+		}
+		user.ID = id
+	}
+	ref := s.doc(user.ID)
+	if ref == nil {
+		return User{}, fmt.Errorf("invalid document ID %q", user.ID)
+	}
+	user.CreatedAt = time.Now().UTC()
+	user.UpdatedAt = nil
+	user.LastAccessedAt = nil
+	user.Deleted, user.DeletedAt = false, nil
+
+	err := s.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		if err := s.checkUnique(tx, user.Name, user.Email, "", opts); err != nil {
+			return err
+		}
+		return tx.Create(ref, docFromUser(user))
+	})
+	if status.Code(err) == codes.AlreadyExists {
+		return User{}, errUserExists
+	}
+	if err != nil {
+		return User{}, err
+	}
+	return user, nil
+}
+
+func (s *firestoreStore) Update(ctx context.Context, id string, patch UserPatch, opts WriteOptions) (User, User, error) {
+	ref := s.doc(id)
+	if ref == nil {
+		return User{}, User{}, errUserNotFound
+	}
+
+	var before, updated User
+	err := s.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(ref)
+		if status.Code(err) == codes.NotFound {
+			return errUserNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if before, err = userFromDoc(doc); err != nil {
+			return err
+		}
+		if before.Deleted {
+			return errUserNotFound
+		}
+		if opts.IfMatch != "" && !etagListMatches(opts.IfMatch, userETag(before), false) {
+			return errPreconditionFailed
+		}
+
+		// Only the fields being changed need re-checking
+		updated = applyPatch(before, patch, time.Now().UTC())
+		name, email := "", ""
+		if patch.Name != nil {
+			name = updated.Name
+		}
+		if patch.Email != nil {
+			email = updated.Email
+		}
+		if err := s.checkUnique(tx, name, email, id, opts); err != nil {
+			return err
+		}
+		return tx.Set(ref, docFromUser(updated))
+	})
+	if err != nil {
+		return User{}, User{}, err
+	}
+	return before, updated, nil
+}
+
+func (s *firestoreStore) Delete(ctx context.Context, id string) error {
+	ref := s.doc(id)
+	if ref == nil {
+		return errUserNotFound
+	}
+	_, err := ref.Delete(ctx, firestore.Exists)
+	if status.Code(err) == codes.NotFound {
+		return errUserNotFound
+	}
+	return err
+}
+
+func (s *firestoreStore) SetDeleted(ctx context.Context, id string, deleted bool) (User, error) {
+	ref := s.doc(id)
+	if ref == nil {
+		return User{}, errUserNotFound
+	}
+
+	var updated User
+	err := s.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(ref)
+		if status.Code(err) == codes.NotFound {
+			return errUserNotFound
+		}
+		if err != nil {
+			return err
+		}
+		current, err := userFromDoc(doc)
+		if err != nil {
+			return err
+		}
+		switch {
+		case deleted && current.Deleted:
+			return errUserNotFound
+		case !deleted && !current.Deleted:
+			return errUserNotDeleted
+		}
+		updated = setDeletedState(current, deleted, time.Now().UTC())
+		return tx.Set(ref, docFromUser(updated))
+	})
+	if err != nil {
+		return User{}, err
+	}
+	return updated, nil
+}
+
+// doc returns the document for a user ID, or nil if the ID can't name a
+// document (e.g. it contains a slash)
+func (s *firestoreStore) doc(id string) *firestore.DocumentRef {
+	if id == "" || id == "." || id == ".." || strings.Contains(id, "/") {
+		return nil
+	}
+	return s.client.Collection(s.collection).Doc(id)
+}
+
+// checkUnique returns errEmailTaken or errNameTaken if another document
+// (other than excludeID) has the email, or the name when opts.UniqueNames is
+// set. Empty values skip their check. Reading through tx makes
+// Firestore abort the transaction if a conflicting user is written concurrently.
+func (s *firestoreStore) checkUnique(tx *firestore.Transaction, name, email, excludeID string, opts WriteOptions) error {
+	users := s.client.Collection(s.collection)
+	if email != "" {
+		taken, err := s.exists(tx, users.Where("email_lower", "==", strings.ToLower(email)), excludeID)
+		if err != nil {
+			return err
+		}
+		if taken {
+			return errEmailTaken
+		}
+	}
+	if opts.UniqueNames && name != "" {
+		query := users.Where("name", "==", name)
+		if uniqueNamesIgnoreCase {
+			query = users.Where("name_lower", "==", strings.ToLower(name))
+		}
+		taken, err := s.exists(tx, query, excludeID)
+		if err != nil {
+			return err
+		}
+		if taken {
+			return errNameTaken
+		}
+	}
+	return nil
+}
+
+// exists reports whether query matches any document other than excludeID
+func (s *firestoreStore) exists(tx *firestore.Transaction, query firestore.Query, excludeID string) (bool, error) {
+	docs, err := tx.Documents(query.Limit(2)).GetAll()
+	if err != nil {
+		return false, err
+	}
+	for _, doc := range docs {
+		if doc.Ref.ID != excludeID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func userFromDoc(doc *firestore.DocumentSnapshot) (User, error) {
+	var stored firestoreUser
+	if err := doc.DataTo(&stored); err != nil {
+		return User{}, fmt.Errorf("failed to decode user %s: %v", doc.Ref.ID, err)
+	}
+	return User{
+		ID:        doc.Ref.ID,
+		Name:      stored.Name,
+		Email:     stored.Email,
+		Role:      stored.Role,
+		CreatedAt: stored.CreatedAt.UTC(),
+		UpdatedAt: utcTime(stored.UpdatedAt),
+		Deleted:   stored.Deleted,
+		DeletedAt: utcTime(stored.DeletedAt),
+	}, nil
+}
+
+func docFromUser(user User) firestoreUser {
+	return firestoreUser{
+		Name:       user.Name,
+		Email:      user.Email,
+		Role:       user.Role,
+		CreatedAt:  user.CreatedAt,
+		UpdatedAt:  user.UpdatedAt,
+		Deleted:    user.Deleted,
+		DeletedAt:  user.DeletedAt,
+		NameLower:  strings.ToLower(user.Name),
+		EmailLower: strings.ToLower(user.Email),
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestListUsersGroupedByRole(t *testing.T) {
+	useTestStore(t)
+	createUserForTest(t, `{"name":"Dan Brown","email":"dan@example.com","role":"developer"}`, http.StatusCreated)
+
+	rec := serveHandler(getAllUsers, http.MethodGet, "/users?group_by=role&sort=name", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body)
+	}
+	var resp UserGroupsResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Count != 4 || len(resp.Groups) != 3 {
+		t.Fatalf("count = %d, groups %v; want 4 users in 3 groups", resp.Count, resp.Groups)
+	}
+	// Within a group, users keep the listing's order
+	if got := userIDs(resp.Groups["developer"]); len(got) != 2 || got[0] != "user-002" {
+		t.Errorf("developer group = %v, want Bob Smith then Dan Brown", got)
+	}
+
+	// Filters apply before grouping
+	rec = serveHandler(getAllUsers, http.MethodGet, "/users?group_by=role&role=viewer", "")
+	resp = UserGroupsResponse{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || resp.Count != 1 || len(resp.Groups) != 1 {
+		t.Fatalf("filtered groups = %s, want only the viewer group", rec.Body)
+	}
+}
+
+func TestListUsersGroupByInvalid(t *testing.T) {
+	useTestStore(t)
+	for _, query := range []string{"group_by=email", "group_by=role&format=ndjson"} {
+		if rec := serveHandler(getAllUsers, http.MethodGet, "/users?"+query, ""); rec.Code != http.StatusBadRequest {
+			t.Errorf("?%s: status = %d, want 400: %s", query, rec.Code, rec.Body)
+		}
+	}
+}
+
+func TestGroupUsersByRoleWithoutRole(t *testing.T) {
+	groups := groupUsersByRole([]User{{ID: "a", Role: "admin"}, {ID: "b"}})
+	if len(groups["admin"]) != 1 || len(groups[noRoleGroup]) != 1 || groups[noRoleGroup][0].ID != "b" {
+		t.Fatalf("groups = %v, want users without a role under %q", groups, noRoleGroup)
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"time"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// HTTP/2 configuration.
+// Cloud Run can forward requests end-to-end over HTTP/2 (the service's "Use
+// HTTP/2 end-to-end" setting), but it does so in cleartext - TLS ends at the
+// front end - and Go's server only speaks HTTP/2 over TLS. ENABLE_H2C=true
+// serves HTTP/2 cleartext (h2c) alongside HTTP/1.1 on the same port.
+// Outbound calls negotiate HTTP/2 over TLS already; OUTBOUND_H2C=true also
+// uses it (with prior knowledge) for plain http:// backends, such as an Order
+// Service reached over a VPC or running locally with h2c on.
+var (
+	enableH2C   = envBool("ENABLE_H2C", false)
+	outboundH2C = envBool("OUTBOUND_H2C", false)
+)
+
+// withH2C lets handler serve h2c requests as well as HTTP/1.1 when
+// ENABLE_H2C is on. HTTP/2 connections close after SERVER_IDLE_TIMEOUT idle,
+// like HTTP/1.1 keep-alives.
+func withH2C(handler http.Handler) http.Handler {
+	if !enableH2C {
+		return handler
+	}
+	return h2c.NewHandler(handler, &http2.Server{IdleTimeout: serverIdleTimeout})
+}
+
+// h2cRoundTripper sends http:// requests over HTTP/2 cleartext and everything
+// else through the regular transport
+type h2cRoundTripper struct {
+	h2c  http.RoundTripper
+	base http.RoundTripper
+}
+
+func (t *h2cRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Scheme == "http" {
+		return t.h2c.RoundTrip(req)
+	}
+	return t.base.RoundTrip(req)
+}
+
+// CloseIdleConnections closes idle connections on both transports
+func (t *h2cRoundTripper) CloseIdleConnections() {
+	for _, rt := range []http.RoundTripper{t.h2c, t.base} {
+		if closer, ok := rt.(interface{ CloseIdleConnections() }); ok {
+			closer.CloseIdleConnections()
+		}
+	}
+}
+
+// withOutboundH2C returns base, wrapped to call http:// backends over h2c
+// when OUTBOUND_H2C is on. One h2c connection per backend carries every
+// concurrent call, so the idle pool settings don't apply to it.
+func withOutboundH2C(base *http.Transport) http.RoundTripper {
+	if !outboundH2C {
+		return base
+	}
+	dialer := &net.Dialer{Timeout: 10 * time.Second, KeepAlive: 30 * time.Second}
+	return &h2cRoundTripper{
+		h2c: &http2.Transport{
+			AllowHTTP: true,
+			DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+				return dialer.DialContext(ctx, network, addr)
+			},
+		},
+		base: base,
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"golang.org/x/net/http2"
+)
+
+// h2cClient speaks HTTP/2 cleartext with prior knowledge, as Cloud Run does
+// when forwarding HTTP/2 end-to-end
+var h2cClient = &http.Client{Transport: &http2.Transport{
+	AllowHTTP: true,
+	DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+		return (&net.Dialer{}).DialContext(ctx, network, addr)
+	},
+}}
+
+func TestH2CServed(t *testing.T) {
+	useTestStore(t)
+	setForTest(t, &enableH2C, true)
+	server := newTestServer(t)
+
+	resp, err := h2cClient.Get(server.URL + "/users/user-001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.ProtoMajor != 2 {
+		t.Errorf("h2c: status = %d over %s, want 200 over HTTP/2", resp.StatusCode, resp.Proto)
+	}
+
+	// HTTP/1.1 is still served on the same port
+	if resp, _ := doRequest(t, server, http.MethodGet, "/users/user-001", ""); resp.StatusCode != http.StatusOK || resp.ProtoMajor != 1 {
+		t.Errorf("HTTP/1.1: status = %d over %s, want 200 over HTTP/1.1", resp.StatusCode, resp.Proto)
+	}
+}
+
+func TestH2COffByDefault(t *testing.T) {
+	useTestStore(t)
+	setForTest(t, &enableH2C, false)
+	server := newTestServer(t)
+
+	if resp, err := h2cClient.Get(server.URL + "/users/user-001"); err == nil {
+		resp.Body.Close()
+		t.Errorf("h2c request got %s %d without ENABLE_H2C", resp.Proto, resp.StatusCode)
+	}
+}
+
+func TestOutboundH2C(t *testing.T) {
+	setForTest(t, &outboundH2C, true)
+	setForTest(t, &enableH2C, true)
+	backendProto := make(chan string, 1)
+	backend := newBackend(t, withH2C(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		backendProto <- r.Proto
+	})).ServeHTTP)
+
+	client := &http.Client{Transport: withOutboundH2C(&http.Transport{})}
+	resp, err := client.Get(backend.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if proto := <-backendProto; proto != "HTTP/2.0" {
+		t.Errorf("http:// backend reached over %s, want HTTP/2.0", proto)
+	}
+
+	// https:// backends go through the regular transport
+	tlsBackend := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	t.Cleanup(tlsBackend.Close)
+	base := tlsBackend.Client().Transport.(*http.Transport).Clone()
+	client = &http.Client{Transport: withOutboundH2C(base)}
+	resp, err = client.Get(tlsBackend.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("https:// backend: status = %d, want 200", resp.StatusCode)
+	}
+}
+
+func TestOutboundH2COffByDefault(t *testing.T) {
+	setForTest(t, &outboundH2C, false)
+	base := &http.Transport{}
+	if rt := withOutboundH2C(base); rt != base {
+		t.Errorf("withOutboundH2C wrapped the transport without OUTBOUND_H2C: %T", rt)
+	}
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+)
+
+// healthFormat selects the health/readiness response body (HEALTH_FORMAT):
+// "json" (default) or "text" for monitors that expect a bare "OK"
+var healthFormat = strings.ToLower(envString("HEALTH_FORMAT", "json"))
+
+// writeHealth writes a health or readiness response in the configured format
+func writeHealth(w http.ResponseWriter, status int, body interface{}) {
+	if healthFormat != "text" {
+		writeJSON(w, status, body)
+		return
+	}
+
+	text := "OK"
+	if status != http.StatusOK {
+		text = "UNAVAILABLE"
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(status)
+	if _, err := w.Write([]byte(text + "\n")); err != nil {
+		logger.Error("Error writing health response", "error", err)
+	}
+}
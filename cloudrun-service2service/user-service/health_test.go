@@ -0,0 +1,38 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestHealthJSONByDefault(t *testing.T) {
+	setForTest(t, &healthFormat, "json")
+
+	rec := serveHandler(healthHandler, http.MethodGet, "/health", "")
+	if rec.Code != http.StatusOK || !strings.HasPrefix(rec.Header().Get("Content-Type"), "application/json") {
+		t.Fatalf("status = %d, Content-Type %q; want 200 JSON", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	var resp HealthResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || resp.Status != "healthy" {
+		t.Fatalf("body = %s, want status healthy", rec.Body)
+	}
+}
+
+func TestHealthTextFormat(t *testing.T) {
+	setForTest(t, &healthFormat, "text")
+
+	rec := serveHandler(healthHandler, http.MethodGet, "/health", "")
+	if rec.Code != http.StatusOK || rec.Body.String() != "OK\n" || !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/plain") {
+		t.Fatalf("status = %d, body %q; want 200 OK as text", rec.Code, rec.Body)
+	}
+
+	// Readiness uses the same format, including when it fails
+	startDraining(nil)
+	t.Cleanup(func() { stopDraining(nil) })
+	rec = serveHandler(readyzHandler, http.MethodGet, "/readyz", "")
+	if rec.Code != http.StatusServiceUnavailable || rec.Body.String() != "UNAVAILABLE\n" {
+		t.Fatalf("draining readyz: status = %d, body %q; want 503 UNAVAILABLE", rec.Code, rec.Body)
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"time"
+)
+
+// Outbound connection pool configuration (OUTBOUND_MAX_IDLE_CONNS_PER_HOST,
+// OUTBOUND_IDLE_CONN_TIMEOUT). Go's default of 2 idle connections per host
+// means most concurrent calls to the Order Service would open a new connection.
+var (
+	outboundMaxIdleConnsPerHost = envInt("OUTBOUND_MAX_IDLE_CONNS_PER_HOST", 32)
+	outboundIdleConnTimeout     = envDuration("OUTBOUND_IDLE_CONN_TIMEOUT", 90*time.Second)
+)
+
+// Per-call timeouts, applied as context deadlines so the shared client can be reused
+const (
+	metadataRequestTimeout = 10 * time.Second
+	serviceRequestTimeout  = 30 * time.Second
+)
+
+// Metadata server connection pool configuration (METADATA_MAX_IDLE_CONNS,
+// METADATA_IDLE_CONN_TIMEOUT). Token fetches on a cache miss reuse these
+// connections instead of dialing the metadata server each time.
+var (
+	metadataMaxIdleConns    = envInt("METADATA_MAX_IDLE_CONNS", 8)
+	metadataIdleConnTimeout = envDuration("METADATA_IDLE_CONN_TIMEOUT", 90*time.Second)
+)
+
+// outboundClient is shared by every call to backend services so TCP/TLS
+// connections are kept alive and reused. HTTP/2 is negotiated over TLS, and
+// used for http:// backends too with OUTBOUND_H2C (see h2c.go).
+var outboundClient = &http.Client{
+	Transport: withOutboundH2C(&http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   10 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   outboundMaxIdleConnsPerHost,
+		IdleConnTimeout:       outboundIdleConnTimeout,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}),
+}
+
+// metadataClient is used for metadata server calls. The server is link-local
+// (169.254.169.254), so the transport never goes through HTTP(S)_PROXY and
+// dials with a short timeout; idle connections are kept warm for the next fetch.
+var metadataClient = &http.Client{
+	Transport: &http.Transport{
+		Proxy: nil,
+		DialContext: (&net.Dialer{
+			Timeout:   2 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		MaxIdleConns:        metadataMaxIdleConns,
+		MaxIdleConnsPerHost: metadataMaxIdleConns,
+		IdleConnTimeout:     metadataIdleConnTimeout,
+	},
+}
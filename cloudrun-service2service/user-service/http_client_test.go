@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestIDTokensComeFromMetadataClient(t *testing.T) {
+	setForTest(t, &impersonateServiceAccount, "")
+	useMetadataServer(t, func(audience string) string { return "token-for-" + audience })
+	// Backend calls use outboundClient; metadata calls must not
+	setForTest(t, &outboundClient, &http.Client{Transport: roundTripFunc(func(*http.Request) (*http.Response, error) {
+		return nil, errors.New("metadata request sent through the outbound client")
+	})})
+
+	token, source, err := fetchIDToken(context.Background(), "https://orders.run.app")
+	if err != nil || token != "token-for-https://orders.run.app" || source != "metadata" {
+		t.Fatalf("token = %q, source %q, err %v; want the metadata server's token", token, source, err)
+	}
+}
+
+func TestMetadataClientKeepsConnectionsAlive(t *testing.T) {
+	var dials atomic.Int32
+	metadata := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("token"))
+	}))
+	metadata.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			dials.Add(1)
+		}
+	}
+	metadata.Start()
+	t.Cleanup(metadata.Close)
+
+	for i := 0; i < 3; i++ {
+		resp, err := metadataClient.Get(metadata.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// The connection is only reused once the body has been read
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+	if got := dials.Load(); got != 1 {
+		t.Errorf("3 sequential metadata fetches opened %d connections, want 1", got)
+	}
+}
+
+func TestMetadataClientBypassesProxy(t *testing.T) {
+	transport, ok := metadataClient.Transport.(*http.Transport)
+	if !ok {
+		t.Fatalf("metadata transport is %T, want *http.Transport", metadataClient.Transport)
+	}
+	if transport.Proxy != nil {
+		t.Error("metadata transport uses a proxy; the metadata server is link-local")
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+
+	"golang.org/x/text/language"
+)
+
+// Error message catalogs, one JSON file per language, keyed by error code.
+// English is the fallback for unsupported languages and missing keys.
+//
+//go:embed locales/*.json
+var localeFiles embed.FS
+
+// messageCatalogs maps a language to its messages; catalogLanguages lists the
+// supported languages with English first so the matcher falls back to it
+var messageCatalogs, catalogLanguages = mustLoadCatalogs()
+
+// languageMatcher picks the best supported language for an Accept-Language header
+var languageMatcher = language.NewMatcher(catalogLanguages)
+
+func mustLoadCatalogs() (map[language.Tag]map[string]string, []language.Tag) {
+	files, err := localeFiles.ReadDir("locales")
+	if err != nil {
+		panic(fmt.Sprintf("reading embedded locales: %v", err))
+	}
+
+	catalogs := make(map[language.Tag]map[string]string)
+	tags := []language.Tag{language.English}
+	for _, file := range files {
+		data, err := localeFiles.ReadFile(path.Join("locales", file.Name()))
+		if err != nil {
+			panic(fmt.Sprintf("reading embedded locale %s: %v", file.Name(), err))
+		}
+		var messages map[string]string
+		if err := json.Unmarshal(data, &messages); err != nil {
+			panic(fmt.Sprintf("invalid embedded locale %s: %v", file.Name(), err))
+		}
+		tag := language.MustParse(strings.TrimSuffix(file.Name(), ".json"))
+		catalogs[tag] = messages
+		if tag != language.English {
+			tags = append(tags, tag)
+		}
+	}
+	return catalogs, tags
+}
+
+// requestLanguage returns the supported language that best matches the
+// request's Accept-Language header
+func requestLanguage(r *http.Request) language.Tag {
+	preferred, _, _ := language.ParseAcceptLanguage(r.Header.Get("Accept-Language"))
+	_, index, _ := languageMatcher.Match(preferred...)
+	return catalogLanguages[index]
+}
+
+// localize formats the message for code in lang, falling back to English
+func localize(lang language.Tag, code string, args ...interface{}) string {
+	template, ok := messageCatalogs[lang][code]
+	if !ok {
+		template = messageCatalogs[language.English][code]
+	}
+	return fmt.Sprintf(template, args...)
+}
+
+// writeError writes an ErrorResponse with a language-independent code and a
+// message localized for the caller's Accept-Language
+func writeError(w http.ResponseWriter, r *http.Request, status int, code string, args ...interface{}) {
+	lang := requestLanguage(r)
+	w.Header().Set("Content-Language", lang.String())
+	writeErrorResponse(w, r, status, localizedError(lang, code, args...))
+}
+
+// localizedError builds an ErrorResponse for code with its message in lang
+func localizedError(lang language.Tag, code string, args ...interface{}) ErrorResponse {
+	return ErrorResponse{
+		Code:  code,
+		Error: localize(lang, code, args...),
+	}
+}
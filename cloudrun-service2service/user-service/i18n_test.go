@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+
+	"golang.org/x/text/language"
+)
+
+func TestErrorsLocalizedFromAcceptLanguage(t *testing.T) {
+	useTestStore(t)
+	server := newTestServer(t)
+
+	for _, tc := range []struct {
+		acceptLanguage, wantLanguage, wantMessage string
+	}{
+		{"de-DE,de;q=0.9,en;q=0.5", "de", "wurde nicht gefunden"},
+		{"fr", "fr", "user-404"},
+		// Unsupported languages fall back to English
+		{"ja", "en", "User with ID 'user-404' not found"},
+		{"", "en", "User with ID 'user-404' not found"},
+	} {
+		resp, body := doRequest(t, server, http.MethodGet, "/users/user-404", "", "Accept-Language", tc.acceptLanguage)
+		if resp.StatusCode != http.StatusNotFound {
+			t.Fatalf("Accept-Language %q: status = %d, want 404", tc.acceptLanguage, resp.StatusCode)
+		}
+		if got := resp.Header.Get("Content-Language"); got != tc.wantLanguage {
+			t.Errorf("Accept-Language %q: Content-Language = %q, want %q", tc.acceptLanguage, got, tc.wantLanguage)
+		}
+		var errResp ErrorResponse
+		if err := json.Unmarshal([]byte(body), &errResp); err != nil {
+			t.Fatal(err)
+		}
+		// The code is the same whatever the language, so clients can match on it
+		if errResp.Code != "user_not_found" || !strings.Contains(errResp.Error, tc.wantMessage) {
+			t.Errorf("Accept-Language %q: error = %+v, want user_not_found containing %q", tc.acceptLanguage, errResp, tc.wantMessage)
+		}
+	}
+}
+
+// formatVerbs matches the fmt verbs in a message template
+var formatVerbs = regexp.MustCompile(`%[sdvq]`)
+
+func TestMessageCatalogsMatchEnglish(t *testing.T) {
+	english := messageCatalogs[language.English]
+	for lang, messages := range messageCatalogs {
+		for code, template := range messages {
+			want, ok := english[code]
+			if !ok {
+				t.Errorf("%s: code %q isn't in the English catalog", lang, code)
+				continue
+			}
+			// Arguments are passed positionally, so every translation needs the same verbs in the same order
+			if got, want := formatVerbs.FindAllString(template, -1), formatVerbs.FindAllString(want, -1); strings.Join(got, "") != strings.Join(want, "") {
+				t.Errorf("%s: %q has verbs %v, English has %v", lang, code, got, want)
+			}
+		}
+	}
+}
+
+func TestLocalizeFallsBackToEnglish(t *testing.T) {
+	// A German catalog without user_not_found
+	german := make(map[string]string)
+	for code, template := range messageCatalogs[language.German] {
+		if code != "user_not_found" {
+			german[code] = template
+		}
+	}
+	catalogs := make(map[language.Tag]map[string]string)
+	for lang, messages := range messageCatalogs {
+		catalogs[lang] = messages
+	}
+	catalogs[language.German] = german
+	setForTest(t, &messageCatalogs, catalogs)
+
+	if got, want := localize(language.German, "user_not_found", "user-404"), "User with ID 'user-404' not found"; got != want {
+		t.Errorf("missing German message = %q, want the English %q", got, want)
+	}
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"crypto/rand"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ALLOW_ID_MIGRATION (or ENABLE_ADMIN) enables POST /admin/migrate/ids. It's
+// off by default since the migration rewrites every sequential ID and can't
+// be undone; even when enabled it needs a trusted admin caller.
+var allowIDMigration = envBool("ALLOW_ID_MIGRATION", envBool("ENABLE_ADMIN", false))
+
+// ID_MIGRATION_GRACE_PERIOD is how long old sequential IDs keep redirecting
+// to their replacement UUIDs after POST /admin/migrate/ids
+var idMigrationGracePeriod = envDuration("ID_MIGRATION_GRACE_PERIOD", 30*24*time.Hour)
+
+// sequentialIDPattern matches the deprecated generated IDs (user-001, user-002, ...)
+var sequentialIDPattern = regexp.MustCompile(`^user-[0-9]+$`)
+
+// legacyIDAlias points a migrated sequential ID at its new UUID until Expires
+type legacyIDAlias struct {
+	NewID   string
+	Expires time.Time
+}
+
+// ID migration state, guarded by idMigrationMu.
+// legacyIDAliases redirects old IDs for the grace period; legacyIDs remembers
+// each migrated user's old ID for good, since the Order Service still stores
+// orders under it. idMigrationMu is taken before the store's own lock, so
+// aliases are recorded atomically with the IDs changing.
+var (
+	idMigrationMu   sync.RWMutex
+	legacyIDAliases = make(map[string]legacyIDAlias)
+	legacyIDs       = make(map[string]string)
+)
+
+// IDMigrationResponse represents the response for POST /admin/migrate/ids
+type IDMigrationResponse struct {
+	Service       string            `json:"service"`
+	Migrated      int               `json:"migrated"`
+	Mappings      map[string]string `json:"mappings"`
+	RedirectUntil *time.Time        `json:"redirect_until,omitempty"`
+	Message       string            `json:"message"`
+}
+
+// idMigrationHandler handles POST /admin/migrate/ids. It's idempotent: users
+// that already have UUIDs are left alone, so repeat calls migrate nothing.
+func idMigrationHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, r, http.MethodPost)
+		return
+	}
+
+	if !requireTrustedAdmin(w, r) {
+		return
+	}
+
+	if !allowStoreMutation(w, r) {
+		return
+	}
+
+	// Other backends generate UUIDs from the start, so there's nothing to migrate
+	store, ok := userStore.(*memoryStore)
+	if !ok {
+		writeErrorResponse(w, r, http.StatusNotImplemented, newErrorResponse("not_supported",
+			fmt.Sprintf("ID migration is only supported by the memory store (STORE_BACKEND=%s)", storeBackend)))
+		return
+	}
+
+	expires := time.Now().Add(idMigrationGracePeriod).UTC()
+
+	idMigrationMu.Lock()
+	mappings, err := store.migrateSequentialIDs()
+	if err != nil {
+		idMigrationMu.Unlock()
+		logger.ErrorContext(r.Context(), "Error generating UUID during ID migration", "error", err)
+		writeErrorResponse(w, r, http.StatusInternalServerError, newErrorResponse("internal_error", "Failed to generate new IDs"))
+		return
+	}
+	for oldID, newID := range mappings {
+		legacyIDAliases[oldID] = legacyIDAlias{NewID: newID, Expires: expires}
+		legacyIDs[newID] = oldID
+	}
+	idMigrationMu.Unlock()
+
+	for oldID, newID := range mappings {
+		moveAccess(oldID, newID)
+	}
+
+	response := IDMigrationResponse{
+		Service:  "user-service (Go)",
+		Migrated: len(mappings),
+		Mappings: mappings,
+		Message:  fmt.Sprintf("Migrated %d users to UUIDs", len(mappings)),
+	}
+	if len(mappings) > 0 {
+		response.RedirectUntil = &expires
+	}
+
+	logger.InfoContext(r.Context(), "ID migration reassigned sequential IDs to UUIDs", "migrated", len(mappings))
+	writeJSON(w, http.StatusOK, response)
+}
+
+// redirectLegacyID redirects requests for a migrated sequential ID to the
+// user's new UUID, preserving the rest of the path and the query string.
+// It reports whether a redirect was written.
+func redirectLegacyID(w http.ResponseWriter, r *http.Request, userID string) bool {
+	newID, ok := migratedID(userID)
+	if !ok {
+		return false
+	}
+
+	// A user created with the old ID after migration takes precedence
+	if _, err := findUser(r.Context(), userID); err == nil {
+		return false
+	}
+
+	target := selfURL("/users/"+newID) + strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/users/"), userID)
+	if r.URL.RawQuery != "" {
+		target += "?" + r.URL.RawQuery
+	}
+	w.Header().Set("Deprecation", "true")
+	// 308 keeps the method and body, so PUT/PATCH/DELETE follow the redirect too
+	http.Redirect(w, r, target, http.StatusPermanentRedirect)
+	return true
+}
+
+// migratedID returns the UUID a migrated sequential ID redirects to, while
+// its grace period lasts
+func migratedID(userID string) (string, bool) {
+	idMigrationMu.RLock()
+	alias, ok := legacyIDAliases[userID]
+	idMigrationMu.RUnlock()
+	if !ok || time.Now().After(alias.Expires) {
+		return "", false
+	}
+	return alias.NewID, true
+}
+
+// legacyIDFor returns the sequential ID a user had before migration, or
+// userID itself if it was never migrated
+func legacyIDFor(userID string) string {
+	idMigrationMu.RLock()
+	defer idMigrationMu.RUnlock()
+	if oldID, ok := legacyIDs[userID]; ok {
+		return oldID
+	}
+	return userID
+}
+
+// newUUID returns a random (version 4) UUID
+func newUUID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
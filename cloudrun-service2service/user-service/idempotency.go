@@ -0,0 +1,215 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// OUTBOUND_IDEMPOTENCY_KEYS controls whether outbound POSTs carry an
+// Idempotency-Key header (default true), which also makes them safe to retry
+var outboundIdempotencyKeys = envBool("OUTBOUND_IDEMPOTENCY_KEYS", true)
+
+// idempotencyKeyKey is the context key for the inbound request's Idempotency-Key
+type idempotencyKeyKey struct{}
+
+// withIdempotencyKey is a middleware that keeps the caller's Idempotency-Key
+// (if any) so outbound calls made for the request can derive theirs from it
+func withIdempotencyKey(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if key := r.Header.Get("Idempotency-Key"); key != "" {
+			r = r.WithContext(context.WithValue(r.Context(), idempotencyKeyKey{}, key))
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// outboundIdempotencyKey derives a stable key for an outbound call. It's seeded
+// by the inbound Idempotency-Key (or, failing that, the caller's request ID) so a client
+// retrying the same request produces the same upstream key, and mixes in the
+// call itself so different calls made for one request get different keys.
+// With neither seed, the key is a hash of the call alone.
+func outboundIdempotencyKey(ctx context.Context, method, url string, body []byte) string {
+	seed, _ := ctx.Value(idempotencyKeyKey{}).(string)
+	if seed == "" {
+		// A generated request ID differs on every retry, so it can't seed the key
+		if requestID := requestIDFromContext(ctx); requestID != "-" && !requestIDGenerated(ctx) {
+			seed = "request-id:" + requestID
+		}
+	}
+
+	h := sha256.New()
+	for _, part := range []string{seed, method, url} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil)[:16])
+}
+
+// Create idempotency configuration.
+// A POST /users retried with the same Idempotency-Key within
+// IDEMPOTENCY_KEY_TTL gets the original response back (with
+// Idempotent-Replayed: true) instead of creating the user again. Reusing a
+// key with a different body is a client bug and gets 422. Only successful
+// responses are remembered, so a failed create can be retried with the same
+// key. Keys are scoped to the caller and held in memory, per instance; at
+// IDEMPOTENCY_MAX_KEYS new keys aren't remembered until old ones expire.
+var (
+	idempotencyKeyTTL     = envDuration("IDEMPOTENCY_KEY_TTL", 24*time.Hour)
+	idempotencyMaxEntries = envInt("IDEMPOTENCY_MAX_KEYS", 10000)
+)
+
+// idempotentResponse is a remembered response to a keyed request. While the
+// first request is still running, done is false and there's nothing to replay.
+type idempotentResponse struct {
+	bodyHash string
+	done     bool
+	status   int
+	header   http.Header
+	body     []byte
+	expiry   time.Time
+}
+
+// idempotentResponses holds responses by caller and Idempotency-Key
+var idempotentResponses = struct {
+	sync.Mutex
+	entries map[string]*idempotentResponse
+}{entries: make(map[string]*idempotentResponse)}
+
+// replayedHeaders are the response headers kept for a replay
+var replayedHeaders = []string{"Content-Type", "Content-Language", "ETag", "Location"}
+
+// idempotencyRecorder passes a response through while keeping a copy to replay
+type idempotencyRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rec *idempotencyRecorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+// Unwrap lets writeJSON find the negotiated response version
+func (rec *idempotencyRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+func (rec *idempotencyRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	rec.body.Write(b)
+	return rec.ResponseWriter.Write(b)
+}
+
+// serveIdempotently runs handler for a request carrying an Idempotency-Key at
+// most once per key: a repeat with the same body replays the first response,
+// a repeat with a different body gets 422, and a repeat while the first is
+// still running gets 409. Requests without a key just run handler.
+func serveIdempotently(w http.ResponseWriter, r *http.Request, handler http.HandlerFunc) {
+	key := r.Header.Get("Idempotency-Key")
+	if key == "" || idempotencyKeyTTL <= 0 {
+		handler(w, r)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeBodyReadError(w, r, err)
+		return
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	hash := sha256.Sum256(body)
+	bodyHash := hex.EncodeToString(hash[:])
+	entryKey := callerRateKey(r) + "\x00" + r.Method + " " + r.URL.Path + "\x00" + key
+
+	now := time.Now()
+	idempotentResponses.Lock()
+	entry, ok := idempotentResponses.entries[entryKey]
+	if ok && now.After(entry.expiry) {
+		delete(idempotentResponses.entries, entryKey)
+		ok = false
+	}
+	switch {
+	case ok && entry.bodyHash != bodyHash:
+		idempotentResponses.Unlock()
+		writeError(w, r, http.StatusUnprocessableEntity, "idempotency_key_reused")
+		return
+	case ok && !entry.done:
+		idempotentResponses.Unlock()
+		writeError(w, r, http.StatusConflict, "idempotency_key_in_progress")
+		return
+	case ok:
+		replay := *entry
+		idempotentResponses.Unlock()
+		logger.InfoContext(r.Context(), "Replaying response for repeated Idempotency-Key", "status", replay.status)
+		for name, values := range replay.header {
+			w.Header()[name] = values
+		}
+		w.Header().Set("Idempotent-Replayed", "true")
+		w.WriteHeader(replay.status)
+		w.Write(replay.body)
+		return
+	}
+	if len(idempotentResponses.entries) >= idempotencyMaxEntries {
+		// Full: make room by dropping expired keys
+		for k, e := range idempotentResponses.entries {
+			if now.After(e.expiry) {
+				delete(idempotentResponses.entries, k)
+			}
+		}
+	}
+	remembered := len(idempotentResponses.entries) < idempotencyMaxEntries
+	if remembered {
+		idempotentResponses.entries[entryKey] = &idempotentResponse{bodyHash: bodyHash, expiry: now.Add(idempotencyKeyTTL)}
+	}
+	idempotentResponses.Unlock()
+
+	if !remembered {
+		logger.WarnContext(r.Context(), "Idempotency key store is full - not remembering key", "max_keys", idempotencyMaxEntries)
+		handler(w, r)
+		return
+	}
+
+	rec := &idempotencyRecorder{ResponseWriter: w}
+	defer func() {
+		// Forget the key unless a response was stored for it, so a handler
+		// panic (re-raised for recoverPanics) doesn't leave it in progress
+		// until the TTL runs out
+		idempotentResponses.Lock()
+		defer idempotentResponses.Unlock()
+		if entry, ok := idempotentResponses.entries[entryKey]; ok && !entry.done {
+			delete(idempotentResponses.entries, entryKey)
+		}
+	}()
+	handler(rec, r)
+
+	idempotentResponses.Lock()
+	defer idempotentResponses.Unlock()
+	if rec.status < 200 || rec.status >= 300 {
+		return
+	}
+	entry, ok = idempotentResponses.entries[entryKey]
+	if !ok {
+		return
+	}
+	entry.done = true
+	entry.status = rec.status
+	entry.body = rec.body.Bytes()
+	entry.header = make(http.Header)
+	for _, name := range replayedHeaders {
+		if values := w.Header().Values(name); len(values) > 0 {
+			entry.header[name] = values
+		}
+	}
+}
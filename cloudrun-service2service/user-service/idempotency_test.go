@@ -0,0 +1,41 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"testing"
+)
+
+func TestIdempotencyKeyReleasedAfterPanic(t *testing.T) {
+	captureLogs(t, slog.LevelInfo)
+	t.Cleanup(func() {
+		idempotentResponses.Lock()
+		clear(idempotentResponses.entries)
+		idempotentResponses.Unlock()
+	})
+	calls := 0
+	server := newPanickingServer(t, func(w http.ResponseWriter, r *http.Request) {
+		serveIdempotently(w, r, func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			if calls == 1 {
+				panic("create failed")
+			}
+			writeJSON(w, http.StatusCreated, map[string]int{"calls": calls})
+		})
+	})
+	body := `{"name":"Dave Jones"}`
+
+	if resp, got := doRequest(t, server, http.MethodPost, "/panic", body, "Idempotency-Key", "panic-key"); resp.StatusCode != http.StatusInternalServerError {
+		t.Fatalf("first request: status = %d, want 500: %s", resp.StatusCode, got)
+	}
+	// The retry runs the handler again rather than getting 409 in progress
+	resp, got := doRequest(t, server, http.MethodPost, "/panic", body, "Idempotency-Key", "panic-key")
+	if resp.StatusCode != http.StatusCreated || calls != 2 {
+		t.Fatalf("retry: status = %d after %d calls, want 201 from a second call: %s", resp.StatusCode, calls, got)
+	}
+	// And its response is the one remembered
+	resp, got = doRequest(t, server, http.MethodPost, "/panic", body, "Idempotency-Key", "panic-key")
+	if resp.StatusCode != http.StatusCreated || resp.Header.Get("Idempotent-Replayed") != "true" || calls != 2 {
+		t.Errorf("second retry: status = %d, Idempotent-Replayed %q after %d calls; want the 201 replayed: %s", resp.StatusCode, resp.Header.Get("Idempotent-Replayed"), calls, got)
+	}
+}
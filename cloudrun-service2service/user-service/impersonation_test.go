@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"golang.org/x/oauth2"
+)
+
+// tokenSourceFunc lets a function stand in for an oauth2.TokenSource
+type tokenSourceFunc func() (*oauth2.Token, error)
+
+func (f tokenSourceFunc) Token() (*oauth2.Token, error) { return f() }
+
+// useImpersonation sets IMPERSONATE_SERVICE_ACCOUNT, with source standing in
+// for the IAM Credentials API, and fails the test if the metadata server is asked
+func useImpersonation(t *testing.T, source func(audience string) (*oauth2.Token, error)) {
+	t.Helper()
+	setForTest(t, &impersonateServiceAccount, "ci-runner@project.iam.gserviceaccount.com")
+	setForTest(t, &newImpersonatedTokenSource, func(ctx context.Context, audience string) (oauth2.TokenSource, error) {
+		return tokenSourceFunc(func() (*oauth2.Token, error) { return source(audience) }), nil
+	})
+	useMetadataServer(t, func(audience string) string {
+		t.Error("metadata server asked for a token while impersonating")
+		return ""
+	})
+}
+
+func TestImpersonatedIDTokens(t *testing.T) {
+	audience := "https://orders.run.app"
+	t.Cleanup(func() { evictIDToken(audience) })
+	token := testIDToken(map[string]interface{}{"aud": audience})
+	fetches := 0
+	useImpersonation(t, func(gotAudience string) (*oauth2.Token, error) {
+		fetches++
+		if gotAudience != audience {
+			t.Errorf("audience = %s, want %s", gotAudience, audience)
+		}
+		return &oauth2.Token{AccessToken: token}, nil
+	})
+
+	got, source, err := getIDToken(context.Background(), audience)
+	if err != nil || got != token || source != "impersonation" {
+		t.Fatalf("getIDToken = %q, %q, %v; want the impersonated token", got, source, err)
+	}
+	// Impersonated tokens are real ID tokens, so they're cached like the metadata server's
+	if _, source, _ := getIDToken(context.Background(), audience); source != "cache" || fetches != 1 {
+		t.Errorf("second getIDToken source = %q after %d fetches, want the cache", source, fetches)
+	}
+}
+
+func TestImpersonatedTokenSentToBackend(t *testing.T) {
+	var gotAuth string
+	backend := newBackend(t, func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+	})
+	t.Cleanup(func() { evictIDToken(backend.URL) })
+	token := testIDToken(map[string]interface{}{"aud": backend.URL})
+	useImpersonation(t, func(string) (*oauth2.Token, error) {
+		return &oauth2.Token{AccessToken: token}, nil
+	})
+
+	if _, err := makeAuthenticatedGet(context.Background(), backend.URL+"/orders"); err != nil {
+		t.Fatal(err)
+	}
+	if gotAuth != "Bearer "+token {
+		t.Errorf("Authorization = %q, want the impersonated token", gotAuth)
+	}
+}
+
+func TestImpersonationFailure(t *testing.T) {
+	useImpersonation(t, func(string) (*oauth2.Token, error) {
+		return nil, errors.New("permission iam.serviceAccounts.getOpenIdToken denied")
+	})
+
+	_, _, err := fetchIDToken(context.Background(), "https://orders.run.app")
+	// The error names the account, since the fix is granting roles/iam.serviceAccountTokenCreator on it
+	if err == nil || !strings.Contains(err.Error(), impersonateServiceAccount) {
+		t.Errorf("err = %v, want one naming %s", err, impersonateServiceAccount)
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// errNotJSONObject is returned by unmarshalFields for bodies that aren't an object
+var errNotJSONObject = errors.New("body is not a JSON object")
+
+// unmarshalFields decodes the JSON object data into the struct v, checking
+// each field on its own so every problem is reported rather than just the
+// first: fields v doesn't have get "field_unknown" (with the closest known
+// name as a suggestion) and values of the wrong type "field_type". The valid
+// fields are still decoded into v, so it can be validated further. err is
+// only set when data isn't a JSON object at all.
+func unmarshalFields(data []byte, v interface{}) (map[string]fieldError, error) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil || raw == nil {
+		if err == nil {
+			err = errNotJSONObject
+		}
+		return nil, err
+	}
+
+	target := reflect.ValueOf(v).Elem()
+	known := jsonFieldIndexes(target.Type())
+	fields := make(map[string]fieldError)
+	for name, value := range raw {
+		index, ok := known[name]
+		if !ok {
+			if suggestion := closestFieldName(name, known); suggestion != "" {
+				fields[name] = fieldError{Code: "field_unknown_suggest", Args: []interface{}{name, suggestion}}
+			} else {
+				fields[name] = fieldError{Code: "field_unknown", Args: []interface{}{name}}
+			}
+			continue
+		}
+
+		field := target.Field(index)
+		decoded := reflect.New(field.Type())
+		if err := json.Unmarshal(value, decoded.Interface()); err != nil {
+			fields[name] = fieldError{Code: "field_type", Args: []interface{}{name, jsonTypeName(field.Type())}}
+			continue
+		}
+		field.Set(decoded.Elem())
+	}
+	return fields, nil
+}
+
+// jsonFieldIndexes maps a struct's JSON field names to their field indexes
+func jsonFieldIndexes(t reflect.Type) map[string]int {
+	indexes := make(map[string]int, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		switch name {
+		case "-":
+			continue
+		case "":
+			name = field.Name
+		}
+		indexes[name] = i
+	}
+	return indexes
+}
+
+// jsonTypeName describes the JSON a Go type decodes from, for error messages
+func jsonTypeName(t reflect.Type) string {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == reflect.TypeOf(time.Time{}) {
+		return "RFC 3339 timestamp"
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	default:
+		return "object"
+	}
+}
+
+// closestFieldName returns the known field name within two edits of name
+// (ignoring case), or "" if there's none, to suggest for a typo
+func closestFieldName(name string, known map[string]int) string {
+	best, bestDistance := "", 3
+	for candidate := range known {
+		distance := editDistance(strings.ToLower(name), strings.ToLower(candidate))
+		if distance < bestDistance || (distance == bestDistance && candidate < best) {
+			best, bestDistance = candidate, distance
+		}
+	}
+	return best
+}
+
+// editDistance is the Levenshtein distance between a and b
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
+
+// isStructPointer reports whether v points to a struct, i.e. decodes from a JSON object
+func isStructPointer(v interface{}) bool {
+	t := reflect.TypeOf(v)
+	return t != nil && t.Kind() == reflect.Pointer && t.Elem().Kind() == reflect.Struct
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	jsonpatch "github.com/evanphx/json-patch/v5"
+)
+
+// Media types PATCH /users/{id} accepts. A merge patch (or plain JSON) sets
+// the fields present in the body; a JSON Patch (RFC 6902) is a list of
+// add/remove/replace/move/copy/test operations applied in order.
+const (
+	mediaTypeMergePatch = "application/merge-patch+json"
+	mediaTypeJSONPatch  = "application/json-patch+json"
+)
+
+// acceptPatch is advertised in Accept-Patch when a PATCH's type isn't supported
+const acceptPatch = mediaTypeMergePatch + ", " + mediaTypeJSONPatch
+
+// jsonPatchMaxAttempts is how many times a JSON Patch is re-applied when
+// another write changes the user between reading and updating it
+const jsonPatchMaxAttempts = 3
+
+// patchMediaType returns the media type of a PATCH body ("" if it has none)
+// and whether it's supported
+func patchMediaType(r *http.Request) (string, bool) {
+	mediaType := requestMediaType(r)
+	switch mediaType {
+	case "application/json", mediaTypeMergePatch, mediaTypeJSONPatch:
+		return mediaType, true
+	default:
+		return mediaType, false
+	}
+}
+
+// patchableUser is the document JSON Patch operations apply to. Timestamps
+// are managed by the store, so they aren't part of it.
+type patchableUser struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Email string `json:"email"`
+	Role  string `json:"role"`
+}
+
+// errJSONPatchTestFailed marks a patch whose test operation didn't match
+var errJSONPatchTestFailed = errors.New("test operation failed")
+
+// userPatchFromJSONPatch applies ops to user and returns the result as a full
+// UserPatch. The patched document must still be a user: unknown fields are
+// rejected, and removed fields become empty (so validation reports them).
+func userPatchFromJSONPatch(ops jsonpatch.Patch, user User) (UserPatch, error) {
+	doc, err := json.Marshal(patchableUser{ID: user.ID, Name: user.Name, Email: user.Email, Role: user.Role})
+	if err != nil {
+		return UserPatch{}, err
+	}
+
+	patched, err := ops.Apply(doc)
+	if errors.Is(err, jsonpatch.ErrTestFailed) {
+		return UserPatch{}, fmt.Errorf("%w: %v", errJSONPatchTestFailed, err)
+	}
+	if err != nil {
+		return UserPatch{}, err
+	}
+
+	var result patchableUser
+	decoder := json.NewDecoder(bytes.NewReader(patched))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&result); err != nil {
+		return UserPatch{}, fmt.Errorf("patched user is invalid: %v", err)
+	}
+	return UserPatch{
+		ID:    &result.ID,
+		Name:  &result.Name,
+		Email: &result.Email,
+		Role:  &result.Role,
+	}, nil
+}
+
+// patchUserJSONPatch handles PATCH /users/{id} with a JSON Patch body. The
+// operations apply to the user as read, and the update only goes through if
+// the user is still unchanged (its ETag), so the patch is atomic; if another
+// write got in first, the user is re-read and the patch re-applied.
+func patchUserJSONPatch(w http.ResponseWriter, r *http.Request, userID string) {
+	var ops jsonpatch.Patch
+	if !decodeJSONBody(w, r, r.Body, &ops) {
+		return
+	}
+
+	for attempt := 1; ; attempt++ {
+		current, err := findUser(r.Context(), userID)
+		if errors.Is(err, errUserNotFound) {
+			writeError(w, r, http.StatusNotFound, "user_not_found", userID)
+			return
+		}
+		if err != nil {
+			writeStoreFailure(w, r, err)
+			return
+		}
+
+		etag := userETag(current)
+		if ifMatch := r.Header.Get("If-Match"); ifMatch != "" && !etagListMatches(ifMatch, etag, false) {
+			writeError(w, r, http.StatusPreconditionFailed, "precondition_failed", userID)
+			return
+		}
+
+		patch, err := userPatchFromJSONPatch(ops, current)
+		if errors.Is(err, errJSONPatchTestFailed) {
+			writeError(w, r, http.StatusConflict, "json_patch_test_failed")
+			return
+		}
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, "json_patch_invalid", err.Error())
+			return
+		}
+
+		if !applyUserUpdate(w, r, userID, patch, etag, attempt < jsonPatchMaxAttempts) {
+			return
+		}
+		logger.InfoContext(r.Context(), "User changed while applying JSON Patch, retrying", "user_id", userID, "attempt", attempt)
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// jsonPatchUser PATCHes path with a JSON Patch
+func jsonPatchUser(path, ops string, headers ...string) *httptest.ResponseRecorder {
+	return serveHandler(userByIDHandler, http.MethodPatch, path, ops, append(headers, "Content-Type", mediaTypeJSONPatch)...)
+}
+
+func TestJSONPatchAppliesOperations(t *testing.T) {
+	useTestStore(t)
+
+	rec := jsonPatchUser("/users/user-002", `[
+		{"op":"test","path":"/role","value":"developer"},
+		{"op":"replace","path":"/name","value":"Robert Smith"},
+		{"op":"copy","from":"/email","path":"/role"},
+		{"op":"replace","path":"/role","value":"viewer"}
+	]`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body)
+	}
+	var resp UsersResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if user := resp.User; user == nil || user.Name != "Robert Smith" || user.Role != "viewer" || user.Email != "bob@example.com" {
+		t.Fatalf("user = %+v, want Robert Smith, viewer", resp.User)
+	}
+}
+
+func TestJSONPatchTestFailureChangesNothing(t *testing.T) {
+	useTestStore(t)
+
+	rec := jsonPatchUser("/users/user-002", `[
+		{"op":"replace","path":"/name","value":"Robert Smith"},
+		{"op":"test","path":"/role","value":"admin"}
+	]`)
+	if rec.Code != http.StatusConflict || !strings.Contains(rec.Body.String(), `"code":"json_patch_test_failed"`) {
+		t.Fatalf("status = %d, want 409 json_patch_test_failed: %s", rec.Code, rec.Body)
+	}
+	if user := listUsersForTest(t, "").Users[1]; user.Name != "Bob Smith" {
+		t.Errorf("name = %q after a failed patch, want it unchanged", user.Name)
+	}
+}
+
+func TestJSONPatchInvalid(t *testing.T) {
+	useTestStore(t)
+
+	for _, ops := range []string{
+		// Not a user any more
+		`[{"op":"add","path":"/admin","value":true}]`,
+		`[{"op":"remove","path":"/nickname"}]`,
+		`[{"op":"frobnicate","path":"/name"}]`,
+	} {
+		if rec := jsonPatchUser("/users/user-002", ops); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400: %s", ops, rec.Code, rec.Body)
+		}
+	}
+
+	// Removing a required field leaves it empty, which validation rejects
+	if rec := jsonPatchUser("/users/user-002", `[{"op":"remove","path":"/email"}]`); rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), `"validation_failed"`) {
+		t.Errorf("removing email: status = %d, want 400 validation_failed: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestJSONPatchIfMatch(t *testing.T) {
+	useTestStore(t)
+
+	rec := jsonPatchUser("/users/user-002", `[{"op":"replace","path":"/name","value":"Robert Smith"}]`, "If-Match", `"stale"`)
+	if rec.Code != http.StatusPreconditionFailed {
+		t.Fatalf("status = %d with a stale If-Match, want 412: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestPatchUnsupportedMediaType(t *testing.T) {
+	useTestStore(t)
+
+	rec := serveHandler(userByIDHandler, http.MethodPatch, "/users/user-002", `name=Robert`, "Content-Type", "application/x-www-form-urlencoded")
+	if rec.Code != http.StatusUnsupportedMediaType || rec.Header().Get("Accept-Patch") != acceptPatch {
+		t.Fatalf("status = %d, Accept-Patch %q; want 415 advertising %q", rec.Code, rec.Header().Get("Accept-Patch"), acceptPatch)
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// decodeJWTPayload decodes the claims segment of a JWT into v.
+// It does NOT verify the signature - only use it on tokens we fetched
+// ourselves, never to trust a caller's claims (see authenticate).
+func decodeJWTPayload(token string, v interface{}) error {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return fmt.Errorf("malformed JWT: expected 3 segments, got %d", len(parts))
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return fmt.Errorf("malformed JWT payload: %v", err)
+	}
+
+	if err := json.Unmarshal(payload, v); err != nil {
+		return fmt.Errorf("malformed JWT claims: %v", err)
+	}
+	return nil
+}
+
+// bearerToken extracts the token from an "Authorization: Bearer <token>" header
+func bearerToken(authHeader string) (string, bool) {
+	scheme, token, ok := strings.Cut(authHeader, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return "", false
+	}
+	return strings.TrimSpace(token), true
+}
@@ -0,0 +1,341 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Pagination configuration for GET /users
+var (
+	defaultPageSize = envInt("DEFAULT_PAGE_SIZE", 20)
+	maxPageSize     = envInt("MAX_PAGE_SIZE", 100)
+)
+
+// EMPTY_LIST_RESPONSE controls what GET /users returns when there are no users
+// to list: "array" (default) sends "users": [] and "count": 0 explicitly,
+// "no_content" sends 204 with no body, and "omit" keeps the old shape where
+// both fields are left out
+var emptyListResponse = strings.ToLower(envString("EMPTY_LIST_RESPONSE", "array"))
+
+// UserListResponse represents the response for GET /users. Count and Users
+// are always present, even when empty.
+type UserListResponse struct {
+	Service    string      `json:"service"`
+	Count      int         `json:"count"`
+	Users      []User      `json:"users"`
+	Pagination *Pagination `json:"pagination,omitempty"`
+}
+
+// UserGroupsResponse represents the response for GET /users?group_by=
+type UserGroupsResponse struct {
+	Service string            `json:"service"`
+	Count   int               `json:"count"`
+	Groups  map[string][]User `json:"groups"`
+}
+
+// writeEmptyUserList writes the EMPTY_LIST_RESPONSE response for a listing
+// with no users and reports whether it did. In "array" mode it writes
+// nothing, so the caller sends its usual (empty) response.
+func writeEmptyUserList(w http.ResponseWriter, pagination *Pagination) bool {
+	switch emptyListResponse {
+	case "no_content":
+		w.WriteHeader(http.StatusNoContent)
+		return true
+	case "omit":
+		writeJSON(w, http.StatusOK, UsersResponse{
+			Service:    "user-service (Go)",
+			Pagination: pagination,
+		})
+		return true
+	default:
+		return false
+	}
+}
+
+// Pagination describes the page of results in a list response
+type Pagination struct {
+	Total      int  `json:"total"`
+	Limit      int  `json:"limit"`
+	Offset     int  `json:"offset"`
+	NextOffset *int `json:"next_offset,omitempty"`
+	// NextCursor resumes after this page; set for listings in creation order
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// parsePagination reads ?limit= and ?offset=, applying defaults and the max page size
+func parsePagination(r *http.Request) (limit, offset int, err error) {
+	limit = defaultPageSize
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		limit, err = strconv.Atoi(raw)
+		if err != nil || limit < 1 {
+			return 0, 0, fmt.Errorf("limit must be a positive integer")
+		}
+	}
+	if limit > maxPageSize {
+		limit = maxPageSize
+	}
+
+	if raw := r.URL.Query().Get("offset"); raw != "" {
+		offset, err = strconv.Atoi(raw)
+		if err != nil || offset < 0 {
+			return 0, 0, fmt.Errorf("offset must be a non-negative integer")
+		}
+	}
+
+	return limit, offset, nil
+}
+
+// paginate returns the requested page of list along with its pagination metadata
+func paginate(list []User, limit, offset int) ([]User, Pagination) {
+	page := Pagination{
+		Total:  len(list),
+		Limit:  limit,
+		Offset: offset,
+	}
+
+	if offset >= len(list) {
+		return []User{}, page
+	}
+
+	end := offset + limit
+	if end < len(list) {
+		page.NextOffset = &end
+	} else {
+		end = len(list)
+	}
+	return list[offset:end], page
+}
+
+// UserFilter narrows a user listing; all set criteria must match
+type UserFilter struct {
+	Role  string
+	Query string
+	// IncludeDeleted also matches soft-deleted users
+	IncludeDeleted bool
+}
+
+// parseUserFilter reads ?role=, ?q= and ?include_deleted= from the request
+func parseUserFilter(r *http.Request) UserFilter {
+	query := r.URL.Query()
+	return UserFilter{
+		Role:           strings.TrimSpace(query.Get("role")),
+		Query:          strings.ToLower(strings.TrimSpace(query.Get("q"))),
+		IncludeDeleted: includeDeleted(r),
+	}
+}
+
+// matches reports whether user satisfies every criterion in the filter.
+// Role is a case-insensitive match; q is a case-insensitive substring of name
+// or email.
+func (f UserFilter) matches(user User) bool {
+	if user.Deleted && !f.IncludeDeleted {
+		return false
+	}
+	if f.Role != "" && !strings.EqualFold(user.Role, f.Role) {
+		return false
+	}
+	if f.Query != "" &&
+		!strings.Contains(strings.ToLower(user.Name), f.Query) &&
+		!strings.Contains(strings.ToLower(user.Email), f.Query) {
+		return false
+	}
+	return true
+}
+
+// filterUsers returns the users matching f
+func filterUsers(list []User, f UserFilter) []User {
+	filtered := make([]User, 0, len(list))
+	for _, user := range list {
+		if f.matches(user) {
+			filtered = append(filtered, user)
+		}
+	}
+	return filtered
+}
+
+// userSortFields are the fields ?sort= accepts, each with its ascending order.
+// Text fields compare case-insensitively.
+var userSortFields = map[string]func(a, b User) bool{
+	"name":       func(a, b User) bool { return strings.ToLower(a.Name) < strings.ToLower(b.Name) },
+	"email":      func(a, b User) bool { return strings.ToLower(a.Email) < strings.ToLower(b.Email) },
+	"role":       func(a, b User) bool { return strings.ToLower(a.Role) < strings.ToLower(b.Role) },
+	"created_at": func(a, b User) bool { return a.CreatedAt.Before(b.CreatedAt) },
+}
+
+// UserSort orders a user listing by one field
+type UserSort struct {
+	Field      string
+	Descending bool
+}
+
+// parseSort reads ?sort= from the request, e.g. "name" or "-created_at" for
+// descending ("" = store order)
+func parseSort(r *http.Request) (UserSort, error) {
+	raw := strings.TrimSpace(r.URL.Query().Get("sort"))
+	if raw == "" {
+		return UserSort{}, nil
+	}
+	s := UserSort{Field: strings.ToLower(strings.TrimPrefix(raw, "-")), Descending: strings.HasPrefix(raw, "-")}
+	if _, ok := userSortFields[s.Field]; !ok {
+		return UserSort{}, fmt.Errorf("sort must be one of: name, email, created_at, role, optionally prefixed with - for descending (got %q)", raw)
+	}
+	return s, nil
+}
+
+// sortUsers sorts list in place by s. Ties keep their store order, so pages
+// stay stable across requests. Callers pass their own copy of the store's users.
+func sortUsers(list []User, s UserSort) {
+	less, ok := userSortFields[s.Field]
+	if !ok {
+		return
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		if s.Descending {
+			return less(list[j], list[i])
+		}
+		return less(list[i], list[j])
+	})
+}
+
+// groupByValues are the accepted values for ?group_by=
+var groupByValues = map[string]bool{"role": true}
+
+// parseGroupBy reads ?group_by= from the request ("" = flat list)
+func parseGroupBy(r *http.Request) (string, error) {
+	groupBy := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("group_by")))
+	if groupBy != "" && !groupByValues[groupBy] {
+		return "", fmt.Errorf("group_by must be one of: role (got %q)", groupBy)
+	}
+	return groupBy, nil
+}
+
+// noRoleGroup is the group for users without a role
+const noRoleGroup = "none"
+
+// groupUsersByRole returns list keyed by role, keeping the list's order within each group
+func groupUsersByRole(list []User) map[string][]User {
+	groups := make(map[string][]User)
+	for _, user := range list {
+		role := user.Role
+		if role == "" {
+			role = noRoleGroup
+		}
+		groups[role] = append(groups[role], user)
+	}
+	return groups
+}
+
+// parseListFormat reads ?format= from the request ("" = a JSON object)
+func parseListFormat(r *http.Request) (string, error) {
+	format := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("format")))
+	if format != "" && format != "json" && format != "ndjson" {
+		return "", fmt.Errorf("format must be one of: json, ndjson (got %q)", format)
+	}
+	return format, nil
+}
+
+// ndjsonFlushEvery is how many users are written between flushes when streaming
+const ndjsonFlushEvery = 100
+
+// streamUsersNDJSON writes list as newline-delimited JSON, one user per line,
+// flushing as it goes so clients can process users before the export ends.
+// The whole list is sent: it isn't paginated or capped by MAX_RESPONSE_BYTES.
+// If the client goes away mid-stream the export stops; since the status is
+// already sent, a truncated stream only shows up as a missing final newline.
+func streamUsersNDJSON(w http.ResponseWriter, r *http.Request, list []User) {
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+
+	// Without a Flusher (e.g. under SIGN_RESPONSES, which buffers to sign)
+	// the response still works, it just isn't sent incrementally
+	flusher, _ := w.(http.Flusher)
+	encoder := json.NewEncoder(w)
+	loc := responseTimezoneOf(w)
+	for i, user := range list {
+		if loc != nil {
+			user = zonedUser(user, loc)
+		}
+		user = projectedUser(w, user)
+		if err := r.Context().Err(); err != nil {
+			logger.WarnContext(r.Context(), "Stopped streaming users", "written", i, "total", len(list), "error", err)
+			return
+		}
+		if err := encoder.Encode(user); err != nil {
+			logger.ErrorContext(r.Context(), "Error streaming users", "written", i, "total", len(list), "error", err)
+			return
+		}
+		if flusher != nil && (i+1)%ndjsonFlushEvery == 0 {
+			flusher.Flush()
+		}
+	}
+	if flusher != nil {
+		flusher.Flush()
+	}
+}
+
+// UserCountResponse represents the response for GET /users/count
+type UserCountResponse struct {
+	Service string `json:"service"`
+	Count   int    `json:"count"`
+}
+
+// countMatchingUsers counts the users matching the request's role and q filters
+func countMatchingUsers(r *http.Request) (int, error) {
+	all, err := userStore.List(r.Context())
+	if err != nil {
+		return 0, err
+	}
+	filter := parseUserFilter(r)
+	count := 0
+	for _, user := range all {
+		if filter.matches(user) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// usersCountHandler handles GET /users/count, which returns how many users
+// match ?role= and ?q= without listing them. The route is registered ahead of
+// /users/{id}, so "count" is reserved and can't be used as a user ID.
+func usersCountHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+	case http.MethodOptions:
+		writeOptions(w, http.MethodGet, http.MethodHead, http.MethodOptions)
+		return
+	default:
+		methodNotAllowed(w, r, http.MethodGet, http.MethodHead, http.MethodOptions)
+		return
+	}
+
+	count, err := countMatchingUsers(r)
+	if err != nil {
+		writeStoreFailure(w, r, err)
+		return
+	}
+	w.Header().Set("X-Total-Count", strconv.Itoa(count))
+	writeJSON(w, http.StatusOK, UserCountResponse{
+		Service: "user-service (Go)",
+		Count:   count,
+	})
+}
+
+// headUsers handles HEAD /users: no body, just the number of matching users
+// in X-Total-Count
+func headUsers(w http.ResponseWriter, r *http.Request) {
+	if listNotModified(w, r) {
+		return
+	}
+	count, err := countMatchingUsers(r)
+	if err != nil {
+		writeStoreFailure(w, r, err)
+		return
+	}
+	w.Header().Set("X-Total-Count", strconv.Itoa(count))
+	w.WriteHeader(http.StatusOK)
+}
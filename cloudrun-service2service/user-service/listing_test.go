@@ -0,0 +1,50 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+// listUsersForTest calls GET /users?query and decodes the listing
+func listUsersForTest(t *testing.T, query string) UsersResponse {
+	t.Helper()
+	rec := serveHandler(getAllUsers, http.MethodGet, "/users?"+query, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /users?%s: status = %d, want 200: %s", query, rec.Code, rec.Body)
+	}
+	var resp UsersResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+// userIDs returns the IDs of users, in order
+func userIDs(users []User) []string {
+	ids := make([]string, len(users))
+	for i, user := range users {
+		ids[i] = user.ID
+	}
+	return ids
+}
+
+func TestListUsersFilters(t *testing.T) {
+	useTestStore(t)
+
+	for _, tc := range []struct {
+		query string
+		want  []string
+	}{
+		{"role=admin", []string{"user-001"}},
+		{"role=ADMIN", []string{"user-001"}},
+		{"q=BOB", []string{"user-002"}},
+		{"q=example.com&role=viewer", []string{"user-003"}},
+		{"q=alice&role=viewer", nil},
+	} {
+		resp := listUsersForTest(t, tc.query)
+		if got := userIDs(resp.Users); len(got) != len(tc.want) || (len(got) > 0 && got[0] != tc.want[0]) {
+			t.Errorf("?%s returned %v, want %v", tc.query, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Request log sampling configuration.
+// Every request normally gets a "request completed" line. On a busy instance
+// most of those are identical successes, so successful (2xx and 3xx)
+// requests can be sampled instead: LOG_SAMPLE_RATE=N logs 1 in N of them,
+// and LOG_SAMPLE_PATHS sets N per path, overriding the default:
+//
+//	LOG_SAMPLE_RATE=10
+//	LOG_SAMPLE_PATHS="/health=1000,/readyz=1000,/users/=5"
+//
+// A path ending in / covers everything under it, as in route patterns; the
+// longest match wins. Errors (4xx and 5xx) and requests slower than
+// LOG_SLOW_REQUEST_THRESHOLD are always logged. Sampled lines carry
+// sample_rate, so counts from logs can be scaled back up. Metrics and the
+// error rate still see every request.
+var (
+	logSampleRate           = max(envInt("LOG_SAMPLE_RATE", 1), 1)
+	logSamplePaths          = parseLogSamplePaths(os.Getenv("LOG_SAMPLE_PATHS"))
+	logSlowRequestThreshold = envDuration("LOG_SLOW_REQUEST_THRESHOLD", time.Second)
+)
+
+// logSampler logs 1 in rate of the requests it covers. The counter makes the
+// decision a single atomic add, with no lock or random number.
+type logSampler struct {
+	rate    uint64
+	counter atomic.Uint64
+}
+
+// sample reports whether the next request should be logged
+func (s *logSampler) sample() bool {
+	return s.rate <= 1 || s.counter.Add(1)%s.rate == 1
+}
+
+// defaultLogSampler applies LOG_SAMPLE_RATE to paths LOG_SAMPLE_PATHS doesn't list
+var defaultLogSampler = &logSampler{rate: uint64(logSampleRate)}
+
+// parseLogSamplePaths parses LOG_SAMPLE_PATHS ("/path=N,...")
+func parseLogSamplePaths(raw string) map[string]*logSampler {
+	samplers := make(map[string]*logSampler)
+	for _, pair := range strings.Split(raw, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		path, rawRate, _ := strings.Cut(pair, "=")
+		path = strings.TrimSpace(path)
+		rate, err := strconv.Atoi(strings.TrimSpace(rawRate))
+		if !strings.HasPrefix(path, "/") || err != nil || rate < 1 {
+			logger.Warn("Ignoring invalid log sample rate", "value", pair)
+			continue
+		}
+		samplers[path] = &logSampler{rate: uint64(rate)}
+	}
+	return samplers
+}
+
+// logSamplerFor returns the sampler for path: an exact LOG_SAMPLE_PATHS
+// entry, else the longest subtree entry containing it, else the default
+func logSamplerFor(path string) *logSampler {
+	if sampler, ok := logSamplePaths[path]; ok {
+		return sampler
+	}
+	var match string
+	for pattern := range logSamplePaths {
+		if strings.HasSuffix(pattern, "/") && strings.HasPrefix(path, pattern) && len(pattern) > len(match) {
+			match = pattern
+		}
+	}
+	if match != "" {
+		return logSamplePaths[match]
+	}
+	return defaultLogSampler
+}
+
+// shouldLogRequest decides whether logRequest logs a finished request,
+// returning the sample rate it was logged at (1 = every request)
+func shouldLogRequest(r *http.Request, status int, latency time.Duration) (bool, uint64) {
+	if status >= 400 || latency >= logSlowRequestThreshold {
+		return true, 1
+	}
+	sampler := logSamplerFor(r.URL.Path)
+	return sampler.sample(), max(sampler.rate, 1)
+}
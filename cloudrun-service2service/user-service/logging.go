@@ -0,0 +1,319 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/compute/metadata"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+// debugLogging enables verbose logs (LOG_LEVEL=debug) that are too noisy for production
+var debugLogging = strings.EqualFold(os.Getenv("LOG_LEVEL"), "debug")
+
+// logger writes one JSON object per line to stdout in the shape Cloud Logging
+// parses into structured entries (severity, message, time). It's also installed
+// as the slog/log default so library output ends up in the same format.
+var logger = newLogger()
+
+func newLogger() *slog.Logger {
+	level := slog.LevelInfo
+	if debugLogging {
+		level = slog.LevelDebug
+	}
+
+	handler := slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
+		Level:       level,
+		ReplaceAttr: cloudLoggingAttr,
+	})
+	l := slog.New(traceHandler{handler})
+	slog.SetDefault(l)
+	return l
+}
+
+// cloudLoggingAttr renames slog's built-in keys to the ones Cloud Logging expects
+func cloudLoggingAttr(groups []string, a slog.Attr) slog.Attr {
+	if len(groups) > 0 {
+		return a
+	}
+	switch a.Key {
+	case slog.LevelKey:
+		a.Key = "severity"
+		if level, ok := a.Value.Any().(slog.Level); ok && level == slog.LevelWarn {
+			// Cloud Logging's name for WARN
+			a.Value = slog.StringValue("WARNING")
+		}
+	case slog.MessageKey:
+		a.Key = "message"
+	}
+	return a
+}
+
+// traceHandler adds the request's trace and correlation ID (if any) to every
+// record logged with a request context, so logs group under the trace in Cloud Logging
+type traceHandler struct {
+	slog.Handler
+}
+
+func (h traceHandler) Handle(ctx context.Context, r slog.Record) error {
+	trace, ok := ctx.Value(traceKey{}).(traceContext)
+	// With tracing enabled, link entries to the exported span instead
+	if span := oteltrace.SpanContextFromContext(ctx); span.IsValid() {
+		trace = traceContext{TraceID: span.TraceID().String(), SpanID: span.SpanID().String(), Sampled: span.IsSampled()}
+		ok = true
+	}
+	if ok {
+		r.AddAttrs(slog.String("logging.googleapis.com/trace", trace.resource()))
+		if trace.SpanID != "" {
+			r.AddAttrs(slog.String("logging.googleapis.com/spanId", trace.SpanID))
+		}
+		r.AddAttrs(slog.Bool("logging.googleapis.com/trace_sampled", trace.Sampled))
+	}
+	if requestID := requestIDFromContext(ctx); requestID != "-" {
+		r.AddAttrs(slog.String("request_id", requestID))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h traceHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return traceHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h traceHandler) WithGroup(name string) slog.Handler {
+	return traceHandler{h.Handler.WithGroup(name)}
+}
+
+// traceKey is the context key for the request's Cloud Trace context
+type traceKey struct{}
+
+// traceContext is the parsed X-Cloud-Trace-Context header ("TRACE_ID/SPAN_ID;o=1")
+type traceContext struct {
+	TraceID string
+	SpanID  string
+	Sampled bool
+}
+
+// parseTraceContext parses an X-Cloud-Trace-Context header value
+func parseTraceContext(header string) (traceContext, bool) {
+	if header == "" {
+		return traceContext{}, false
+	}
+	value, options, _ := strings.Cut(header, ";")
+	traceID, spanID, _ := strings.Cut(value, "/")
+	if traceID == "" {
+		return traceContext{}, false
+	}
+
+	trace := traceContext{TraceID: traceID, Sampled: options == "o=1"}
+	// The header carries the span ID in decimal; Cloud Logging wants 16 hex digits
+	if span, err := strconv.ParseUint(spanID, 10, 64); err == nil {
+		trace.SpanID = fmt.Sprintf("%016x", span)
+	}
+	return trace, true
+}
+
+// resource returns the trace in the projects/PROJECT_ID/traces/TRACE_ID form
+// Cloud Logging needs to link entries to Cloud Trace
+func (t traceContext) resource() string {
+	if project := logProjectID(); project != "" {
+		return "projects/" + project + "/traces/" + t.TraceID
+	}
+	return t.TraceID
+}
+
+// logProjectID is the project used in trace resource names: GOOGLE_CLOUD_PROJECT
+// if set, otherwise looked up once from the metadata server when running on GCP
+var logProjectID = sync.OnceValue(func() string {
+	if project := os.Getenv("GOOGLE_CLOUD_PROJECT"); project != "" {
+		return project
+	}
+	if !metadata.OnGCE() {
+		return ""
+	}
+	project, err := metadata.NewClient(metadataClient).ProjectID()
+	if err != nil {
+		return ""
+	}
+	return project
+})
+
+// traceHeaders are the incoming trace headers forwarded on outbound calls so
+// the downstream spans join the caller's trace instead of starting a new one
+var traceHeaders = []string{"X-Cloud-Trace-Context", "Traceparent", "Tracestate"}
+
+// traceHeadersKey is the context key for the incoming request's trace headers
+type traceHeadersKey struct{}
+
+// withTraceHeaders returns a copy of ctx carrying the trace headers present in h
+func withTraceHeaders(ctx context.Context, h http.Header) context.Context {
+	forwarded := make(http.Header)
+	for _, name := range traceHeaders {
+		if value := h.Get(name); value != "" {
+			forwarded.Set(name, value)
+		}
+	}
+	if len(forwarded) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, traceHeadersKey{}, forwarded)
+}
+
+// propagateTraceHeaders copies the trace headers stored in ctx onto an outbound request
+func propagateTraceHeaders(ctx context.Context, req *http.Request) {
+	forwarded, _ := ctx.Value(traceHeadersKey{}).(http.Header)
+	for name, values := range forwarded {
+		req.Header[name] = values
+	}
+}
+
+// requestIDKey is the context key for the request's correlation ID
+type requestIDKey struct{}
+
+// generatedRequestIDKey marks a correlation ID we generated because the
+// caller didn't send one
+type generatedRequestIDKey struct{}
+
+// maxRequestIDLength bounds caller-supplied correlation IDs, which end up in
+// every log line for the request
+const maxRequestIDLength = 128
+
+// withRequestID returns a copy of ctx carrying the given correlation ID
+func withRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// ensureRequestID returns a copy of ctx carrying the caller's X-Request-Id,
+// or a new UUID if it's missing or unusable, along with the ID
+func ensureRequestID(ctx context.Context, header string) (context.Context, string) {
+	if header != "" && len(header) <= maxRequestIDLength && isPrintableASCII(header) {
+		return withRequestID(ctx, header), header
+	}
+	requestID, err := newUUID()
+	if err != nil {
+		return ctx, "-"
+	}
+	ctx = context.WithValue(ctx, generatedRequestIDKey{}, true)
+	return withRequestID(ctx, requestID), requestID
+}
+
+// requestIDGenerated reports whether ctx's correlation ID was generated here
+// rather than sent by the caller
+func requestIDGenerated(ctx context.Context) bool {
+	generated, _ := ctx.Value(generatedRequestIDKey{}).(bool)
+	return generated
+}
+
+// propagateRequestID sets X-Request-Id on an outbound request so the call can
+// be found in the downstream service's logs under the same ID
+func propagateRequestID(ctx context.Context, req *http.Request) {
+	if requestID := requestIDFromContext(ctx); requestID != "-" {
+		req.Header.Set("X-Request-Id", requestID)
+	}
+}
+
+func isPrintableASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < 0x21 || s[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// requestIDFromContext returns the correlation ID stored in ctx, or "-" if none
+func requestIDFromContext(ctx context.Context) string {
+	if requestID, ok := ctx.Value(requestIDKey{}).(string); ok && requestID != "" {
+		return requestID
+	}
+	return "-"
+}
+
+// statusRecorder captures the status code a handler writes, for request logging
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer (e.g. to flush)
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// Flush passes flushes through so streamed responses aren't held back
+func (r *statusRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// logRequest is a middleware that logs each request with its method, path,
+// status code and latency as structured fields. Successful requests may be
+// sampled; see LOG_SAMPLE_RATE.
+func logRequest(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		// Carry the caller's correlation ID (or a new one) through every log
+		// line and outbound call, and echo it so the caller can quote it
+		ctx, requestID := ensureRequestID(r.Context(), r.Header.Get("X-Request-Id"))
+		if requestID != "-" {
+			w.Header().Set("X-Request-Id", requestID)
+		}
+		// Cloud Run sets X-Cloud-Trace-Context on every request it forwards
+		if trace, ok := parseTraceContext(r.Header.Get("X-Cloud-Trace-Context")); ok {
+			ctx = context.WithValue(ctx, traceKey{}, trace)
+		}
+		ctx = withTraceHeaders(ctx, r.Header)
+		r = r.WithContext(ctx)
+
+		recorder := &statusRecorder{ResponseWriter: w}
+		handler.ServeHTTP(recorder, r)
+
+		status := recorder.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		latency := time.Since(start)
+		observeRequest(r, status, latency)
+		recordRequestOutcome(r.URL.Path, status)
+		logged, sampleRate := shouldLogRequest(r, status, latency)
+		if !logged {
+			return
+		}
+		attrs := []any{
+			"method", r.Method,
+			// Escaped, so decoded control characters can't break up the line
+			"path", r.URL.EscapedPath(),
+			"status", status,
+			"latency_ms", latency.Milliseconds(),
+			"user_agent", r.UserAgent(),
+			// Never log the token itself - verification happens in authenticate
+			"authorization_present", r.Header.Get("Authorization") != "",
+		}
+		if sampleRate > 1 {
+			attrs = append(attrs, "sample_rate", sampleRate)
+		}
+		logger.InfoContext(ctx, "request completed", attrs...)
+	})
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestOutboundRequestLogsResolvedAudience(t *testing.T) {
+	logs := captureLogs(t, slog.LevelDebug)
+	backend := newBackend(t, func(w http.ResponseWriter, r *http.Request) {})
+	audience := "https://order-service-xxxxx-uc.a.run.app"
+	setForTest(t, &audienceOverrides, map[string]string{backend.URL: audience})
+	token := useCachedIDToken(t, audience)
+
+	if _, err := makeAuthenticatedGet(context.Background(), backend.URL+"/orders/user/user-001"); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`"severity":"DEBUG"`, `"audience":"` + audience + `"`, `"token_source":"cache"`} {
+		if !strings.Contains(logs.String(), want) {
+			t.Errorf("logs don't contain %s:\n%s", want, logs)
+		}
+	}
+	if strings.Contains(logs.String(), token) {
+		t.Error("the ID token was logged")
+	}
+}
+
+func TestOutboundAudienceNotLoggedAtInfo(t *testing.T) {
+	logs := captureLogs(t, slog.LevelInfo)
+	backend := newBackend(t, func(w http.ResponseWriter, r *http.Request) {})
+	useCachedIDToken(t, backend.URL)
+
+	if _, err := makeAuthenticatedGet(context.Background(), backend.URL+"/orders"); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(logs.String(), "audience") {
+		t.Errorf("audience logged without LOG_LEVEL=debug:\n%s", logs)
+	}
+}
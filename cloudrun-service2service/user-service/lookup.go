@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// UserCandidate is one of several users matching an ambiguous lookup
+type UserCandidate struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Href string `json:"href"`
+}
+
+// MultipleChoicesResponse represents a 300 response for an ambiguous lookup
+type MultipleChoicesResponse struct {
+	Service    string          `json:"service"`
+	Message    string          `json:"message"`
+	Candidates []UserCandidate `json:"candidates"`
+}
+
+// usersByNameHandler handles GET /users:byName?name=. It returns the single
+// matching user, 300 Multiple Choices listing the candidates when several
+// match (rather than picking one arbitrarily), or 404 when none do. Names are
+// compared the same way as for UNIQUE_NAMES (case-insensitive by default).
+func usersByNameHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, r, http.MethodGet)
+		return
+	}
+
+	name := strings.TrimSpace(r.URL.Query().Get("name"))
+	if name == "" {
+		writeError(w, r, http.StatusBadRequest, "name_param_required")
+		return
+	}
+
+	all, err := userStore.List(r.Context())
+	if err != nil {
+		writeStoreFailure(w, r, err)
+		return
+	}
+	var matches []User
+	for _, user := range all {
+		if !user.Deleted && sameName(user.Name, name) {
+			matches = append(matches, user)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		writeError(w, r, http.StatusNotFound, "user_name_not_found", name)
+	case 1:
+		match := matches[0]
+		recordAccess(match.ID, time.Now())
+		if includeAccess(r) {
+			match.LastAccessedAt = lastAccessedAt(match.ID)
+		}
+		w.Header().Set("Content-Location", selfURL("/users/"+match.ID))
+		writeJSON(w, http.StatusOK, UsersResponse{
+			Service: "user-service (Go)",
+			User:    &match,
+		})
+	default:
+		candidates := make([]UserCandidate, len(matches))
+		for i, user := range matches {
+			candidates[i] = UserCandidate{ID: user.ID, Name: user.Name, Href: selfURL("/users/" + user.ID)}
+			w.Header().Add("Link", fmt.Sprintf("<%s>; rel=\"alternate\"", candidates[i].Href))
+		}
+		writeJSON(w, http.StatusMultipleChoices, MultipleChoicesResponse{
+			Service:    "user-service (Go)",
+			Message:    fmt.Sprintf("%d users are named '%s' - choose one by ID", len(matches), name),
+			Candidates: candidates,
+		})
+	}
+}
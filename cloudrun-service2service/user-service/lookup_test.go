@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestUsersByNameSingleMatch(t *testing.T) {
+	useTestStore(t)
+	server := newTestServer(t)
+
+	resp, body := doRequest(t, server, http.MethodGet, "/users:byName?name="+url.QueryEscape("bob smith"), "")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", resp.StatusCode, body)
+	}
+	var users UsersResponse
+	if err := json.Unmarshal([]byte(body), &users); err != nil || users.User == nil || users.User.ID != "user-002" {
+		t.Fatalf("body = %s, want user-002", body)
+	}
+	if location := resp.Header.Get("Content-Location"); !strings.HasSuffix(location, "/users/user-002") {
+		t.Errorf("Content-Location = %q, want the user's URL", location)
+	}
+}
+
+func TestUsersByNameAmbiguous(t *testing.T) {
+	useTestStore(t)
+	setForTest(t, &uniqueNames, false)
+	created := createUserForTest(t, `{"name":"Bob Smith","email":"bob.other@example.com","role":"viewer"}`, http.StatusCreated)
+	server := newTestServer(t)
+
+	resp, body := doRequest(t, server, http.MethodGet, "/users:byName?name="+url.QueryEscape("Bob Smith"), "")
+	if resp.StatusCode != http.StatusMultipleChoices {
+		t.Fatalf("status = %d, want 300: %s", resp.StatusCode, body)
+	}
+	var choices MultipleChoicesResponse
+	if err := json.Unmarshal([]byte(body), &choices); err != nil {
+		t.Fatal(err)
+	}
+	if len(choices.Candidates) != 2 || len(resp.Header.Values("Link")) != 2 {
+		t.Fatalf("candidates = %+v, Link %v; want both Bobs", choices.Candidates, resp.Header.Values("Link"))
+	}
+	ids := map[string]bool{choices.Candidates[0].ID: true, choices.Candidates[1].ID: true}
+	if !ids["user-002"] || !ids[created.User.ID] {
+		t.Errorf("candidates = %+v, want user-002 and %s", choices.Candidates, created.User.ID)
+	}
+}
+
+func TestUsersByNameErrors(t *testing.T) {
+	useTestStore(t)
+	server := newTestServer(t)
+
+	for _, tc := range []struct {
+		method, path string
+		want         int
+	}{
+		{http.MethodGet, "/users:byName", http.StatusBadRequest},
+		{http.MethodGet, "/users:byName?name=Nobody", http.StatusNotFound},
+		{http.MethodPost, "/users:byName?name=Bob", http.StatusMethodNotAllowed},
+	} {
+		if resp, body := doRequest(t, server, tc.method, tc.path, ""); resp.StatusCode != tc.want {
+			t.Errorf("%s %s: status = %d, want %d: %s", tc.method, tc.path, resp.StatusCode, tc.want, body)
+		}
+	}
+}
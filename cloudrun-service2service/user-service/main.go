@@ -11,26 +11,47 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"log"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
+	"os/signal"
+	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	"golang.org/x/oauth2/google"
+	"golang.org/x/text/language"
 )
 
 // User represents a user in the system
 type User struct {
-	ID        string    `json:"id"`
-	Name      string    `json:"name"`
-	Email     string    `json:"email"`
-	Role      string    `json:"role"`
-	CreatedAt time.Time `json:"created_at"`
+	ID        string     `json:"id"`
+	Name      string     `json:"name"`
+	Email     string     `json:"email"`
+	Role      string     `json:"role"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+
+	// Deleted marks a soft-deleted user, hidden unless ?include_deleted=true
+	// and restorable with POST /users/{id}/restore
+	Deleted   bool       `json:"deleted,omitempty"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+
+	// LastAccessedAt is tracked separately from the store and only
+	// included in responses when requested with ?include_access=true
+	LastAccessedAt *time.Time `json:"last_accessed_at,omitempty"`
+
+	// projection limits the fields written for a ?fields= request
+	projection *userProjection
 }
 
 // HealthResponse represents the health check response
@@ -43,11 +64,18 @@ type HealthResponse struct {
 
 // UsersResponse represents the response for user endpoints
 type UsersResponse struct {
-	Service string `json:"service"`
-	Count   int    `json:"count,omitempty"`
-	Users   []User `json:"users,omitempty"`
-	User    *User  `json:"user,omitempty"`
-	Message string `json:"message,omitempty"`
+	Service  string   `json:"service"`
+	Count    int      `json:"count,omitempty"`
+	Users    []User   `json:"users,omitempty"`
+	User     *User    `json:"user,omitempty"`
+	Message  string   `json:"message,omitempty"`
+	Warnings []string `json:"warnings,omitempty"`
+
+	// Pagination is set on list responses
+	Pagination *Pagination `json:"pagination,omitempty"`
+
+	// Changes lists changed fields on update responses when requested
+	Changes map[string]FieldChange `json:"changes,omitempty"`
 }
 
 // UserWithOrders represents a user along with their orders
@@ -56,40 +84,92 @@ type UserWithOrders struct {
 	User    *User       `json:"user"`
 	Orders  interface{} `json:"orders"`
 	Flow    string      `json:"flow"`
+
+	// Warnings explains a partial response, e.g. orders: null after a timeout
+	Warnings []string `json:"warnings,omitempty"`
+	// Cached is set when the orders came from the orders cache
+	Cached bool `json:"cached,omitempty"`
 }
 
-// ErrorResponse represents an error response
+// UserPatch represents a partial update; only non-nil fields are applied
+type UserPatch struct {
+	ID    *string `json:"id"`
+	Name  *string `json:"name"`
+	Email *string `json:"email"`
+	Role  *string `json:"role"`
+}
+
+// ErrorResponse represents an error response (see errors.go)
 type ErrorResponse struct {
-	Error string `json:"error"`
-}
-
-// In-memory user storage (simulating a database)
-var users = []User{
-	{
-		ID:        "user-001",
-		Name:      "Alice Johnson",
-		Email:     "alice@example.com",
-		Role:      "admin",
-		CreatedAt: time.Now().Add(-30 * 24 * time.Hour),
-	},
-	{
-		ID:        "user-002",
-		Name:      "Bob Smith",
-		Email:     "bob@example.com",
-		Role:      "developer",
-		CreatedAt: time.Now().Add(-20 * 24 * time.Hour),
-	},
-	{
-		ID:        "user-003",
-		Name:      "Carol Williams",
-		Email:     "carol@example.com",
-		Role:      "viewer",
-		CreatedAt: time.Now().Add(-10 * 24 * time.Hour),
-	},
-}
-
-// ORDER_SERVICE_URL is the URL of the Order Service for service-to-service calls
-var ORDER_SERVICE_URL = os.Getenv("ORDER_SERVICE_URL")
+	Code   string            `json:"code"`
+	Error  string            `json:"error"`
+	Fields map[string]string `json:"fields,omitempty"`
+	// Details lists each invalid field with its own code, in field order
+	Details []ErrorDetail `json:"details,omitempty"`
+
+	// Errors lists each failed dependency call behind a 5xx, with its own code
+	Errors []UpstreamError `json:"errors,omitempty"`
+
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// deterministicDemo seeds demo users with fixed timestamps (DETERMINISTIC_DEMO=true)
+// so tests and screenshots are reproducible
+var deterministicDemo = envBool("DETERMINISTIC_DEMO", false)
+
+// demoEpoch is the fixed "now" the demo data is relative to in deterministic mode
+var demoEpoch = time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// seedUsers returns the initial demo users. By default their CreatedAt is
+// relative to the current time; in deterministic mode it is relative to demoEpoch.
+func seedUsers() []User {
+	now := time.Now().UTC()
+	if deterministicDemo {
+		now = demoEpoch
+	}
+
+	return []User{
+		{
+			ID:        "user-001",
+			Name:      "Alice Johnson",
+			Email:     "alice@example.com",
+			Role:      "admin",
+			CreatedAt: now.Add(-30 * 24 * time.Hour),
+		},
+		{
+			ID:        "user-002",
+			Name:      "Bob Smith",
+			Email:     "bob@example.com",
+			Role:      "developer",
+			CreatedAt: now.Add(-20 * 24 * time.Hour),
+		},
+		{
+			ID:        "user-003",
+			Name:      "Carol Williams",
+			Email:     "carol@example.com",
+			Role:      "viewer",
+			CreatedAt: now.Add(-10 * 24 * time.Hour),
+		},
+	}
+}
+
+// shutdownTimeout is how long to wait for in-flight requests on SIGTERM (SHUTDOWN_TIMEOUT).
+// Cloud Run allows 10 seconds between SIGTERM and SIGKILL.
+var shutdownTimeout = envDuration("SHUTDOWN_TIMEOUT", 10*time.Second)
+
+// Server timeout configuration, so slow or stalled clients can't hold
+// connections open indefinitely. SERVER_READ_HEADER_TIMEOUT bounds reading
+// the headers, SERVER_READ_TIMEOUT the whole request including its body,
+// SERVER_WRITE_TIMEOUT everything from the end of the headers to the end of
+// the response (so it must exceed the longest route timeout, 60s), and
+// SERVER_IDLE_TIMEOUT how long a keep-alive connection waits for its next
+// request. 0 disables a timeout.
+var (
+	serverReadHeaderTimeout = envDuration("SERVER_READ_HEADER_TIMEOUT", 10*time.Second)
+	serverReadTimeout       = envDuration("SERVER_READ_TIMEOUT", 30*time.Second)
+	serverWriteTimeout      = envDuration("SERVER_WRITE_TIMEOUT", 90*time.Second)
+	serverIdleTimeout       = envDuration("SERVER_IDLE_TIMEOUT", 120*time.Second)
+)
 
 func main() {
 	port := os.Getenv("PORT")
@@ -97,140 +177,452 @@ func main() {
 		port = "8080"
 	}
 
+	// Cloud Run sends SIGTERM before stopping an instance; ctx is cancelled
+	// when it (or Ctrl+C locally) arrives
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
+
+	if storeBackend != "memory" {
+		store, err := newUserStore(ctx)
+		if err != nil {
+			logger.Error("Failed to create user store", "backend", storeBackend, "error", err)
+			os.Exit(1)
+		}
+		userStore = store
+	}
+	logger.Info("User store configured", "backend", storeBackend)
+	if storeBackend == "memory" && maxUsers > 0 {
+		if err := checkMaxUsersPolicy(); err != nil {
+			logger.Error("Invalid store capacity configuration", "error", err)
+			os.Exit(1)
+		}
+		logger.Info("User store capacity limited", "max_users", maxUsers, "policy", maxUsersPolicy)
+	}
+
+	if err := checkOrderServiceMode(); err != nil {
+		logger.Error("Invalid Order Service configuration", "error", err)
+		os.Exit(1)
+	}
 	// Log ORDER_SERVICE_URL for debugging
-	if ORDER_SERVICE_URL != "" {
-		log.Printf("Order Service URL configured: %s", ORDER_SERVICE_URL)
+	if mockOrderService() {
+		logger.Warn("ORDER_SERVICE_MODE=mock - orders are canned data, the Order Service is never called")
+	} else if orderURL := getOrderServiceURL(); orderURL != "" {
+		logger.Info("Order Service URL configured", "url", orderURL)
 	} else {
-		log.Printf("ORDER_SERVICE_URL not configured - user-orders endpoint will be limited")
+		logger.Warn("ORDER_SERVICE_URL not configured - user-orders endpoint will be limited")
 	}
 
-	// Set up routes
-	http.HandleFunc("/", healthHandler)
-	http.HandleFunc("/health", healthHandler)
-	http.HandleFunc("/users", usersHandler)
-	http.HandleFunc("/users/", userByIDHandler)
+	if impersonateServiceAccount != "" {
+		logger.Info("Outbound ID tokens will impersonate a service account", "service_account", impersonateServiceAccount)
+	}
 
-	log.Printf("User Service (Go) starting on port %s", port)
-	if err := http.ListenAndServe(":"+port, logRequest(http.DefaultServeMux)); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+	if debugLogBodies {
+		logger.Warn("DEBUG_LOG_BODIES is on - request and response bodies are logged; don't use it in production", "max_bytes", debugLogBodyMaxBytes)
+	}
+	if basePath != "" {
+		logger.Info("Serving routes under a base path", "base_path", basePath)
+	}
+	if enableH2C {
+		logger.Info("Serving HTTP/2 cleartext (h2c) alongside HTTP/1.1")
 	}
-}
 
-// logRequest is a middleware that logs incoming requests
-func logRequest(handler http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		log.Printf("%s %s - User-Agent: %s", r.Method, r.URL.Path, r.UserAgent())
-		
-		// Log authentication info (for debugging)
-		authHeader := r.Header.Get("Authorization")
-		if authHeader != "" {
-			log.Printf("  Authorization header present (Bearer token)")
+	// Fail fast on configuration that would make requests fail
+	if startupChecks {
+		if problems := runStartupChecks(ctx); len(problems) > 0 {
+			for _, problem := range problems {
+				logger.Error("Startup check failed", "error", problem)
+			}
+			os.Exit(1)
 		}
-		
-		handler.ServeHTTP(w, r)
-	})
+	}
+
+	// Set up routes (see routes.go for each route's policy)
+	registerRoutes(http.DefaultServeMux, routes)
+	checkOpenAPIRoutes(http.DefaultServeMux)
+
+	if requireAuth {
+		if authAudience == "" {
+			logger.Warn("REQUIRE_AUTH is set but AUTH_AUDIENCE is empty - token audiences will not be checked")
+		} else {
+			logger.Info("Verifying incoming OIDC tokens", "audience", authAudience)
+		}
+	}
+
+	if err := checkRoleEnforcement(); err != nil {
+		logger.Error("Invalid role configuration", "error", err)
+		os.Exit(1)
+	}
+
+	if signResponses && responseSigningKey == "" {
+		logger.Warn("SIGN_RESPONSES is set but RESPONSE_SIGNING_KEY is empty - responses will not be signed")
+	}
+
+	// Hot-reload outbound config from a mounted file if configured
+	if configFile != "" && configReloadInterval > 0 {
+		logger.Info("Watching config file for changes", "path", configFile, "interval", configReloadInterval.String())
+		go watchConfigFile(ctx, configFile, configReloadInterval)
+	}
+
+	// Sample concurrency gauges on a fixed interval
+	if metricsSampleInterval > 0 {
+		go runConcurrencySampler(ctx, metricsSampleInterval)
+	}
+
+	// Periodically snapshot the in-memory store if configured
+	if snapshotDir != "" && snapshotInterval > 0 {
+		logger.Info("Snapshotting users periodically", "dir", snapshotDir, "interval", snapshotInterval.String())
+		go runPeriodicSnapshots(ctx, snapshotDir, snapshotInterval)
+	}
+
+	// Export spans for requests and outbound calls if TRACE_EXPORTER is set
+	shutdownTracing, err := setupTracing(ctx)
+	if err != nil {
+		logger.Error("Failed to set up tracing", "exporter", traceExporter, "error", err)
+		os.Exit(1)
+	}
+	if tracingEnabled() {
+		logger.Info("Tracing enabled", "exporter", traceExporter, "sample_ratio", traceSampleRatio)
+	}
+
+	// Requests (and the outbound calls they make) derive from requestCtx. It's
+	// only cancelled if draining times out, so in-flight work can finish first.
+	requestCtx, cancelRequests := context.WithCancel(context.Background())
+	defer cancelRequests()
+
+	server := &http.Server{
+		Addr:        ":" + port,
+		Handler:     Chain(serverMiddleware...)(http.DefaultServeMux),
+		BaseContext: func(net.Listener) context.Context { return requestCtx },
+
+		ReadHeaderTimeout: serverReadHeaderTimeout,
+		ReadTimeout:       serverReadTimeout,
+		WriteTimeout:      serverWriteTimeout,
+		IdleTimeout:       serverIdleTimeout,
+	}
+
+	serverErr := make(chan error, 1)
+	go func() {
+		logger.Info("User Service (Go) starting", "port", port, "version", version, "commit", commit)
+		serverErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serverErr:
+		logger.Error("Failed to start server", "error", err)
+		os.Exit(1)
+	case <-ctx.Done():
+	}
+
+	// Fail readiness for the rest of the drain, in case probes still arrive
+	if since := drainingSince(); since != nil {
+		logger.Info("Shutdown signal received - already draining", "draining_for", time.Since(*since).Round(time.Millisecond).String(), "in_flight", inFlightRequests.Load())
+	}
+	startDraining(server)
+	logger.Info("Shutdown signal received - draining in-flight requests", "timeout", shutdownTimeout.String())
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		// Drain timed out: abort remaining outbound calls and close connections
+		logger.Warn("Graceful shutdown timed out - aborting remaining requests", "error", err)
+		cancelRequests()
+		server.Close()
+	}
+	if closer, ok := userStore.(io.Closer); ok {
+		closer.Close()
+	}
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		logger.Warn("Failed to flush traces", "error", err)
+	}
+	logger.Info("User Service (Go) stopped")
 }
 
-// getIDToken fetches an OIDC ID token for the given audience (target service URL)
-func getIDToken(ctx context.Context, audience string) (string, error) {
-	// Use Google's default credentials to get an ID token
-	// This works automatically on Cloud Run with the service's identity
-	tokenSource, err := google.DefaultTokenSource(ctx, audience)
+// getIDToken returns an OIDC ID token for the given audience (target service URL),
+// reusing a cached token until it's close to expiry.
+// It also reports where the token came from ("cache", "impersonation",
+// "metadata" or "access-token-fallback") so callers can log it without ever
+// logging the token itself.
+func getIDToken(ctx context.Context, audience string) (string, string, error) {
+	if token, ok := cachedIDToken(audience); ok {
+		return token, "cache", nil
+	}
+
+	token, source, err := fetchIDToken(ctx, audience)
 	if err != nil {
-		return "", fmt.Errorf("failed to get token source: %v", err)
+		return "", "", err
+	}
+
+	// Only real ID tokens carry an exp claim we can cache against
+	if source == "metadata" || source == "impersonation" {
+		storeIDToken(audience, token)
+	}
+	return token, source, nil
+}
+
+// fetchIDToken fetches a fresh OIDC ID token for the given audience, by
+// impersonation if configured and otherwise from the metadata server
+func fetchIDToken(ctx context.Context, audience string) (string, string, error) {
+	// An explicitly configured service account takes precedence (see impersonation.go)
+	if impersonateServiceAccount != "" {
+		return fetchImpersonatedIDToken(ctx, audience)
 	}
 
-	// For ID tokens, we need to use the IDTokenSource
-	// The google.DefaultTokenSource returns access tokens, not ID tokens
-	// We need to use the metadata server directly for ID tokens
-	
+	// google.DefaultTokenSource returns access tokens, not ID tokens, so ID
+	// tokens come from the metadata server directly. This works automatically
+	// on Cloud Run with the service's identity.
+
 	// Create a request to the metadata server
 	metadataURL := fmt.Sprintf(
 		"http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/identity?audience=%s",
 		audience,
 	)
-	
-	req, err := http.NewRequestWithContext(ctx, "GET", metadataURL, nil)
+
+	metadataCtx, cancel := context.WithTimeout(ctx, metadataRequestTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(metadataCtx, "GET", metadataURL, nil)
 	if err != nil {
-		return "", fmt.Errorf("failed to create metadata request: %v", err)
+		return "", "", fmt.Errorf("failed to create metadata request: %v", err)
 	}
 	req.Header.Set("Metadata-Flavor", "Google")
-	
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Do(req)
+
+	resp, err := metadataClient.Do(req)
 	if err != nil {
-		// If metadata server is not available (local dev), try using access token
-		log.Printf("Metadata server not available, falling back to access token: %v", err)
-		token, err := tokenSource.Token()
-		if err != nil {
-			return "", fmt.Errorf("failed to get token: %v", err)
+		// The caller gave up (or its deadline passed): don't carry on with the fallback
+		if ctx.Err() != nil {
+			return "", "", fmt.Errorf("metadata request cancelled: %w", ctx.Err())
 		}
-		return token.AccessToken, nil
+		// If metadata server is not available (local dev), try using access token
+		logger.WarnContext(ctx, "Metadata server not available, falling back to access token", "error", err)
+		return fetchAccessTokenFallback(ctx, audience)
 	}
 	defer resp.Body.Close()
-	
+
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("metadata server returned %d: %s", resp.StatusCode, string(body))
+		return "", "", fmt.Errorf("metadata server returned %d: %s", resp.StatusCode, string(body))
 	}
-	
+
 	idToken, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", fmt.Errorf("failed to read ID token: %v", err)
+		return "", "", fmt.Errorf("failed to read ID token: %v", err)
+	}
+
+	return string(idToken), "metadata", nil
+}
+
+// fetchAccessTokenFallback gets an access token from Google's default
+// credentials for local development, where there's no metadata server.
+// The token source makes its HTTP calls with ctx, so cancellation still applies.
+func fetchAccessTokenFallback(ctx context.Context, audience string) (string, string, error) {
+	tokenSource, err := google.DefaultTokenSource(ctx, audience)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to get token source: %v", err)
+	}
+	token, err := tokenSource.Token()
+	if err != nil {
+		if ctx.Err() != nil {
+			return "", "", fmt.Errorf("failed to get token: %w", ctx.Err())
+		}
+		return "", "", fmt.Errorf("failed to get token: %v", err)
+	}
+	return token.AccessToken, "access-token-fallback", nil
+}
+
+// makeAuthenticatedRequest makes an HTTP request to another service with OIDC
+// authentication (or HMAC for hmac backends). body may be nil; headers are
+// added to the request, with Content-Type defaulting to application/json, but
+// can't override the credentials. It returns the response's status and body.
+// A non-2xx response is also returned as a *statusError, so callers that only
+// care about success can just check err, while others can act on the status.
+// Calls other than GET carry an Idempotency-Key (the caller's, if headers
+// has one) so they can be retried safely.
+func makeAuthenticatedRequest(ctx context.Context, method, url string, body io.Reader, headers http.Header) (int, []byte, error) {
+	var reqBody []byte
+	if body != nil {
+		var err error
+		if reqBody, err = io.ReadAll(body); err != nil {
+			return 0, nil, fmt.Errorf("failed to read request body: %v", err)
+		}
 	}
-	
-	return string(idToken), nil
+	return doAuthenticatedRequest(ctx, method, url, reqBody, headers)
 }
 
-// makeAuthenticatedRequest makes an HTTP request to another service with OIDC authentication
-func makeAuthenticatedRequest(ctx context.Context, url string) ([]byte, error) {
-	// Extract the base URL for the audience
+// makeAuthenticatedGet GETs url from another service, for callers that only
+// need the body of a successful response
+func makeAuthenticatedGet(ctx context.Context, url string) ([]byte, error) {
+	_, body, err := makeAuthenticatedRequest(ctx, http.MethodGet, url, nil, nil)
+	return body, err
+}
+
+// doAuthenticatedRequest makes an authenticated call through the backend's circuit breaker
+func doAuthenticatedRequest(ctx context.Context, method, url string, reqBody []byte, headers http.Header) (int, []byte, error) {
+	// The backend is the target's base URL; it keys the circuit breaker,
+	// concurrency cap and metrics, and is the token audience unless overridden
 	parts := strings.Split(url, "/")
 	if len(parts) < 3 {
-		return nil, fmt.Errorf("invalid URL: %s", url)
+		return 0, nil, fmt.Errorf("invalid URL: %s", url)
 	}
-	audience := parts[0] + "//" + parts[2]
-	
-	// Get OIDC ID token
-	idToken, err := getIDToken(ctx, audience)
+	backend := parts[0] + "//" + parts[2]
+
+	// One child span covers the call, including any retries
+	ctx, endSpan := startOutboundSpan(ctx, method, url, backend)
+
+	// Fail fast while the backend's circuit is open rather than waiting out
+	// timeouts and retries against a service that's down
+	done, err := outboundBreakers.allow(backend)
+	if err != nil {
+		endSpan(err)
+		return 0, nil, err
+	}
+	status, body, err := retryAuthenticatedRequest(ctx, method, url, backend, reqBody, headers)
+	done(breakerResultFor(ctx, err))
+	endSpan(err)
+	return status, body, err
+}
+
+// retryAuthenticatedRequest makes an authenticated call, retrying where it's safe to
+func retryAuthenticatedRequest(ctx context.Context, method, url, backend string, reqBody []byte, headers http.Header) (int, []byte, error) {
+	// Respect the per-backend concurrency cap so we don't overwhelm the target
+	release, err := outboundLimiter.acquire(ctx, backend)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get ID token: %v", err)
+		return 0, nil, err
+	}
+	defer release()
+
+	// The key is derived once so every retry of this call carries the same one
+	idempotencyKey := headers.Get("Idempotency-Key")
+	if idempotencyKey == "" && method != http.MethodGet && outboundIdempotencyKeys {
+		idempotencyKey = outboundIdempotencyKey(ctx, method, url, reqBody)
+	}
+
+	// Retry on 5xx and network errors (e.g. during a cold start), but only
+	// GETs and calls the backend can deduplicate by idempotency key
+	refreshedToken := false
+	for attempt := 0; ; attempt++ {
+		status, body, err := sendAuthenticatedRequest(ctx, method, url, backend, reqBody, headers, idempotencyKey)
+		observeOutbound(backend, err)
+		if err == nil {
+			return status, body, nil
+		}
+		// A rejected token may be a cached one that expired in flight: the
+		// request wasn't processed, so fetch a fresh token and try once more,
+		// outside the retry budget and whatever the method
+		if !refreshedToken && rejectedCredentials(backend, status) {
+			refreshedToken = true
+			evictIDToken(outboundAudience(backend))
+			logger.WarnContext(ctx, "Outbound request rejected its ID token, retrying with a fresh one",
+				"url", url, "status", status)
+			attempt--
+			continue
+		}
+		if method != http.MethodGet && idempotencyKey == "" {
+			return status, body, err
+		}
+		if attempt >= outboundMaxRetries || !retryable(ctx, err) {
+			return status, body, err
+		}
+
+		delay := retryBackoff(attempt + 1)
+		logger.WarnContext(ctx, "Outbound request failed, retrying",
+			"url", url, "attempt", attempt+1, "max_attempts", outboundMaxRetries+1,
+			"retry_in", delay.String(), "error", err)
+		if err := sleepContext(ctx, delay); err != nil {
+			return 0, nil, fmt.Errorf("request failed: %w", err)
+		}
 	}
-	
+}
+
+// rejectedCredentials reports whether a status from backend means it
+// rejected our OIDC token (HMAC signatures are never cached, so a fresh one
+// wouldn't fare better)
+func rejectedCredentials(backend string, status int) bool {
+	return (status == http.StatusUnauthorized || status == http.StatusForbidden) &&
+		outboundAuthMode(backend) == authModeOIDC
+}
+
+// sendAuthenticatedRequest makes a single authenticated attempt.
+// Credentials are attached per attempt so HMAC nonces are never reused.
+func sendAuthenticatedRequest(ctx context.Context, method, url, backend string, reqBody []byte, headers http.Header, idempotencyKey string) (int, []byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, serviceRequestTimeout)
+	defer cancel()
+
 	// Create request
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(reqBody))
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %v", err)
+		return 0, nil, fmt.Errorf("failed to create request: %v", err)
 	}
-	
-	// Add Authorization header with Bearer token
-	req.Header.Set("Authorization", "Bearer "+idToken)
 	req.Header.Set("Content-Type", "application/json")
-	
+	for name, values := range headers {
+		req.Header[http.CanonicalHeaderKey(name)] = values
+	}
+	if idempotencyKey != "" {
+		req.Header.Set("Idempotency-Key", idempotencyKey)
+	}
+	if err := authorizeOutboundRequest(ctx, req, backend, reqBody); err != nil {
+		return 0, nil, err
+	}
+
 	// Make request
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
+	resp, err := outboundClient.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("request failed: %v", err)
+		logOutboundBodies(ctx, req, reqBody, nil, nil)
+		return 0, nil, fmt.Errorf("request failed: %w", err)
 	}
 	defer resp.Body.Close()
-	
+
 	body, err := io.ReadAll(resp.Body)
+	logOutboundBodies(ctx, req, reqBody, resp, body)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %v", err)
+		return resp.StatusCode, nil, fmt.Errorf("failed to read response: %w", err)
 	}
-	
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("service returned %d: %s", resp.StatusCode, string(body))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, body, &statusError{StatusCode: resp.StatusCode, Body: string(body)}
+	}
+
+	return resp.StatusCode, body, nil
+}
+
+// authorizeOutboundRequest propagates the request's tracing, request ID and
+// propagated values onto req and attaches backend's credentials: an OIDC ID
+// token, or an HMAC signature over body for hmac backends
+func authorizeOutboundRequest(ctx context.Context, req *http.Request, backend string, body []byte) error {
+	propagateTraceHeaders(ctx, req)
+	injectTraceContext(ctx, req)
+	propagateRequestID(ctx, req)
+	applyPropagatedValues(ctx, req)
+
+	url := req.URL.String()
+	if outboundAuthMode(backend) == authModeHMAC {
+		// Non-GCP backends get an HMAC-signed request instead of an OIDC token
+		if err := signRequestHMAC(req, body, outboundHMACSecret); err != nil {
+			return fmt.Errorf("failed to sign request: %v", err)
+		}
+		logger.DebugContext(ctx, "Outbound request", "url", url, "auth", "hmac")
+		return nil
+	}
+
+	// Get OIDC ID token
+	audience := outboundAudience(backend)
+	idToken, tokenSource, err := getIDToken(ctx, audience)
+	if err != nil {
+		return fmt.Errorf("failed to get ID token: %v", err)
 	}
-	
-	return body, nil
+
+	// Log the resolved audience for OIDC debugging - never the token itself
+	logger.DebugContext(ctx, "Outbound request", "url", url, "auth", "oidc",
+		"audience", audience, "token_source", tokenSource, "cached", tokenSource == "cache")
+
+	// Add Authorization header with Bearer token
+	req.Header.Set("Authorization", "Bearer "+idToken)
+	return nil
 }
 
 // healthHandler handles the health check endpoint
 func healthHandler(w http.ResponseWriter, r *http.Request) {
 	if r.URL.Path != "/" && r.URL.Path != "/health" {
-		http.NotFound(w, r)
+		writeError(w, r, http.StatusNotFound, "route_not_found", r.URL.Path)
 		return
 	}
 
@@ -238,10 +630,10 @@ func healthHandler(w http.ResponseWriter, r *http.Request) {
 		Service:  "user-service",
 		Language: "Go",
 		Status:   "healthy",
-		Version:  "1.0.0",
+		Version:  version,
 	}
 
-	writeJSON(w, http.StatusOK, response)
+	writeHealth(w, http.StatusOK, response)
 }
 
 // usersHandler handles the /users endpoint
@@ -249,206 +641,737 @@ func usersHandler(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case http.MethodGet:
 		getAllUsers(w, r)
+	case http.MethodHead:
+		headUsers(w, r)
 	case http.MethodPost:
 		createUser(w, r)
+	case http.MethodOptions:
+		writeOptions(w, http.MethodGet, http.MethodHead, http.MethodPost, http.MethodOptions)
 	default:
-		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{
-			Error: fmt.Sprintf("Method %s not allowed", r.Method),
-		})
+		methodNotAllowed(w, r, http.MethodGet, http.MethodHead, http.MethodPost, http.MethodOptions)
 	}
 }
 
 // userByIDHandler handles the /users/{id} endpoint
 func userByIDHandler(w http.ResponseWriter, r *http.Request) {
-	// Extract user ID from path
-	path := strings.TrimPrefix(r.URL.Path, "/users/")
-	
-	// Check if this is a request for user's orders: /users/{id}/orders
-	if strings.Contains(path, "/orders") {
-		parts := strings.Split(path, "/orders")
-		userID := parts[0]
-		getUserOrders(w, r, userID)
+	userID, subresource, ok := parseUserPath(r.URL.EscapedPath())
+	if !ok {
+		writeError(w, r, http.StatusNotFound, "route_not_found", r.URL.Path)
 		return
 	}
-	
-	if path == "" {
-		writeJSON(w, http.StatusBadRequest, ErrorResponse{
-			Error: "User ID is required",
-		})
+	if userID == "" {
+		writeError(w, r, http.StatusBadRequest, "user_id_required")
+		return
+	}
+	// IDs go into log lines and the Order Service URL, so anything outside
+	// the ID format (encoded slashes, newlines, ...) is rejected up front
+	if err := validateUserID(userID); err != nil {
+		logger.WarnContext(r.Context(), "Rejected invalid user ID", "user_id", loggableUserID(userID))
+		writeError(w, r, http.StatusBadRequest, "user_id_invalid", loggableUserID(userID), userIDPattern.String(), maxUserIDLength)
+		return
+	}
+
+	// Old sequential IDs redirect to their UUIDs during the migration grace period
+	if redirectLegacyID(w, r, userID) {
+		return
+	}
+
+	// Undoing a soft delete: /users/{id}/restore
+	if subresource == "restore" {
+		switch r.Method {
+		case http.MethodPost:
+			restoreUser(w, r, userID)
+		case http.MethodOptions:
+			writeOptions(w, http.MethodPost, http.MethodOptions)
+		default:
+			methodNotAllowed(w, r, http.MethodPost, http.MethodOptions)
+		}
+		return
+	}
+
+	// A request for the user's orders: /users/{id}/orders
+	if subresource == "orders" {
+		switch r.Method {
+		case http.MethodGet:
+			getUserOrders(w, r, userID)
+		case http.MethodOptions:
+			writeOptions(w, http.MethodGet, http.MethodOptions)
+		default:
+			methodNotAllowed(w, r, http.MethodGet, http.MethodOptions)
+		}
 		return
 	}
 
 	switch r.Method {
 	case http.MethodGet:
-		getUserByID(w, r, path)
+		getUserByID(w, r, userID)
+	case http.MethodPut:
+		updateUser(w, r, userID, false)
+	case http.MethodPatch:
+		updateUser(w, r, userID, true)
 	case http.MethodDelete:
-		deleteUser(w, r, path)
+		deleteUser(w, r, userID)
+	case http.MethodOptions:
+		writeOptions(w, http.MethodGet, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodOptions)
+	default:
+		methodNotAllowed(w, r, http.MethodGet, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodOptions)
+	}
+}
+
+// parseUserPath splits an escaped /users/{id}[/orders|/restore] path (r.URL.EscapedPath)
+// into the unescaped user ID and subresource ("", "orders" or "restore"), matching whole
+// segments so IDs like "orders-team" aren't mistaken for the orders route. An
+// encoded slash stays part of the ID (and fails validation) rather than
+// splitting it. A single trailing slash is ignored. ok is false for any other
+// shape, e.g. /users/{id}/unknown.
+func parseUserPath(path string) (userID, subresource string, ok bool) {
+	rest := strings.TrimPrefix(path, "/users/")
+	if len(rest) > 1 {
+		rest = strings.TrimSuffix(rest, "/")
+	}
+
+	segments := strings.Split(rest, "/")
+	switch {
+	case len(segments) == 1:
+		return unescapeUserID(segments[0]), "", true
+	case len(segments) == 2 && (segments[1] == "orders" || segments[1] == "restore"):
+		return unescapeUserID(segments[0]), segments[1], true
 	default:
-		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{
-			Error: fmt.Sprintf("Method %s not allowed", r.Method),
-		})
+		return "", "", false
+	}
+}
+
+// unescapeUserID percent-decodes a path segment. A malformed escape is left
+// as is, and its "%" makes the ID fail validation.
+func unescapeUserID(segment string) string {
+	id, err := url.PathUnescape(segment)
+	if err != nil {
+		return segment
 	}
+	return id
 }
 
 // getAllUsers returns all users
 func getAllUsers(w http.ResponseWriter, r *http.Request) {
-	response := UsersResponse{
-		Service: "user-service (Go)",
-		Count:   len(users),
-		Users:   users,
+	w, ok := withFieldProjection(w, r)
+	if !ok {
+		return
+	}
+	if r.URL.Query().Has("ids") {
+		getUsersByIDs(w, r)
+		return
 	}
 
-	writeJSON(w, http.StatusOK, response)
-}
+	limit, offset, err := parsePagination(r)
+	if err != nil {
+		writeErrorResponse(w, r, http.StatusBadRequest, newErrorResponse("invalid_parameter", err.Error()))
+		return
+	}
 
-// getUserByID returns a specific user by ID
-func getUserByID(w http.ResponseWriter, r *http.Request, userID string) {
-	for _, user := range users {
-		if user.ID == userID {
-			response := UsersResponse{
-				Service: "user-service (Go)",
-				User:    &user,
+	groupBy, err := parseGroupBy(r)
+	if err != nil {
+		writeErrorResponse(w, r, http.StatusBadRequest, newErrorResponse("invalid_parameter", err.Error()))
+		return
+	}
+
+	order, err := parseSort(r)
+	if err != nil {
+		writeErrorResponse(w, r, http.StatusBadRequest, newErrorResponse("invalid_parameter", err.Error()))
+		return
+	}
+
+	format, err := parseListFormat(r)
+	if err == nil && format == "ndjson" && groupBy != "" {
+		err = fmt.Errorf("group_by can't be combined with format=ndjson")
+	}
+	if err != nil {
+		writeErrorResponse(w, r, http.StatusBadRequest, newErrorResponse("invalid_parameter", err.Error()))
+		return
+	}
+
+	cursor, err := parseListCursor(r, order)
+	if err == nil && cursor != nil && (groupBy != "" || format == "ndjson") {
+		err = fmt.Errorf("cursor can't be combined with group_by or format=ndjson")
+	}
+	if errors.Is(err, errCursorInvalid) {
+		writeError(w, r, http.StatusBadRequest, "cursor_invalid")
+		return
+	}
+	if err != nil {
+		writeErrorResponse(w, r, http.StatusBadRequest, newErrorResponse("invalid_parameter", err.Error()))
+		return
+	}
+
+	if listNotModified(w, r) {
+		return
+	}
+
+	// List returns a copy, so filtering and encoding don't race with writers
+	all, err := userStore.List(r.Context())
+	if err != nil {
+		writeStoreFailure(w, r, err)
+		return
+	}
+	// filterUsers returns a new slice, so sorting it leaves the store's order alone
+	matched := filterUsers(all, parseUserFilter(r))
+	switch {
+	case cursor != nil:
+		sortByCreation(matched, cursor.Descending)
+	case cursorOrder(order) && format != "ndjson" && groupBy == "":
+		// Break ties by ID as cursors do, so next_cursor resumes exactly
+		sortByCreation(matched, order.Descending)
+	default:
+		sortUsers(matched, order)
+	}
+	if format == "ndjson" {
+		streamUsersNDJSON(w, r, matched)
+		return
+	}
+	var groups map[string][]User
+	if groupBy == "role" {
+		groups = groupUsersByRole(matched)
+	}
+
+	if groups != nil {
+		if len(matched) == 0 && writeEmptyUserList(w, nil) {
+			return
+		}
+		// Grouped results cover every matching user, so they aren't paginated
+		response := UserGroupsResponse{
+			Service: "user-service (Go)",
+			Count:   len(matched),
+			Groups:  groups,
+		}
+		body, fits, err := encodeWithinLimit(projectedBody(w, zonedBody(w, response)))
+		if err != nil {
+			logger.ErrorContext(r.Context(), "Error encoding users response", "error", err)
+			writeErrorResponse(w, r, http.StatusInternalServerError, newErrorResponse("internal_error", "Failed to encode response"))
+			return
+		}
+		if !fits {
+			writeResponseTooLarge(w, r, "narrow the results with role or q filters")
+			return
+		}
+		writeEncodedJSON(w, http.StatusOK, body)
+		return
+	}
+
+	for {
+		var pageUsers []User
+		var pagination Pagination
+		if cursor != nil {
+			pageUsers, pagination = paginateAfter(matched, limit, *cursor)
+		} else {
+			pageUsers, pagination = paginate(matched, limit, offset)
+			if pagination.NextOffset != nil && cursorOrder(order) {
+				pagination.NextCursor = cursorAfter(pageUsers[len(pageUsers)-1], order)
 			}
+		}
+		if len(pageUsers) == 0 && writeEmptyUserList(w, &pagination) {
+			return
+		}
+
+		response := UserListResponse{
+			Service:    "user-service (Go)",
+			Count:      len(pageUsers),
+			Users:      pageUsers,
+			Pagination: &pagination,
+		}
+
+		if maxResponseBytes <= 0 {
 			writeJSON(w, http.StatusOK, response)
 			return
 		}
+
+		versioned, contentType := versionedBody(w, response)
+		body, fits, err := encodeWithinLimit(versioned)
+		if err != nil {
+			logger.ErrorContext(r.Context(), "Error encoding users response", "error", err)
+			writeErrorResponse(w, r, http.StatusInternalServerError, newErrorResponse("internal_error", "Failed to encode response"))
+			return
+		}
+		if fits {
+			w.Header().Set("Content-Type", contentType)
+			writeEncodedJSON(w, http.StatusOK, body)
+			return
+		}
+
+		// Too large: either shrink the page (forcing pagination) or reject
+		if responseSizeMode != "paginate" || len(pageUsers) <= 1 {
+			writeResponseTooLarge(w, r, "use a smaller limit")
+			return
+		}
+		limit = len(pageUsers) / 2
 	}
+}
 
-	writeJSON(w, http.StatusNotFound, ErrorResponse{
-		Error: fmt.Sprintf("User with ID '%s' not found", userID),
-	})
+// getUserByID returns a specific user by ID
+func getUserByID(w http.ResponseWriter, r *http.Request, userID string) {
+	w, ok := withFieldProjection(w, r)
+	if !ok {
+		return
+	}
+	foundUser, err := lookupUser(r, userID)
+	if errors.Is(err, errUserNotFound) {
+		writeError(w, r, http.StatusNotFound, "user_not_found", userID)
+		return
+	}
+	if err != nil {
+		writeStoreFailure(w, r, err)
+		return
+	}
+
+	recordAccess(userID, time.Now())
+	etag := userETag(foundUser)
+	w.Header().Set("ETag", etag)
+	if notModified(w, r, etag) {
+		return
+	}
+	if includeAccess(r) {
+		foundUser.LastAccessedAt = lastAccessedAt(userID)
+	}
+
+	response := UsersResponse{
+		Service: "user-service (Go)",
+		User:    &foundUser,
+	}
+	writeJSON(w, http.StatusOK, response)
 }
 
 // getUserOrders fetches a user and their orders from the Order Service
 // This demonstrates service-to-service communication: User Service -> Order Service
 func getUserOrders(w http.ResponseWriter, r *http.Request, userID string) {
-	log.Printf("getUserOrders called for user: %s", userID)
-	
+	logger.InfoContext(r.Context(), "getUserOrders called", "user_id", userID)
+
 	// First, find the user
-	var foundUser *User
-	for _, user := range users {
-		if user.ID == userID {
-			foundUser = &user
-			break
-		}
+	foundUser, err := findUser(r.Context(), userID)
+	if errors.Is(err, errUserNotFound) {
+		writeError(w, r, http.StatusNotFound, "user_not_found", userID)
+		return
+	}
+	if err != nil {
+		writeStoreFailure(w, r, err)
+		return
 	}
-	
-	if foundUser == nil {
-		writeJSON(w, http.StatusNotFound, ErrorResponse{
-			Error: fmt.Sprintf("User with ID '%s' not found", userID),
-		})
+
+	// Check if ORDER_SERVICE_URL is configured (mock mode doesn't need it)
+	baseURL := getOrderServiceURL()
+	if baseURL == "" && !mockOrderService() {
+		writeErrorResponse(w, r, http.StatusServiceUnavailable, newErrorResponse("not_configured", "ORDER_SERVICE_URL not configured - cannot fetch orders"))
 		return
 	}
-	
-	// Check if ORDER_SERVICE_URL is configured
-	if ORDER_SERVICE_URL == "" {
-		writeJSON(w, http.StatusServiceUnavailable, ErrorResponse{
-			Error: "ORDER_SERVICE_URL not configured - cannot fetch orders",
-		})
+
+	// Orders stay filed under the user's pre-migration ID, if it had one
+	orderPath := legacyIDFor(userID)
+	query := forwardedOrderQuery(r)
+	orderURL := fmt.Sprintf("%s/orders/user/%s", baseURL, url.PathEscape(orderPath))
+	if query != "" {
+		// Let the Order Service trim the payload; if it ignores these we just get full orders
+		orderURL += "?" + query
+	}
+
+	// Serve recent orders from the cache unless the client asked for fresh ones
+	cacheKey := orderPath + "?" + query
+	var ordersResponse interface{}
+	cached := false
+	if !bypassOrdersCache(r) {
+		ordersResponse, cached = cachedUserOrders(cacheKey)
+	}
+	var warnings []string
+	flow := "User Service (Go) → Order Service (Node.js) via OIDC"
+	if mockOrderService() {
+		flow = mockOrdersFlow
+	}
+	if !cached && mockOrderService() {
+		ordersResponse = mockUserOrders(orderPath)
+	} else if !cached {
+		var ok bool
+		if ordersResponse, warnings, ok = fetchUserOrders(w, r, foundUser, orderURL); !ok {
+			return
+		}
+		// Stand-in empty orders aren't cached, so the warning isn't lost
+		if len(warnings) == 0 {
+			storeUserOrders(cacheKey, ordersResponse)
+		}
+	}
+
+	// Return combined response
+	response := UserWithOrders{
+		Service:  "user-service (Go)",
+		User:     &foundUser,
+		Orders:   ordersResponse,
+		Flow:     flow,
+		Warnings: warnings,
+		Cached:   cached,
+	}
+
+	if maxResponseBytes > 0 {
+		body, fits, err := encodeWithinLimit(zonedBody(w, response))
+		if err != nil || !fits {
+			logger.WarnContext(r.Context(), "Orders response exceeds MAX_RESPONSE_BYTES", "user_id", userID)
+			writeResponseTooLarge(w, r, "the user has too many orders to return in one response")
+			return
+		}
+		logger.InfoContext(r.Context(), "Successfully fetched orders", "user_id", userID)
+		ordersFetchedTotal.Add(1)
+		writeEncodedJSON(w, http.StatusOK, body)
 		return
 	}
-	
+
+	logger.InfoContext(r.Context(), "Successfully fetched orders", "user_id", userID)
+	ordersFetchedTotal.Add(1)
+	writeJSON(w, http.StatusOK, response)
+}
+
+// fetchUserOrders calls the Order Service for user's orders and checks the
+// response against the contract, with warnings to pass on to the client. On
+// failure it writes the error response and returns false.
+func fetchUserOrders(w http.ResponseWriter, r *http.Request, foundUser User, orderURL string) (interface{}, []string, bool) {
 	// Make authenticated request to Order Service
-	log.Printf("Calling Order Service at: %s/orders/user/%s", ORDER_SERVICE_URL, userID)
-	
-	orderURL := fmt.Sprintf("%s/orders/user/%s", ORDER_SERVICE_URL, userID)
-	ordersData, err := makeAuthenticatedRequest(r.Context(), orderURL)
+	logger.InfoContext(r.Context(), "Calling Order Service", "url", orderURL, "user_id", foundUser.ID)
+
+	ordersData, err := makeAuthenticatedGet(r.Context(), orderURL)
 	if err != nil {
-		log.Printf("Error calling Order Service: %v", err)
-		writeJSON(w, http.StatusBadGateway, ErrorResponse{
-			Error: fmt.Sprintf("Failed to fetch orders from Order Service: %v", err),
-		})
-		return
+		logger.ErrorContext(r.Context(), "Error calling Order Service", "error", err)
+		var openErr *circuitOpenError
+		if errors.As(err, &openErr) {
+			w.Header().Set("Retry-After", strconv.Itoa(int(openErr.RetryAfter.Round(time.Second)/time.Second)+1))
+			writeErrorResponse(w, r, http.StatusServiceUnavailable, upstreamFailure("order-service", "Order Service is unavailable (circuit open) - try again shortly", err))
+			return nil, nil, false
+		}
+		if errors.Is(err, errBackendBusy) {
+			writeErrorResponse(w, r, http.StatusServiceUnavailable, upstreamFailure("order-service", "Order Service is at its concurrency limit - try again shortly", err))
+			return nil, nil, false
+		}
+		if isTimeout(err) {
+			writeOrdersTimeout(w, r, foundUser, err)
+			return nil, nil, false
+		}
+		var statusErr *statusError
+		if errors.As(err, &statusErr) {
+			if statusErr.StatusCode == http.StatusNotFound {
+				return emptyUserOrders(foundUser.ID), []string{noOrdersWarning}, true
+			}
+			status, code, message := ordersStatusFailure(statusErr.StatusCode)
+			resp := upstreamFailure("order-service", message, err)
+			resp.Code = code
+			writeErrorResponse(w, r, status, resp)
+			return nil, nil, false
+		}
+		writeErrorResponse(w, r, http.StatusBadGateway, upstreamFailure("order-service", clientErrorMessage("Failed to fetch orders from Order Service", err), err))
+		return nil, nil, false
 	}
-	
+
 	// Parse the orders response
 	var ordersResponse interface{}
 	if err := json.Unmarshal(ordersData, &ordersResponse); err != nil {
-		log.Printf("Error parsing orders response: %v", err)
-		writeJSON(w, http.StatusInternalServerError, ErrorResponse{
-			Error: "Failed to parse orders response",
-		})
+		logger.ErrorContext(r.Context(), "Error parsing orders response", "error", err)
+		writeErrorResponse(w, r, http.StatusInternalServerError, upstreamFailure("order-service", "Failed to parse orders response", fmt.Errorf("%w: %v", errBadUpstreamResponse, err)))
+		return nil, nil, false
+	}
+
+	// Catch Go/Node.js schema drift before handing the data to our caller
+	if err := checkOrdersContract(r.Context(), ordersResponse); err != nil {
+		logger.ErrorContext(r.Context(), "Error validating orders response", "error", err)
+		writeErrorResponse(w, r, http.StatusBadGateway, upstreamFailure("order-service", clientErrorMessage("Order Service returned an unexpected response", err), fmt.Errorf("%w: %v", errBadUpstreamResponse, err)))
+		return nil, nil, false
+	}
+
+	return ordersResponse, nil, true
+}
+
+// Orders timeout configuration.
+// When the Order Service times out, the user has already been found, so by
+// default (ORDERS_TIMEOUT_PARTIAL=true) it's returned with "orders": null and
+// a warning, with status ORDERS_TIMEOUT_STATUS (200, or 504 for clients that
+// key off the status). With ORDERS_TIMEOUT_PARTIAL=false a timeout is a plain 504.
+var (
+	ordersTimeoutPartial = envBool("ORDERS_TIMEOUT_PARTIAL", true)
+	ordersTimeoutStatus  = envInt("ORDERS_TIMEOUT_STATUS", http.StatusOK)
+)
+
+// writeOrdersTimeout responds to a /users/{id}/orders request whose Order Service call timed out
+func writeOrdersTimeout(w http.ResponseWriter, r *http.Request, user User, err error) {
+	// A client that set its own deadline asked for a 504, not a partial response
+	if clientDeadlineExceeded(r.Context()) {
+		writeClientDeadlineExceeded(w, r)
 		return
 	}
-	
-	// Return combined response
-	response := UserWithOrders{
-		Service: "user-service (Go)",
-		User:    foundUser,
-		Orders:  ordersResponse,
-		Flow:    "User Service (Go) → Order Service (Node.js) via OIDC",
+	if !ordersTimeoutPartial {
+		writeErrorResponse(w, r, http.StatusGatewayTimeout, upstreamFailure("order-service", "Order Service timed out", err))
+		return
 	}
-	
-	log.Printf("Successfully fetched orders for user %s", userID)
-	writeJSON(w, http.StatusOK, response)
+
+	logger.WarnContext(r.Context(), "Returning user without orders after Order Service timeout", "user_id", user.ID)
+	writeJSON(w, ordersTimeoutStatus, UserWithOrders{
+		Service:  "user-service (Go)",
+		User:     &user,
+		Orders:   nil,
+		Flow:     "User Service (Go) → Order Service (Node.js) via OIDC",
+		Warnings: []string{"Orders unavailable: the Order Service timed out - retry for the full response"},
+	})
+}
+
+// forwardedOrderQuery returns the client's field selection params (fields, view)
+// encoded for the Order Service request, or "" when none were given
+func forwardedOrderQuery(r *http.Request) string {
+	forwarded := url.Values{}
+	for _, key := range []string{"fields", "view"} {
+		if value := strings.TrimSpace(r.URL.Query().Get(key)); value != "" {
+			forwarded.Set(key, value)
+		}
+	}
+	return forwarded.Encode()
 }
 
-// createUser creates a new user
+// createUser creates a new user, or several when the body is a JSON array
 func createUser(w http.ResponseWriter, r *http.Request) {
-	var newUser User
-	if err := json.NewDecoder(r.Body).Decode(&newUser); err != nil {
-		writeJSON(w, http.StatusBadRequest, ErrorResponse{
-			Error: "Invalid JSON body",
-		})
+	if !allowStoreMutation(w, r) || !requireJSONContentType(w, r) {
 		return
 	}
+	serveIdempotently(w, r, createUserOnce)
+}
 
-	// Generate ID if not provided
-	if newUser.ID == "" {
-		newUser.ID = fmt.Sprintf("user-%03d", len(users)+1)
+// createUserOnce creates the user (or users) in the request body
+func createUserOnce(w http.ResponseWriter, r *http.Request) {
+	body := bufio.NewReader(r.Body)
+	if isJSONArray(body) {
+		createUsers(w, r, body)
+		return
 	}
-	newUser.CreatedAt = time.Now()
 
-	// Validate required fields
-	if newUser.Name == "" {
-		writeJSON(w, http.StatusBadRequest, ErrorResponse{
-			Error: "Name is required",
-		})
+	var newUser User
+	fields, ok := decodeJSONFields(w, r, body, &newUser)
+	if !ok {
 		return
 	}
-
-	if newUser.Email == "" {
-		writeJSON(w, http.StatusBadRequest, ErrorResponse{
-			Error: "Email is required",
-		})
+	if len(fields) > 0 {
+		// Report missing and invalid fields alongside the unknown or mistyped ones
+		writeFieldErrors(w, r, mergeFieldErrors(fields, validateUserFields(&newUser.Name, &newUser.Email, &newUser.Role)))
 		return
 	}
 
-	users = append(users, newUser)
+	lang := requestLanguage(r)
+	created, status, errResp := insertUser(r.Context(), lang, newUser)
+	if errResp != nil {
+		w.Header().Set("Content-Language", lang.String())
+		writeErrorResponse(w, r, status, *errResp)
+		return
+	}
 
 	response := UsersResponse{
-		Service: "user-service (Go)",
-		User:    &newUser,
-		Message: "User created successfully",
+		Service:  "user-service (Go)",
+		User:     &created,
+		Message:  "User created successfully",
+		Warnings: userWarnings(r.Context(), created),
 	}
 
+	w.Header().Set("ETag", userETag(created))
 	writeJSON(w, http.StatusCreated, response)
 }
 
-// deleteUser deletes a user by ID
-func deleteUser(w http.ResponseWriter, r *http.Request, userID string) {
-	for i, user := range users {
-		if user.ID == userID {
-			users = append(users[:i], users[i+1:]...)
-			response := UsersResponse{
-				Service: "user-service (Go)",
-				Message: fmt.Sprintf("User '%s' deleted successfully", userID),
+// insertUser validates newUser and adds it to the store. It returns the stored
+// user, or the status and (localized) error to report instead.
+func insertUser(ctx context.Context, lang language.Tag, newUser User) (User, int, *ErrorResponse) {
+	// Validate client-supplied IDs so they can be used in /users/{id} paths
+	if newUser.ID != "" {
+		if err := validateUserID(newUser.ID); err != nil {
+			errResp := localizedError(lang, "user_id_invalid", loggableUserID(newUser.ID), userIDPattern.String(), maxUserIDLength)
+			return User{}, http.StatusBadRequest, &errResp
+		}
+	}
+
+	// Validate fields (name and email are required)
+	if fields := validateUserFields(&newUser.Name, &newUser.Email, &newUser.Role); len(fields) > 0 {
+		errResp := fieldErrorsResponse(lang, fields)
+		return User{}, http.StatusBadRequest, &errResp
+	}
+
+	// The store checks email (and, if enabled, name) uniqueness atomically with the insert
+	created, err := userStore.Create(ctx, newUser, WriteOptions{
+		UniqueNames: featureEnabled(ctx, "unique_names", uniqueNames),
+	})
+	switch {
+	case errors.Is(err, errEmailTaken):
+		errResp := emailTakenError(lang, newUser.Email)
+		return User{}, http.StatusConflict, &errResp
+	case errors.Is(err, errNameTaken):
+		errResp := localizedError(lang, "name_taken", newUser.Name)
+		return User{}, http.StatusConflict, &errResp
+	case errors.Is(err, errUserExists):
+		errResp := localizedError(lang, "user_exists", newUser.ID)
+		return User{}, http.StatusConflict, &errResp
+	case errors.Is(err, errStoreFull):
+		errResp := localizedError(lang, "store_full", maxUsers)
+		return User{}, http.StatusInsufficientStorage, &errResp
+	case err != nil:
+		logger.ErrorContext(ctx, "Error creating user", "backend", storeBackend, "error", err)
+		errResp := newErrorResponse("store_unavailable", clientErrorMessage("User store unavailable - try again shortly", err))
+		return User{}, http.StatusServiceUnavailable, &errResp
+	}
+	usersCreatedTotal.Add(1)
+
+	return created, http.StatusCreated, nil
+}
+
+// updateUser replaces (PUT) or partially updates (PATCH) an existing user
+func updateUser(w http.ResponseWriter, r *http.Request, userID string, partial bool) {
+	if !allowStoreMutation(w, r) {
+		return
+	}
+	// PATCH bodies can also be patch documents, so they're checked below
+	if !partial && !requireJSONContentType(w, r) {
+		return
+	}
+
+	var patch UserPatch
+	if partial {
+		mediaType, ok := patchMediaType(r)
+		if !ok {
+			w.Header().Set("Accept-Patch", acceptPatch)
+			if mediaType == "" {
+				writeError(w, r, http.StatusUnsupportedMediaType, "content_type_required", acceptPatch)
+			} else {
+				writeError(w, r, http.StatusUnsupportedMediaType, "unsupported_patch_type", mediaType)
 			}
-			writeJSON(w, http.StatusOK, response)
 			return
 		}
+		if mediaType == mediaTypeJSONPatch {
+			patchUserJSONPatch(w, r, userID)
+			return
+		}
+		// Plain JSON and merge patches both set just the fields present
+		if !decodeJSONBody(w, r, r.Body, &patch) {
+			return
+		}
+	} else {
+		// A PUT is a full replacement, so every field is applied
+		var replacement User
+		if !decodeJSONBody(w, r, r.Body, &replacement) {
+			return
+		}
+		patch = UserPatch{
+			Name:  &replacement.Name,
+			Email: &replacement.Email,
+			Role:  &replacement.Role,
+		}
+		if replacement.ID != "" {
+			patch.ID = &replacement.ID
+		}
+	}
+
+	applyUserUpdate(w, r, userID, patch, r.Header.Get("If-Match"), false)
+}
+
+// applyUserUpdate validates patch and applies it to the user if its ETag
+// matches ifMatch (when set), writing the response. With retryable set, a
+// failed precondition writes nothing and returns true so the caller can
+// re-read the user and try again.
+func applyUserUpdate(w http.ResponseWriter, r *http.Request, userID string, patch UserPatch, ifMatch string, retryable bool) (retry bool) {
+	// The ID comes from the path and can't be changed by the body
+	if patch.ID != nil && *patch.ID != userID {
+		writeError(w, r, http.StatusBadRequest, "user_id_mismatch", *patch.ID, userID)
+		return false
+	}
+
+	// Validate the fields being set
+	if fields := validateUserFields(patch.Name, patch.Email, patch.Role); len(fields) > 0 {
+		writeFieldErrors(w, r, fields)
+		return false
 	}
 
-	writeJSON(w, http.StatusNotFound, ErrorResponse{
-		Error: fmt.Sprintf("User with ID '%s' not found", userID),
+	// The store checks If-Match and uniqueness and applies the patch atomically,
+	// so a concurrent edit can't slip in between the check and the write
+	before, updated, err := userStore.Update(r.Context(), userID, patch, WriteOptions{
+		UniqueNames: featureEnabled(r.Context(), "unique_names", uniqueNames),
+		IfMatch:     ifMatch,
 	})
+	switch {
+	case errors.Is(err, errUserNotFound):
+		writeError(w, r, http.StatusNotFound, "user_not_found", userID)
+		return false
+	case errors.Is(err, errPreconditionFailed) && retryable:
+		return true
+	case errors.Is(err, errPreconditionFailed):
+		writeError(w, r, http.StatusPreconditionFailed, "precondition_failed", userID)
+		return false
+	case errors.Is(err, errNameTaken):
+		writeError(w, r, http.StatusConflict, "name_taken", *patch.Name)
+		return false
+	case errors.Is(err, errEmailTaken):
+		writeEmailTaken(w, r, *patch.Email)
+		return false
+	case err != nil:
+		writeStoreFailure(w, r, err)
+		return false
+	}
+
+	response := UsersResponse{
+		Service: "user-service (Go)",
+		User:    &updated,
+		Message: fmt.Sprintf("User '%s' updated successfully", userID),
+	}
+	if wantsDiff(r) {
+		// before/updated were captured in the same write, so the diff is exact
+		response.Changes = diffUsers(before, updated)
+	}
+
+	w.Header().Set("ETag", userETag(updated))
+	writeJSON(w, http.StatusOK, response)
+	return false
+}
+
+// deleteUser soft-deletes a user by ID, or removes it for good with ?hard=true
+func deleteUser(w http.ResponseWriter, r *http.Request, userID string) {
+	if !allowStoreMutation(w, r) {
+		return
+	}
+	if !hardDelete(r) {
+		softDeleteUser(w, r, userID)
+		return
+	}
+
+	err := userStore.Delete(r.Context(), userID)
+	if errors.Is(err, errUserNotFound) {
+		writeError(w, r, http.StatusNotFound, "user_not_found", userID)
+		return
+	}
+	if err != nil {
+		writeStoreFailure(w, r, err)
+		return
+	}
+
+	forgetAccess(userID)
+	usersDeletedTotal.Add(1)
+	response := UsersResponse{
+		Service: "user-service (Go)",
+		Message: fmt.Sprintf("User '%s' deleted successfully", userID),
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// methodNotAllowed writes a 405 response with an Allow header listing the supported methods
+func methodNotAllowed(w http.ResponseWriter, r *http.Request, allowed ...string) {
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	writeError(w, r, http.StatusMethodNotAllowed, "method_not_allowed", r.Method)
+}
+
+// writeOptions answers an OPTIONS request with 204 and an Allow header listing the supported methods
+func writeOptions(w http.ResponseWriter, allowed ...string) {
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	w.WriteHeader(http.StatusNoContent)
 }
 
 // writeJSON writes a JSON response
 func writeJSON(w http.ResponseWriter, status int, data interface{}) {
-	w.Header().Set("Content-Type", "application/json")
+	data, contentType := versionedBody(w, data)
+	w.Header().Set("Content-Type", contentType)
+	if bufferJSONResponses {
+		writeBufferedJSON(w, status, data)
+		return
+	}
 	w.WriteHeader(status)
 	if err := json.NewEncoder(w).Encode(data); err != nil {
-		log.Printf("Error encoding JSON response: %v", err)
+		logger.Error("Error encoding JSON response", "error", err)
 	}
-}
\ No newline at end of file
+}
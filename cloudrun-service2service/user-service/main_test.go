@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/api/idtoken"
+)
+
+func TestMain(m *testing.M) {
+	// Every request is logged; keep test output to the failures
+	logger = slog.New(slog.NewJSONHandler(io.Discard, nil))
+	os.Exit(m.Run())
+}
+
+// captureLogs sends log lines at level and up to the returned buffer until
+// the test ends
+func captureLogs(t *testing.T, level slog.Level) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	handler := slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: level, ReplaceAttr: cloudLoggingAttr})
+	setForTest(t, &logger, slog.New(traceHandler{handler}))
+	return &buf
+}
+
+// testIDToken returns an unsigned JWT with claims, expiring in an hour
+// unless claims say otherwise
+func testIDToken(claims map[string]interface{}) string {
+	payload := map[string]interface{}{"exp": time.Now().Add(time.Hour).Unix()}
+	for name, value := range claims {
+		payload[name] = value
+	}
+	data, _ := json.Marshal(payload)
+	encode := base64.RawURLEncoding.EncodeToString
+	return encode([]byte(`{"alg":"RS256","typ":"JWT"}`)) + "." + encode(data) + "." + testTokenSignature
+}
+
+// testTokenSignature signs the tokens useAuth accepts
+const testTokenSignature = "c2lnbmF0dXJl"
+
+// useAuth turns on REQUIRE_AUTH with a verifier that accepts tokens made by
+// testIDToken, standing in for Google's signature check
+func useAuth(t *testing.T) {
+	t.Helper()
+	setForTest(t, &requireAuth, true)
+	setForTest(t, &validateIDToken, func(ctx context.Context, token, audience string) (*idtoken.Payload, error) {
+		var claims map[string]interface{}
+		if !strings.HasSuffix(token, "."+testTokenSignature) || decodeJWTPayload(token, &claims) != nil {
+			return nil, errors.New("invalid signature")
+		}
+		subject, _ := claims["sub"].(string)
+		return &idtoken.Payload{Subject: subject, Claims: claims}, nil
+	})
+}
+
+// bearer returns an Authorization header value for a token with claims
+func bearer(claims map[string]interface{}) string {
+	return "Bearer " + testIDToken(claims)
+}
+
+// useCachedIDToken caches an ID token for audience, so outbound calls to it
+// don't need the metadata server, and returns the token
+func useCachedIDToken(t *testing.T, audience string) string {
+	t.Helper()
+	token := testIDToken(map[string]interface{}{"aud": audience})
+	storeIDToken(audience, token)
+	t.Cleanup(func() { evictIDToken(audience) })
+	return token
+}
+
+// roundTripFunc lets a function stand in for an http.RoundTripper
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+// useMetadataServer answers metadata server ID token requests with mint's
+// token for the requested audience until the test ends
+func useMetadataServer(t *testing.T, mint func(audience string) string) {
+	t.Helper()
+	setForTest(t, &metadataClient, &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		if req.Header.Get("Metadata-Flavor") != "Google" {
+			return nil, errors.New("metadata request without Metadata-Flavor")
+		}
+		rec := httptest.NewRecorder()
+		rec.WriteString(mint(req.URL.Query().Get("audience")))
+		return rec.Result(), nil
+	})})
+}
+
+// newBackend starts a backend service for outbound calls to reach
+func newBackend(t *testing.T, handler http.HandlerFunc) *httptest.Server {
+	t.Helper()
+	backend := httptest.NewServer(handler)
+	t.Cleanup(backend.Close)
+	return backend
+}
+
+// useOrderService points ORDER_SERVICE_URL at a fake Order Service running
+// handler, with the orders cache off so every request reaches it
+func useOrderService(t *testing.T, handler http.HandlerFunc) *httptest.Server {
+	t.Helper()
+	backend := newBackend(t, handler)
+	previous := setOrderServiceURL(backend.URL)
+	t.Cleanup(func() { setOrderServiceURL(previous) })
+	setForTest(t, &orderServiceMode, "live")
+	setForTest(t, &ordersCacheTTL, 0)
+	useCachedIDToken(t, backend.URL)
+	return backend
+}
+
+// testOrdersJSON is a contract-conforming Order Service response for userID
+func testOrdersJSON(userID string) string {
+	return `{"service":"order-service","userId":"` + userID + `","count":1,"orders":[` +
+		`{"id":"order-1","userId":"` + userID + `","items":[{"product":"Laptop","quantity":1,"price":999.99}],` +
+		`"total":999.99,"status":"completed","createdAt":"2024-01-15T10:30:00Z"}]}`
+}
+
+// newTestServer serves the real routes behind the full middleware chain, as
+// main does
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	registerRoutes(mux, routes)
+	server := httptest.NewServer(Chain(serverMiddleware...)(mux))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// useTestStore gives the test a fresh memory store holding the demo users
+func useTestStore(t *testing.T) *memoryStore {
+	t.Helper()
+	store := newMemoryStore(seedUsers())
+	setForTest[UserStore](t, &userStore, store)
+	return store
+}
+
+// setForTest sets *p to v until the test ends
+func setForTest[T any](t *testing.T, p *T, v T) {
+	t.Helper()
+	previous := *p
+	*p = v
+	t.Cleanup(func() { *p = previous })
+}
+
+// promhttpHandler serves /metrics as the route does
+var promhttpHandler = promhttp.Handler().ServeHTTP
+
+// noRedirects is a client that returns redirects instead of following them
+var noRedirects = &http.Client{
+	CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+}
+
+// doRequest sends a request to server and returns the response with its
+// body read. headers are name, value pairs.
+func doRequest(t *testing.T, server *httptest.Server, method, path, body string, headers ...string) (*http.Response, string) {
+	t.Helper()
+	req, err := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	resp, err := noRedirects.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp, string(data)
+}
+
+// serveHandler calls handler with a request and returns the recorded response
+func serveHandler(handler http.HandlerFunc, method, path, body string, headers ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+	return rec
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// newUserJSON is a valid create body for user-00n
+func newUserJSON(n int) string {
+	return fmt.Sprintf(`{"id":"user-%03d","name":"User %d","email":"user%d@example.com","role":"viewer"}`, n, n, n)
+}
+
+func TestMaxUsersReject(t *testing.T) {
+	useTestStore(t)
+	setForTest(t, &maxUsers, 4)
+	setForTest(t, &maxUsersPolicy, "reject")
+
+	createUserForTest(t, newUserJSON(4), http.StatusCreated)
+	rec := serveHandler(usersHandler, http.MethodPost, "/users", newUserJSON(5), "Content-Type", "application/json")
+	if rec.Code != http.StatusInsufficientStorage || !strings.Contains(rec.Body.String(), `"store_full"`) {
+		t.Fatalf("status = %d, want 507 store_full: %s", rec.Code, rec.Body)
+	}
+	if got := listUsersForTest(t, "").Count; got != 4 {
+		t.Errorf("%d users, want MAX_USERS (4)", got)
+	}
+}
+
+func TestMaxUsersEvict(t *testing.T) {
+	useTestStore(t)
+	setForTest(t, &maxUsers, 4)
+	setForTest(t, &maxUsersPolicy, "evict")
+	evictedBefore := usersEvictedTotal.Load()
+
+	createUserForTest(t, newUserJSON(4), http.StatusCreated)
+	createUserForTest(t, newUserJSON(5), http.StatusCreated)
+	createUserForTest(t, newUserJSON(6), http.StatusCreated)
+
+	// The oldest users that aren't demo users make room
+	got := userIDs(listUsersForTest(t, "").Users)
+	if want := []string{"user-001", "user-002", "user-003", "user-006"}; !slices.Equal(got, want) {
+		t.Errorf("users = %v, want %v", got, want)
+	}
+	if evicted := usersEvictedTotal.Load() - evictedBefore; evicted != 2 {
+		t.Errorf("users_evicted went up by %d, want 2", evicted)
+	}
+}
+
+func TestMaxUsersEvictNeverRemovesDemoUsers(t *testing.T) {
+	useTestStore(t)
+	setForTest(t, &maxUsers, 3)
+	setForTest(t, &maxUsersPolicy, "evict")
+
+	rec := serveHandler(usersHandler, http.MethodPost, "/users", newUserJSON(4), "Content-Type", "application/json")
+	if rec.Code != http.StatusInsufficientStorage {
+		t.Errorf("status = %d, want 507 with only demo users to evict: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestCheckMaxUsersPolicy(t *testing.T) {
+	for policy, wantErr := range map[string]bool{"reject": false, "evict": false, "drop": true, "": true} {
+		setForTest(t, &maxUsersPolicy, policy)
+		if err := checkMaxUsersPolicy(); (err != nil) != wantErr {
+			t.Errorf("MAX_USERS_POLICY=%q: err = %v, want error %v", policy, err, wantErr)
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Mesh health configuration.
+// MESH_DEPENDENCIES lists extra services to check besides the Order Service,
+// e.g. "billing=https://billing-xxxxx-uc.a.run.app,audit=https://audit-xxxxx-uc.a.run.app".
+var (
+	meshDependencies   = parseMeshDependencies(os.Getenv("MESH_DEPENDENCIES"))
+	meshHealthCacheTTL = envDuration("MESH_HEALTH_CACHE_TTL", 10*time.Second)
+	meshHealthTimeout  = envDuration("MESH_HEALTH_TIMEOUT", 5*time.Second)
+)
+
+// meshDependency is a downstream service whose health we report
+type meshDependency struct {
+	Name string
+	URL  string
+}
+
+// ServiceHealth is the health of a single service in the mesh
+type ServiceHealth struct {
+	Name      string `json:"name"`
+	URL       string `json:"url,omitempty"`
+	Status    string `json:"status"`
+	Version   string `json:"version,omitempty"`
+	LatencyMs int64  `json:"latency_ms"`
+	Error     string `json:"error,omitempty"`
+	// Code classifies Error, as in UpstreamError
+	Code string `json:"code,omitempty"`
+}
+
+// MeshHealthResponse represents the response for GET /mesh/health
+type MeshHealthResponse struct {
+	Service   string          `json:"service"`
+	Status    string          `json:"status"`
+	CheckedAt time.Time       `json:"checked_at"`
+	Cached    bool            `json:"cached"`
+	Services  []ServiceHealth `json:"services"`
+
+	// Errors lists every dependency whose check failed, not just the first
+	Errors []UpstreamError `json:"errors,omitempty"`
+}
+
+// meshHealthCache holds the last mesh health result for meshHealthCacheTTL
+var meshHealthCache struct {
+	sync.Mutex
+	response *MeshHealthResponse
+}
+
+// meshHealthHandler handles GET /mesh/health
+func meshHealthHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, r, http.MethodGet)
+		return
+	}
+
+	// Always 200: the body reports per-service status so a single
+	// unreachable dependency doesn't make the whole report unavailable
+	writeJSON(w, http.StatusOK, cachedMeshHealth(r.Context()))
+}
+
+// statusHandler handles GET /status: the same report as /mesh/health, but
+// 503 unless every service is healthy, so dashboards and uptime checks can
+// alert on the status code alone
+func statusHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, r, http.MethodGet)
+		return
+	}
+
+	response := cachedMeshHealth(r.Context())
+	status := http.StatusOK
+	if response.Status != "healthy" {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, response)
+}
+
+// cachedMeshHealth returns the last mesh health result if it's under
+// MESH_HEALTH_CACHE_TTL old, otherwise checks again
+func cachedMeshHealth(ctx context.Context) MeshHealthResponse {
+	meshHealthCache.Lock()
+	cached := meshHealthCache.response
+	meshHealthCache.Unlock()
+
+	if cached != nil && time.Since(cached.CheckedAt) < meshHealthCacheTTL {
+		response := *cached
+		response.Cached = true
+		return response
+	}
+
+	response := checkMeshHealth(ctx)
+
+	meshHealthCache.Lock()
+	meshHealthCache.response = &response
+	meshHealthCache.Unlock()
+	return response
+}
+
+// checkMeshHealth checks this service and every configured dependency in parallel
+func checkMeshHealth(ctx context.Context) MeshHealthResponse {
+	dependencies := meshDependencies
+	if orderURL := getOrderServiceURL(); orderURL != "" {
+		dependencies = append([]meshDependency{{Name: "order-service", URL: orderURL}}, dependencies...)
+	}
+
+	services := make([]ServiceHealth, len(dependencies)+1)
+	failures := make([]*UpstreamError, len(dependencies))
+	services[0] = ServiceHealth{
+		Name:    "user-service",
+		Status:  "healthy",
+		Version: "1.0.0",
+	}
+
+	var wg sync.WaitGroup
+	for i, dep := range dependencies {
+		wg.Add(1)
+		go func(i int, dep meshDependency) {
+			defer wg.Done()
+			services[i+1], failures[i] = checkServiceHealth(ctx, dep)
+		}(i, dep)
+	}
+	wg.Wait()
+
+	var errs []UpstreamError
+	for _, failure := range failures {
+		if failure != nil {
+			errs = append(errs, *failure)
+		}
+	}
+
+	status := "healthy"
+	for _, svc := range services {
+		if svc.Status != "healthy" {
+			status = "degraded"
+			break
+		}
+	}
+
+	return MeshHealthResponse{
+		Service:   "user-service (Go)",
+		Status:    status,
+		CheckedAt: time.Now().UTC(),
+		Services:  services,
+		Errors:    errs,
+	}
+}
+
+// checkServiceHealth calls a dependency's /health endpoint with an OIDC token.
+// If the check itself fails, the classified error is returned too.
+func checkServiceHealth(ctx context.Context, dep meshDependency) (ServiceHealth, *UpstreamError) {
+	ctx, cancel := context.WithTimeout(ctx, meshHealthTimeout)
+	defer cancel()
+
+	health := ServiceHealth{Name: dep.Name, URL: dep.URL}
+
+	start := time.Now()
+	body, err := makeAuthenticatedGet(ctx, strings.TrimRight(dep.URL, "/")+"/health")
+	health.LatencyMs = time.Since(start).Milliseconds()
+	if err != nil {
+		logger.WarnContext(ctx, "Mesh health check failed", "dependency", dep.Name, "error", err)
+		failure := newUpstreamError(dep.Name, err)
+		health.Status = "unreachable"
+		health.Error = failure.Error
+		health.Code = failure.Code
+		return health, &failure
+	}
+
+	var reported HealthResponse
+	if err := json.Unmarshal(body, &reported); err != nil {
+		failure := newUpstreamError(dep.Name, fmt.Errorf("%w: invalid health response: %v", errBadUpstreamResponse, err))
+		health.Status = "unhealthy"
+		health.Error = failure.Error
+		health.Code = failure.Code
+		return health, &failure
+	}
+
+	health.Status = reported.Status
+	health.Version = reported.Version
+	if health.Status == "" {
+		health.Status = "unknown"
+	}
+	return health, nil
+}
+
+// parseMeshDependencies parses "name=url" pairs separated by commas
+func parseMeshDependencies(raw string) []meshDependency {
+	var dependencies []meshDependency
+	for _, pair := range strings.Split(raw, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		name, url, ok := strings.Cut(pair, "=")
+		if !ok || name == "" || url == "" {
+			logger.Warn("Ignoring invalid mesh dependency", "value", pair)
+			continue
+		}
+		dependencies = append(dependencies, meshDependency{Name: strings.TrimSpace(name), URL: strings.TrimSpace(url)})
+	}
+	return dependencies
+}
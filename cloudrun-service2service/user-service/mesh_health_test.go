@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMeshHealth(t *testing.T) {
+	setForTest(t, &meshHealthCacheTTL, 0)
+	setForTest(t, &outboundMaxRetries, 0)
+	useOrderService(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/health" {
+			t.Errorf("order-service checked at %s, want /health", r.URL.Path)
+		}
+		w.Write([]byte(`{"service":"order-service","status":"healthy","version":"2.1.0"}`))
+	})
+	billing := newBackend(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "down", http.StatusInternalServerError)
+	})
+	useCachedIDToken(t, billing.URL)
+	setForTest(t, &meshDependencies, []meshDependency{{Name: "billing", URL: billing.URL}})
+
+	rec := serveHandler(meshHealthHandler, http.MethodGet, "/mesh/health", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200 whatever the dependencies' health", rec.Code)
+	}
+	var resp MeshHealthResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Status != "degraded" || len(resp.Services) != 3 {
+		t.Fatalf("status %q with %d services, want degraded with 3", resp.Status, len(resp.Services))
+	}
+	if orders := resp.Services[1]; orders.Name != "order-service" || orders.Status != "healthy" || orders.Version != "2.1.0" {
+		t.Errorf("order-service = %+v, want healthy 2.1.0", orders)
+	}
+	if billing := resp.Services[2]; billing.Name != "billing" || billing.Status != "unreachable" || billing.Code == "" {
+		t.Errorf("billing = %+v, want unreachable with a code", billing)
+	}
+	if len(resp.Errors) != 1 || resp.Errors[0].Dependency != "billing" {
+		t.Errorf("errors = %+v, want billing's", resp.Errors)
+	}
+}
+
+func TestMeshHealthCached(t *testing.T) {
+	setForTest(t, &meshHealthCacheTTL, time.Minute)
+	var calls atomic.Int32
+	useOrderService(t, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Write([]byte(`{"status":"healthy"}`))
+	})
+	t.Cleanup(func() { meshHealthCache.response = nil })
+	meshHealthCache.response = nil
+
+	for i := 0; i < 3; i++ {
+		serveHandler(meshHealthHandler, http.MethodGet, "/mesh/health", "")
+	}
+	if calls.Load() != 1 {
+		t.Errorf("order-service checked %d times, want once within MESH_HEALTH_CACHE_TTL", calls.Load())
+	}
+}
+
+func TestParseMeshDependencies(t *testing.T) {
+	deps := parseMeshDependencies("billing=https://billing.run.app, audit = https://audit.run.app ,broken")
+	if len(deps) != 2 || deps[0] != (meshDependency{"billing", "https://billing.run.app"}) || deps[1] != (meshDependency{"audit", "https://audit.run.app"}) {
+		t.Fatalf("dependencies = %+v", deps)
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Prometheus metrics served at /metrics (alongside the Go runtime and process
+// collectors registered by default)
+var (
+	httpRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "user_service_http_requests_total",
+		Help: "HTTP requests handled, by route, method and status code.",
+	}, []string{"path", "method", "status"})
+
+	httpRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "user_service_http_request_duration_seconds",
+		Help:    "HTTP request latency, by route and method.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"path", "method"})
+
+	outboundRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "user_service_outbound_requests_total",
+		Help: "Authenticated calls to backend services (e.g. the Order Service), by backend and result.",
+	}, []string{"backend", "result"})
+
+	circuitBreakerState = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "user_service_circuit_breaker_state",
+		Help: "Circuit breaker state per backend: 0 closed, 1 half-open, 2 open.",
+	}, []string{"backend"})
+
+	// Set by the concurrency sampler every METRICS_SAMPLE_INTERVAL (see
+	// concurrency_metrics.go), so scrapes and pushes see the same values
+	inFlightRequestsGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "user_service_inflight_requests",
+		Help: "Requests being served, as of the latest sample.",
+	})
+
+	outboundQueueDepthGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "user_service_outbound_queue_depth",
+		Help: "Outbound calls waiting for a backend concurrency slot, as of the latest sample.",
+	})
+
+	concurrencySamplesTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "user_service_concurrency_samples_total",
+		Help: "Samples of the in-flight and queue-depth gauges published.",
+	})
+
+	// The demo counters (see counters.go). Unlike /stats these are never
+	// reset, as Prometheus expects of counters.
+	usersCreatedMetric = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "user_service_users_created_total",
+		Help: "Users created.",
+	})
+
+	usersDeletedMetric = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "user_service_users_deleted_total",
+		Help: "Users deleted.",
+	})
+
+	usersEvictedMetric = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "user_service_users_evicted_total",
+		Help: "Users evicted to keep the store within its limit.",
+	})
+
+	ordersFetchedMetric = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "user_service_orders_fetched_total",
+		Help: "Successful order fetches from the Order Service.",
+	})
+)
+
+// observeRequest records a completed request
+func observeRequest(r *http.Request, status int, latency time.Duration) {
+	route := metricsRoute(r)
+	httpRequestsTotal.WithLabelValues(route, r.Method, strconv.Itoa(status)).Inc()
+	httpRequestDuration.WithLabelValues(route, r.Method).Observe(latency.Seconds())
+}
+
+// observeOutbound records the outcome of a call made by makeAuthenticatedRequest
+func observeOutbound(backend string, err error) {
+	result := "success"
+	if err != nil {
+		result = "failure"
+	}
+	outboundRequestsTotal.WithLabelValues(backend, result).Inc()
+}
+
+// observeCircuitState records a backend's circuit breaker state
+func observeCircuitState(backend, state string) {
+	value := 0.0
+	switch state {
+	case circuitHalfOpen:
+		value = 1
+	case circuitOpen:
+		value = 2
+	}
+	circuitBreakerState.WithLabelValues(backend).Set(value)
+}
+
+// metricsRoute labels a request by the route that served it rather than its
+// raw path, so user IDs (or scanners probing random URLs) can't create
+// unbounded series
+func metricsRoute(r *http.Request) string {
+	_, pattern := http.DefaultServeMux.Handler(r)
+	if pattern != "/users/" {
+		return pattern
+	}
+	// Mirrors userByIDHandler's routing
+	userID, subresource, ok := parseUserPath(r.URL.EscapedPath())
+	switch {
+	case !ok || userID == "":
+		return pattern
+	case subresource == "orders":
+		return "/users/{id}/orders"
+	default:
+		return "/users/{id}"
+	}
+}
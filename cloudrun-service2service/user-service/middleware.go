@@ -0,0 +1,50 @@
+package main
+
+import "net/http"
+
+// Middleware wraps a handler with a cross-cutting concern
+type Middleware func(http.Handler) http.Handler
+
+// Chain composes middlewares into one, the first listed outermost: it sees
+// each request first and its response last
+func Chain(middlewares ...Middleware) Middleware {
+	return func(handler http.Handler) http.Handler {
+		for i := len(middlewares) - 1; i >= 0; i-- {
+			handler = middlewares[i](handler)
+		}
+		return handler
+	}
+}
+
+// serverMiddleware is what every request passes through on its way to the
+// routes, outermost first. Per-route policy (role, timeout, rate) is applied
+// by registerRoutes instead.
+var serverMiddleware = []Middleware{
+	// withH2C serves HTTP/2 requests on goroutines of its own, so a recover
+	// above it wouldn't see their panics
+	withH2C,
+	recoverPanics,
+	// BASE_PATH is stripped first, so everything below sees unprefixed paths
+	stripBasePath,
+	// Observability: the trace span and in-flight gauge cover the whole
+	// request; logRequest assigns the request ID everything after it logs with
+	traceRequests,
+	trackInFlight,
+	logRequest,
+	logBodies,
+	// Cross-origin preflights are answered before auth, which they can't pass
+	withCORS,
+	// Signs whatever is written below it, error responses included
+	signResponseBodies,
+	// Identify the caller, then limit and authorize them
+	authenticate,
+	limitCallerRate,
+	withCallerRole,
+	// Per-request settings carried in the context or the response writer
+	withFeatureOverrides,
+	withPropagatedValues,
+	withIdempotencyKey,
+	negotiateResponseVersion,
+	withResponseTimezone,
+	limitRequestBody,
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// tracingMiddleware records when name sees a request and its response
+func tracingMiddleware(name string, trace *[]string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			*trace = append(*trace, name+" in")
+			next.ServeHTTP(w, r)
+			*trace = append(*trace, name+" out")
+		})
+	}
+}
+
+func TestChainOrder(t *testing.T) {
+	var trace []string
+	handler := Chain(tracingMiddleware("a", &trace), tracingMiddleware("b", &trace), tracingMiddleware("c", &trace))(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { trace = append(trace, "handler") }))
+
+	serveHandler(handler.ServeHTTP, http.MethodGet, "/", "")
+	want := []string{"a in", "b in", "c in", "handler", "c out", "b out", "a out"}
+	if !slices.Equal(trace, want) {
+		t.Errorf("trace = %v, want %v", trace, want)
+	}
+}
+
+func TestChainEmpty(t *testing.T) {
+	called := false
+	handler := Chain()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { called = true }))
+	serveHandler(handler.ServeHTTP, http.MethodGet, "/", "")
+	if !called {
+		t.Error("an empty chain didn't call the handler")
+	}
+}
+
+func TestServerMiddlewareStripsBasePathBeforeLogging(t *testing.T) {
+	useTestStore(t)
+	setForTest(t, &basePath, "/api")
+	logs := captureLogs(t, slog.LevelInfo)
+
+	resp, body := doRequest(t, newTestServer(t), http.MethodGet, "/api/users/user-001", "")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", resp.StatusCode, body)
+	}
+	// logRequest is inside stripBasePath, so it logs the route's path
+	if !strings.Contains(logs.String(), `"path":"/users/user-001"`) {
+		t.Errorf("request not logged under its unprefixed path:\n%s", logs)
+	}
+}
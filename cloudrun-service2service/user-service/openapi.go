@@ -0,0 +1,87 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// The API description is hand-authored in api/openapi.json and embedded in
+// the binary. checkOpenAPIRoutes runs at startup and logs any documented path
+// that no handler serves, so the spec and the routes can't drift silently.
+
+//go:embed api/openapi.json
+var openAPISpec []byte
+
+// servedOpenAPISpec is the spec as served, with BASE_PATH as its server
+var servedOpenAPISpec = withServersEntry(openAPISpec)
+
+// openAPIPaths are the paths documented in the spec
+var openAPIPaths = mustParseOpenAPIPaths(openAPISpec)
+
+func mustParseOpenAPIPaths(data []byte) []string {
+	var spec struct {
+		Paths map[string]json.RawMessage `json:"paths"`
+	}
+	if err := json.Unmarshal(data, &spec); err != nil {
+		panic(fmt.Sprintf("invalid embedded OpenAPI spec: %v", err))
+	}
+	paths := make([]string, 0, len(spec.Paths))
+	for path := range spec.Paths {
+		paths = append(paths, path)
+	}
+	return paths
+}
+
+// openAPIHandler handles GET /openapi.json
+func openAPIHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		methodNotAllowed(w, r, http.MethodGet, http.MethodHead)
+		return
+	}
+	writeEncodedJSON(w, http.StatusOK, servedOpenAPISpec)
+}
+
+// swaggerUIPage renders the spec with Swagger UI, loaded from a CDN by the browser
+const swaggerUIPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>User Service (Go) API</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+  <script>SwaggerUIBundle({url: "%s", dom_id: "#swagger-ui"});</script>
+</body>
+</html>
+`
+
+// docsHandler handles GET /docs
+func docsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		methodNotAllowed(w, r, http.MethodGet, http.MethodHead)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprintf(w, swaggerUIPage, selfURL("/openapi.json"))
+}
+
+// checkOpenAPIRoutes logs every documented path that falls through to the
+// catch-all health handler, i.e. that no registered route serves
+func checkOpenAPIRoutes(mux *http.ServeMux) {
+	for _, path := range openAPIPaths {
+		concrete := strings.ReplaceAll(path, "{id}", "example")
+		req, err := http.NewRequest(http.MethodGet, concrete, nil)
+		if err != nil {
+			logger.Warn("OpenAPI spec documents an invalid path", "path", path, "error", err)
+			continue
+		}
+		if _, pattern := mux.Handler(req); pattern == "" || pattern == "/" {
+			logger.Warn("OpenAPI spec documents a path with no route", "path", path)
+		}
+	}
+}
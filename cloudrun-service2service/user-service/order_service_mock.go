@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"strings"
+	"time"
+)
+
+// Order Service mode configuration.
+// ORDER_SERVICE_MODE=mock answers orders requests (/users/{id}/orders and
+// the orders batch) with canned orders generated from the user ID, without
+// calling the Order Service - so the whole flow can be demonstrated on a
+// laptop with no Order Service deployed and no Google credentials. The
+// default, "live", calls ORDER_SERVICE_URL with an OIDC token.
+var orderServiceMode = strings.ToLower(envString("ORDER_SERVICE_MODE", "live"))
+
+// mockOrdersFlow replaces UserWithOrders.Flow in mock mode, so a demo never
+// passes off canned orders as the real service-to-service call
+const mockOrdersFlow = "User Service (Go) → mock Order Service (ORDER_SERVICE_MODE=mock)"
+
+// checkOrderServiceMode reports an unknown ORDER_SERVICE_MODE
+func checkOrderServiceMode() error {
+	if orderServiceMode != "live" && orderServiceMode != "mock" {
+		return fmt.Errorf("unknown ORDER_SERVICE_MODE %q (want live or mock)", orderServiceMode)
+	}
+	return nil
+}
+
+// mockOrderService reports whether orders come from mockUserOrders
+func mockOrderService() bool {
+	return orderServiceMode == "mock"
+}
+
+// mockProducts are what mock orders are made of
+var mockProducts = []struct {
+	Name  string
+	Price float64
+}{
+	{"Widget A", 29.99},
+	{"Widget B", 49.99},
+	{"Widget C", 15.99},
+	{"Gadget X", 199.99},
+	{"Gadget Y", 89.50},
+	{"Accessory D", 9.99},
+	{"Accessory E", 4.75},
+}
+
+// mockOrderStatuses are the statuses the orders contract allows
+var mockOrderStatuses = []string{"pending", "processing", "shipped", "completed", "cancelled"}
+
+type mockOrderItem struct {
+	Product  string  `json:"product"`
+	Quantity int     `json:"quantity"`
+	Price    float64 `json:"price"`
+}
+
+type mockOrder struct {
+	ID        string          `json:"id"`
+	UserID    string          `json:"userId"`
+	Items     []mockOrderItem `json:"items"`
+	Total     float64         `json:"total"`
+	Status    string          `json:"status"`
+	CreatedAt string          `json:"createdAt"`
+}
+
+// mockUserOrders returns plausible orders for userID in the Order Service's
+// response shape. The same user always gets the same orders (1-3 of them),
+// dated relative to now, or to the demo epoch with DETERMINISTIC_DEMO. It's
+// decoded from JSON so callers see exactly what a real response would give.
+func mockUserOrders(userID string) interface{} {
+	h := fnv.New64a()
+	h.Write([]byte(userID))
+	seed := h.Sum64()
+	next := func(n int) int {
+		// xorshift keeps successive picks for one user from repeating
+		seed ^= seed << 13
+		seed ^= seed >> 7
+		seed ^= seed << 17
+		return int(seed % uint64(n))
+	}
+
+	now := time.Now().UTC()
+	if deterministicDemo {
+		now = demoEpoch
+	}
+
+	orders := make([]mockOrder, 1+next(3))
+	for i := range orders {
+		items := make([]mockOrderItem, 1+next(3))
+		total := 0.0
+		for j := range items {
+			product := mockProducts[next(len(mockProducts))]
+			items[j] = mockOrderItem{Product: product.Name, Quantity: 1 + next(3), Price: product.Price}
+			total += float64(items[j].Quantity) * product.Price
+		}
+		orders[i] = mockOrder{
+			ID:        fmt.Sprintf("mock-%s-%d", userID, i+1),
+			UserID:    userID,
+			Items:     items,
+			Total:     math.Round(total*100) / 100,
+			Status:    mockOrderStatuses[next(len(mockOrderStatuses))],
+			CreatedAt: now.Add(-time.Duration(1+next(30*24)) * time.Hour).Format(time.RFC3339),
+		}
+	}
+
+	data, _ := json.Marshal(map[string]interface{}{
+		"service": "order-service (mock)",
+		"userId":  userID,
+		"count":   len(orders),
+		"orders":  orders,
+	})
+	var decoded interface{}
+	_ = json.Unmarshal(data, &decoded)
+	return decoded
+}
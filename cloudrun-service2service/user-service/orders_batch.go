@@ -0,0 +1,222 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+
+	"golang.org/x/text/language"
+)
+
+// Orders batch configuration.
+// POST /users/orders/batch fetches orders for many users in one request. At
+// most ORDERS_BATCH_CONCURRENCY Order Service calls run at once for a batch,
+// so a large batch doesn't hammer the Order Service (the per-backend
+// OUTBOUND_MAX_CONCURRENCY cap still applies across all requests), and a
+// batch may name at most ORDERS_BATCH_MAX_USERS users.
+var (
+	ordersBatchConcurrency = envInt("ORDERS_BATCH_CONCURRENCY", 5)
+	ordersBatchMaxUsers    = envInt("ORDERS_BATCH_MAX_USERS", 100)
+)
+
+// OrdersBatchRequest is the body of POST /users/orders/batch
+type OrdersBatchRequest struct {
+	UserIDs []string `json:"user_ids"`
+}
+
+// UserOrdersResult is one user's entry in an orders batch: the user and
+// their orders, or the error that stopped them being fetched
+type UserOrdersResult struct {
+	UserID   string         `json:"user_id"`
+	User     *User          `json:"user,omitempty"`
+	Orders   interface{}    `json:"orders,omitempty"`
+	Cached   bool           `json:"cached,omitempty"`
+	Warnings []string       `json:"warnings,omitempty"`
+	Error    *ErrorResponse `json:"error,omitempty"`
+}
+
+// OrdersBatchResponse represents the response for POST /users/orders/batch
+type OrdersBatchResponse struct {
+	Service   string             `json:"service"`
+	Succeeded int                `json:"succeeded"`
+	Failed    int                `json:"failed"`
+	Results   []UserOrdersResult `json:"results"`
+}
+
+// fanOut calls fn for items 0..n-1 with at most limit calls running at once
+// (limit < 1 runs them one at a time) and returns each call's error. Once ctx
+// is done, items that haven't started are skipped with ctx's error, and the
+// ctx passed to fn lets running calls stop early.
+func fanOut(ctx context.Context, n, limit int, fn func(ctx context.Context, i int) error) []error {
+	errs := make([]error, n)
+	slots := make(chan struct{}, max(limit, 1))
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			for ; i < n; i++ {
+				errs[i] = ctx.Err()
+			}
+			wg.Wait()
+			return errs
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			errs[i] = fn(ctx, i)
+		}(i)
+	}
+	wg.Wait()
+	return errs
+}
+
+// ordersBatchHandler handles POST /users/orders/batch: each listed user and
+// their orders, fetched in parallel. A user that can't be found or whose
+// orders can't be fetched gets an error entry without failing the rest: 200
+// when every user succeeded, 207 Multi-Status otherwise.
+func ordersBatchHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, r, http.MethodPost)
+		return
+	}
+
+	if !requireJSONContentType(w, r) {
+		return
+	}
+	var req OrdersBatchRequest
+	if !decodeJSONBody(w, r, r.Body, &req) {
+		return
+	}
+	if len(req.UserIDs) == 0 {
+		writeError(w, r, http.StatusBadRequest, "user_ids_required")
+		return
+	}
+	if len(req.UserIDs) > ordersBatchMaxUsers {
+		writeError(w, r, http.StatusRequestEntityTooLarge, "batch_too_large", len(req.UserIDs), ordersBatchMaxUsers)
+		return
+	}
+
+	baseURL := getOrderServiceURL()
+	if baseURL == "" && !mockOrderService() {
+		writeErrorResponse(w, r, http.StatusServiceUnavailable, newErrorResponse("not_configured", "ORDER_SERVICE_URL not configured - cannot fetch orders"))
+		return
+	}
+
+	lang := requestLanguage(r)
+	query := forwardedOrderQuery(r)
+	useCache := !bypassOrdersCache(r)
+	results := make([]UserOrdersResult, len(req.UserIDs))
+	errs := fanOut(r.Context(), len(req.UserIDs), ordersBatchConcurrency, func(ctx context.Context, i int) error {
+		result, err := fetchBatchUserOrders(ctx, baseURL, req.UserIDs[i], query, useCache)
+		results[i] = result
+		return err
+	})
+
+	response := OrdersBatchResponse{
+		Service: "user-service (Go)",
+		Results: results,
+	}
+	for i, err := range errs {
+		results[i].UserID = req.UserIDs[i]
+		if err == nil {
+			response.Succeeded++
+			continue
+		}
+		response.Failed++
+		results[i].User, results[i].Orders = nil, nil
+		results[i].Error = batchItemError(lang, req.UserIDs[i], err)
+	}
+	if clientDeadlineExceeded(r.Context()) {
+		writeClientDeadlineExceeded(w, r)
+		return
+	}
+
+	status := http.StatusOK
+	if response.Failed > 0 {
+		status = http.StatusMultiStatus
+	}
+	w.Header().Set("Content-Language", lang.String())
+	ordersFetchedTotal.Add(int64(response.Succeeded))
+	writeJSON(w, status, response)
+}
+
+// fetchBatchUserOrders looks up userID and fetches their orders, from the
+// orders cache when allowed
+func fetchBatchUserOrders(ctx context.Context, baseURL, userID, query string, useCache bool) (UserOrdersResult, error) {
+	result := UserOrdersResult{UserID: userID}
+	if err := validateUserID(userID); err != nil {
+		return result, err
+	}
+	user, err := findUser(ctx, userID)
+	if err != nil {
+		return result, err
+	}
+	result.User = &user
+
+	// Orders stay filed under the user's pre-migration ID, if it had one
+	orderPath := legacyIDFor(userID)
+	orderURL := fmt.Sprintf("%s/orders/user/%s", baseURL, url.PathEscape(orderPath))
+	if query != "" {
+		orderURL += "?" + query
+	}
+	cacheKey := orderPath + "?" + query
+	if useCache {
+		if orders, ok := cachedUserOrders(cacheKey); ok {
+			result.Orders, result.Cached = orders, true
+			return result, nil
+		}
+	}
+
+	// Canned orders aren't cached, so they can't be served once the mode is
+	// switched back to live
+	if mockOrderService() {
+		result.Orders = mockUserOrders(orderPath)
+		return result, nil
+	}
+
+	ordersData, err := makeAuthenticatedGet(ctx, orderURL)
+	var statusErr *statusError
+	if errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusNotFound {
+		result.Orders, result.Warnings = emptyUserOrders(userID), []string{noOrdersWarning}
+		return result, nil
+	}
+	if err != nil {
+		logger.ErrorContext(ctx, "Error calling Order Service", "error", err, "user_id", userID)
+		return result, err
+	}
+	var orders interface{}
+	if err := json.Unmarshal(ordersData, &orders); err != nil {
+		return result, fmt.Errorf("%w: %v", errBadUpstreamResponse, err)
+	}
+	if err := checkOrdersContract(ctx, orders); err != nil {
+		return result, fmt.Errorf("%w: %v", errBadUpstreamResponse, err)
+	}
+	storeUserOrders(cacheKey, orders)
+	result.Orders = orders
+	return result, nil
+}
+
+// batchItemError describes why userID's entry in a batch failed
+func batchItemError(lang language.Tag, userID string, err error) *ErrorResponse {
+	var resp ErrorResponse
+	var statusErr *statusError
+	switch {
+	case validateUserID(userID) != nil:
+		resp = localizedError(lang, "user_id_invalid", loggableUserID(userID), userIDPattern.String(), maxUserIDLength)
+	case errors.Is(err, errUserNotFound):
+		resp = localizedError(lang, "user_not_found", userID)
+	case errors.As(err, &statusErr):
+		_, code, message := ordersStatusFailure(statusErr.StatusCode)
+		resp = upstreamFailure("order-service", message, err)
+		resp.Code = code
+	default:
+		resp = upstreamFailure("order-service", clientErrorMessage("Failed to fetch orders from Order Service", err), err)
+	}
+	return &resp
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// postOrdersBatch POSTs a batch for userIDs and decodes the response
+func postOrdersBatch(t *testing.T, userIDs ...string) (int, OrdersBatchResponse) {
+	t.Helper()
+	body, _ := json.Marshal(OrdersBatchRequest{UserIDs: userIDs})
+	rec := serveHandler(ordersBatchHandler, http.MethodPost, "/users/orders/batch", string(body), "Content-Type", "application/json")
+	var resp OrdersBatchResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("status %d: %v: %s", rec.Code, err, rec.Body)
+	}
+	return rec.Code, resp
+}
+
+func TestOrdersBatch(t *testing.T) {
+	useTestStore(t)
+	useOrderService(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(testOrdersJSON(strings.TrimPrefix(r.URL.Path, "/orders/user/"))))
+	})
+
+	status, resp := postOrdersBatch(t, "user-003", "user-001")
+	if status != http.StatusOK || resp.Succeeded != 2 || resp.Failed != 0 {
+		t.Fatalf("status = %d, %d succeeded, %d failed; want 200 with both", status, resp.Succeeded, resp.Failed)
+	}
+	// Results come back in request order, whichever call finished first
+	for i, want := range []string{"user-003", "user-001"} {
+		result := resp.Results[i]
+		if result.UserID != want || result.User == nil || result.User.ID != want || result.Orders == nil || result.Error != nil {
+			t.Errorf("results[%d] = %+v, want %s with orders", i, result, want)
+		}
+	}
+}
+
+func TestOrdersBatchPartialFailure(t *testing.T) {
+	useTestStore(t)
+	setForTest(t, &outboundMaxRetries, 0)
+	useOrderService(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/orders/user/user-002" {
+			http.Error(w, "boom", http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte(testOrdersJSON("user-001")))
+	})
+
+	status, resp := postOrdersBatch(t, "user-001", "user-002", "user-999", "bad/id")
+	if status != http.StatusMultiStatus || resp.Succeeded != 1 || resp.Failed != 3 {
+		t.Fatalf("status = %d, %d succeeded, %d failed; want 207 with 1 and 3", status, resp.Succeeded, resp.Failed)
+	}
+	if resp.Results[0].Error != nil || resp.Results[0].Orders == nil {
+		t.Errorf("user-001 = %+v, want its orders", resp.Results[0])
+	}
+	for i, wantCode := range map[int]string{1: "upstream_unavailable", 2: "user_not_found", 3: "user_id_invalid"} {
+		result := resp.Results[i]
+		if result.Error == nil || result.Error.Code != wantCode || result.User != nil || result.Orders != nil {
+			t.Errorf("results[%d] = %+v, want only a %s error", i, result, wantCode)
+		}
+	}
+}
+
+func TestOrdersBatchConcurrencyBounded(t *testing.T) {
+	useTestStore(t)
+	setForTest(t, &ordersBatchConcurrency, 2)
+	var inFlight, peak atomic.Int32
+	useOrderService(t, func(w http.ResponseWriter, r *http.Request) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		w.Write([]byte(testOrdersJSON(strings.TrimPrefix(r.URL.Path, "/orders/user/"))))
+	})
+	ids := []string{"user-001", "user-002", "user-003"}
+	for i := 4; i <= 6; i++ {
+		id := fmt.Sprintf("user-%03d", i)
+		createUserForTest(t, fmt.Sprintf(`{"id":%q,"name":"User %d","email":"user%d@example.com","role":"viewer"}`, id, i, i), http.StatusCreated)
+		ids = append(ids, id)
+	}
+
+	if status, resp := postOrdersBatch(t, ids...); status != http.StatusOK || resp.Succeeded != len(ids) {
+		t.Fatalf("status = %d, %d succeeded; want 200 with all %d", status, resp.Succeeded, len(ids))
+	}
+	if got := peak.Load(); got != 2 {
+		t.Errorf("peak concurrent Order Service calls = %d, want ORDERS_BATCH_CONCURRENCY (2)", got)
+	}
+}
+
+func TestOrdersBatchInvalid(t *testing.T) {
+	useTestStore(t)
+	setForTest(t, &ordersBatchMaxUsers, 2)
+
+	for _, tc := range []struct {
+		method, body, wantCode string
+		want                   int
+	}{
+		{http.MethodPost, `{"user_ids":[]}`, "user_ids_required", http.StatusBadRequest},
+		{http.MethodPost, `{"user_ids":["user-001","user-002","user-003"]}`, "batch_too_large", http.StatusRequestEntityTooLarge},
+		{http.MethodPost, `{"user_ids":`, "", http.StatusBadRequest},
+		{http.MethodGet, "", "", http.StatusMethodNotAllowed},
+	} {
+		rec := serveHandler(ordersBatchHandler, tc.method, "/users/orders/batch", tc.body, "Content-Type", "application/json")
+		if rec.Code != tc.want || !strings.Contains(rec.Body.String(), tc.wantCode) {
+			t.Errorf("%s %s: status = %d, want %d %s: %s", tc.method, tc.body, rec.Code, tc.want, tc.wantCode, rec.Body)
+		}
+	}
+}
+
+func TestFanOutStopsWhenCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var calls atomic.Int32
+	errs := fanOut(ctx, 5, 1, func(ctx context.Context, i int) error {
+		calls.Add(1)
+		cancel()
+		// Holding the only slot, so the rest can't start before the cancel is seen
+		time.Sleep(50 * time.Millisecond)
+		return nil
+	})
+	if got := calls.Load(); got != 1 {
+		t.Errorf("fn called %d times, want 1 before the cancel", got)
+	}
+	for i, err := range errs[1:] {
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("errs[%d] = %v, want context.Canceled", i+1, err)
+		}
+	}
+}
+
+func TestOrdersBatchMockOrdersNotCached(t *testing.T) {
+	useTestStore(t)
+	var calls atomic.Int32
+	useOrderService(t, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Write([]byte(testOrdersJSON("user-001")))
+	})
+	useOrdersCache(t, time.Minute)
+
+	setForTest(t, &orderServiceMode, "mock")
+	if status, resp := postOrdersBatch(t, "user-001"); status != http.StatusOK || resp.Results[0].Orders == nil {
+		t.Fatalf("mock: status = %d, %+v; want the canned orders", status, resp.Results[0])
+	}
+
+	// Back to live, the Order Service is asked rather than the canned orders replayed
+	orderServiceMode = "live"
+	status, resp := postOrdersBatch(t, "user-001")
+	if status != http.StatusOK || resp.Results[0].Cached || calls.Load() != 1 {
+		t.Errorf("live: status = %d, cached %v after %d Order Service calls; want 200 from the Order Service", status, resp.Results[0].Cached, calls.Load())
+	}
+}
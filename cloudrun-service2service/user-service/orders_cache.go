@@ -0,0 +1,91 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Orders cache configuration.
+// A user's orders rarely change between rapid repeated requests, so the
+// parsed Order Service response is kept for ORDERS_CACHE_TTL (0 disables the
+// cache). ORDERS_CACHE_MAX_ENTRIES bounds memory; when it's full, new
+// responses aren't cached until entries expire.
+var (
+	ordersCacheTTL        = envDuration("ORDERS_CACHE_TTL", 30*time.Second)
+	ordersCacheMaxEntries = envInt("ORDERS_CACHE_MAX_ENTRIES", 1000)
+)
+
+// cachedOrders is an Order Service response and when it stops being served
+type cachedOrders struct {
+	orders interface{}
+	expiry time.Time
+}
+
+// ordersCache holds orders responses keyed by the Order Service path and
+// query, so different field selections for the same user are cached apart
+var ordersCache = struct {
+	sync.Mutex
+	entries map[string]cachedOrders
+}{entries: make(map[string]cachedOrders)}
+
+// cachedUserOrders returns the cached orders for key if they haven't expired.
+// Expired entries are evicted here rather than by a background sweeper.
+func cachedUserOrders(key string) (interface{}, bool) {
+	if ordersCacheTTL <= 0 {
+		return nil, false
+	}
+
+	ordersCache.Lock()
+	defer ordersCache.Unlock()
+
+	cached, ok := ordersCache.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(cached.expiry) {
+		delete(ordersCache.entries, key)
+		return nil, false
+	}
+	return cached.orders, true
+}
+
+// storeUserOrders caches orders for key for ORDERS_CACHE_TTL. The cached value
+// is shared between responses, so callers must not modify it afterwards.
+func storeUserOrders(key string, orders interface{}) {
+	if ordersCacheTTL <= 0 {
+		return
+	}
+
+	ordersCache.Lock()
+	defer ordersCache.Unlock()
+
+	if _, ok := ordersCache.entries[key]; !ok && len(ordersCache.entries) >= ordersCacheMaxEntries {
+		// Make room by dropping whatever has expired; if nothing has, skip caching
+		now := time.Now()
+		for k, cached := range ordersCache.entries {
+			if now.After(cached.expiry) {
+				delete(ordersCache.entries, k)
+			}
+		}
+		if len(ordersCache.entries) >= ordersCacheMaxEntries {
+			return
+		}
+	}
+	ordersCache.entries[key] = cachedOrders{
+		orders: orders,
+		expiry: time.Now().Add(ordersCacheTTL),
+	}
+}
+
+// bypassOrdersCache reports whether the client asked for fresh orders with
+// Cache-Control: no-cache (or Pragma: no-cache, from HTTP/1.0 clients)
+func bypassOrdersCache(r *http.Request) bool {
+	for _, directive := range strings.Split(r.Header.Get("Cache-Control"), ",") {
+		if strings.EqualFold(strings.TrimSpace(directive), "no-cache") {
+			return true
+		}
+	}
+	return strings.EqualFold(strings.TrimSpace(r.Header.Get("Pragma")), "no-cache")
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// useOrdersCache turns the orders cache on with ttl, emptying it after the test
+func useOrdersCache(t *testing.T, ttl time.Duration) {
+	t.Helper()
+	setForTest(t, &ordersCacheTTL, ttl)
+	t.Cleanup(func() {
+		ordersCache.Lock()
+		clear(ordersCache.entries)
+		ordersCache.Unlock()
+	})
+}
+
+func TestOrdersCache(t *testing.T) {
+	useTestStore(t)
+	var calls atomic.Int32
+	useOrderService(t, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(testOrdersJSON("user-001")))
+	})
+	useOrdersCache(t, time.Minute)
+	server := newTestServer(t)
+
+	cached := func(headers ...string) bool {
+		t.Helper()
+		resp, body := doRequest(t, server, http.MethodGet, "/users/user-001/orders", "", headers...)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("status = %d, want 200: %s", resp.StatusCode, body)
+		}
+		var orders UserWithOrders
+		if err := json.Unmarshal([]byte(body), &orders); err != nil {
+			t.Fatal(err)
+		}
+		return orders.Cached
+	}
+
+	if cached() {
+		t.Error("first request was served from the cache")
+	}
+	if !cached() {
+		t.Error("repeat request wasn't served from the cache")
+	}
+	// The client can ask for fresh orders
+	if cached("Cache-Control", "no-cache") || cached("Pragma", "no-cache") {
+		t.Error("no-cache request was served from the cache")
+	}
+	if got := calls.Load(); got != 3 {
+		t.Errorf("Order Service called %d times, want 3 (first and both no-cache requests)", got)
+	}
+}
+
+func TestOrdersCacheExpiry(t *testing.T) {
+	useOrdersCache(t, time.Minute)
+	storeUserOrders("user-001?", "orders")
+	if _, ok := cachedUserOrders("user-001?"); !ok {
+		t.Fatal("stored orders weren't cached")
+	}
+
+	ordersCache.Lock()
+	entry := ordersCache.entries["user-001?"]
+	entry.expiry = time.Now().Add(-time.Second)
+	ordersCache.entries["user-001?"] = entry
+	ordersCache.Unlock()
+	if _, ok := cachedUserOrders("user-001?"); ok {
+		t.Fatal("expired orders were served")
+	}
+	ordersCache.Lock()
+	defer ordersCache.Unlock()
+	if _, ok := ordersCache.entries["user-001?"]; ok {
+		t.Error("expired entry wasn't evicted on lookup")
+	}
+}
+
+func TestOrdersCacheMaxEntries(t *testing.T) {
+	useOrdersCache(t, time.Minute)
+	setForTest(t, &ordersCacheMaxEntries, 2)
+
+	storeUserOrders("user-001?", "a")
+	storeUserOrders("user-002?", "b")
+	storeUserOrders("user-003?", "c")
+	if _, ok := cachedUserOrders("user-003?"); ok {
+		t.Error("cached past ORDERS_CACHE_MAX_ENTRIES")
+	}
+	// Existing entries can still be refreshed
+	storeUserOrders("user-001?", "a2")
+	if orders, _ := cachedUserOrders("user-001?"); orders != "a2" {
+		t.Errorf("user-001 orders = %v, want the refreshed a2", orders)
+	}
+}
+
+func TestOrdersCacheDisabled(t *testing.T) {
+	useOrdersCache(t, 0)
+	storeUserOrders("user-001?", "orders")
+	if _, ok := cachedUserOrders("user-001?"); ok {
+		t.Error("orders cached with ORDERS_CACHE_TTL=0")
+	}
+}
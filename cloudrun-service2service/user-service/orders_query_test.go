@@ -0,0 +1,52 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestOrdersForwardFieldSelection(t *testing.T) {
+	useTestStore(t)
+	var mu sync.Mutex
+	var queries []url.Values
+	useOrderService(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		queries = append(queries, r.URL.Query())
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(testOrdersJSON("user-001")))
+	})
+	setForTest(t, &ordersCacheTTL, time.Minute)
+	t.Cleanup(func() {
+		ordersCache.Lock()
+		clear(ordersCache.entries)
+		ordersCache.Unlock()
+	})
+	server := newTestServer(t)
+
+	for _, path := range []string{
+		"/users/user-001/orders?fields=id,total&view=summary&debug=1",
+		// A different selection is cached apart, so it reaches the backend too
+		"/users/user-001/orders?view=full",
+		"/users/user-001/orders?view=full",
+	} {
+		if resp, body := doRequest(t, server, http.MethodGet, path, ""); resp.StatusCode != http.StatusOK {
+			t.Fatalf("GET %s: status = %d, want 200: %s", path, resp.StatusCode, body)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(queries) != 2 {
+		t.Fatalf("backend called %d times, want 2 (the repeat is cached)", len(queries))
+	}
+	if got := queries[0]; got.Get("fields") != "id,total" || got.Get("view") != "summary" || got.Has("debug") {
+		t.Errorf("first backend query = %v, want only fields and view", got)
+	}
+	if got := queries[1]; got.Get("view") != "full" || got.Has("fields") {
+		t.Errorf("second backend query = %v, want view=full", got)
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// An Order Service call that gets an HTTP error response reached the Order
+// Service, so it isn't a 502: the client gets a status saying where the
+// problem lies, and the downstream status is in errors[].status.
+//
+//   - 404: the Order Service has nothing filed for the user, so they're
+//     returned with no orders (and a warning, in case the URL is wrong)
+//   - 401/403: the Order Service refused this service's credentials, a
+//     deployment problem on our side (500, upstream_auth_failed)
+//   - 429 and 5xx: the Order Service is overloaded or failing (503,
+//     upstream_unavailable)
+//   - other 4xx: the Order Service rejected the request we built (500,
+//     upstream_rejected)
+
+// noOrdersWarning explains the empty orders returned for a downstream 404
+const noOrdersWarning = "The Order Service has no orders for this user (HTTP 404)"
+
+// emptyUserOrders is the Order Service's response shape for a user with no orders
+func emptyUserOrders(userID string) map[string]interface{} {
+	return map[string]interface{}{
+		"service": "order-service",
+		"userId":  userID,
+		"count":   0,
+		"orders":  []interface{}{},
+	}
+}
+
+// ordersStatusFailure returns the status, error code and message for an
+// Order Service error response other than 404
+func ordersStatusFailure(downstream int) (int, string, string) {
+	switch {
+	case downstream == http.StatusUnauthorized || downstream == http.StatusForbidden:
+		return http.StatusInternalServerError, "upstream_auth_failed", fmt.Sprintf("The Order Service refused user-service's credentials (HTTP %d) - check its roles/run.invoker grant", downstream)
+	case downstream == http.StatusTooManyRequests || downstream >= 500:
+		return http.StatusServiceUnavailable, "upstream_unavailable", fmt.Sprintf("The Order Service failed (HTTP %d) - try again shortly", downstream)
+	default:
+		return http.StatusInternalServerError, "upstream_rejected", fmt.Sprintf("The Order Service rejected the request (HTTP %d)", downstream)
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestOrdersErrorStatusesMapped(t *testing.T) {
+	useTestStore(t)
+	setForTest(t, &outboundMaxRetries, 0)
+	// A 401/403 is retried once with a fresh token, which is rejected too
+	setForTest(t, &impersonateServiceAccount, "")
+	useMetadataServer(t, func(audience string) string {
+		return testIDToken(map[string]interface{}{"aud": audience})
+	})
+
+	for _, tc := range []struct {
+		downstream, want int
+		wantCode         string
+	}{
+		{http.StatusUnauthorized, http.StatusInternalServerError, "upstream_auth_failed"},
+		{http.StatusForbidden, http.StatusInternalServerError, "upstream_auth_failed"},
+		{http.StatusTooManyRequests, http.StatusServiceUnavailable, "upstream_unavailable"},
+		{http.StatusInternalServerError, http.StatusServiceUnavailable, "upstream_unavailable"},
+		{http.StatusServiceUnavailable, http.StatusServiceUnavailable, "upstream_unavailable"},
+		{http.StatusBadRequest, http.StatusInternalServerError, "upstream_rejected"},
+		{http.StatusConflict, http.StatusInternalServerError, "upstream_rejected"},
+	} {
+		// A fresh backend each time, so earlier failures don't open its circuit breaker
+		useOrderService(t, func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "no", tc.downstream)
+		})
+		resp, body := doRequest(t, newTestServer(t), http.MethodGet, "/users/user-001/orders", "")
+		if resp.StatusCode != tc.want {
+			t.Errorf("Order Service %d: status = %d, want %d: %s", tc.downstream, resp.StatusCode, tc.want, body)
+			continue
+		}
+		var errResp ErrorResponse
+		if err := json.Unmarshal([]byte(body), &errResp); err != nil {
+			t.Fatal(err)
+		}
+		if errResp.Code != tc.wantCode || len(errResp.Errors) != 1 || errResp.Errors[0].Status != tc.downstream {
+			t.Errorf("Order Service %d: body = %s, want %s with the downstream status in errors[]", tc.downstream, body, tc.wantCode)
+		}
+	}
+}
+
+func TestOrdersNotFoundReturnsEmptyOrders(t *testing.T) {
+	useTestStore(t)
+	var calls atomic.Int32
+	useOrderService(t, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		http.NotFound(w, r)
+	})
+	useOrdersCache(t, time.Minute)
+	server := newTestServer(t)
+
+	for i := 0; i < 2; i++ {
+		resp, body := doRequest(t, server, http.MethodGet, "/users/user-001/orders", "")
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("status = %d, want 200: %s", resp.StatusCode, body)
+		}
+		var got struct {
+			User     *User                  `json:"user"`
+			Orders   map[string]interface{} `json:"orders"`
+			Warnings []string               `json:"warnings"`
+		}
+		if err := json.Unmarshal([]byte(body), &got); err != nil {
+			t.Fatal(err)
+		}
+		if got.User == nil || got.Orders["count"] != 0.0 || len(got.Warnings) != 1 || got.Warnings[0] != noOrdersWarning {
+			t.Errorf("body = %s, want the user, no orders and a warning", body)
+		}
+	}
+	// The stand-in empty orders aren't cached, so the warning isn't lost
+	if got := calls.Load(); got != 2 {
+		t.Errorf("Order Service called %d times, want 2", got)
+	}
+}
+
+func TestOrdersUnreachableIsBadGateway(t *testing.T) {
+	useTestStore(t)
+	setForTest(t, &outboundMaxRetries, 0)
+	useOrderService(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Error("request reached the Order Service")
+	})
+	setForTest(t, &outboundClient, &http.Client{Transport: roundTripFunc(func(*http.Request) (*http.Response, error) {
+		return nil, &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+	})})
+
+	resp, body := doRequest(t, newTestServer(t), http.MethodGet, "/users/user-001/orders", "")
+	if resp.StatusCode != http.StatusBadGateway {
+		t.Errorf("status = %d, want 502 when the Order Service can't be reached: %s", resp.StatusCode, body)
+	}
+}
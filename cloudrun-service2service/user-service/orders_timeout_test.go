@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"testing"
+)
+
+// useTimingOutOrderService makes every Order Service call time out
+func useTimingOutOrderService(t *testing.T) {
+	t.Helper()
+	useOrderService(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Error("request reached the Order Service")
+	})
+	setForTest(t, &outboundMaxRetries, 0)
+	setForTest(t, &outboundClient, &http.Client{Transport: roundTripFunc(func(*http.Request) (*http.Response, error) {
+		return nil, &net.DNSError{Err: "i/o timeout", Name: "order-service", IsTimeout: true}
+	})})
+}
+
+func TestOrdersTimeoutReturnsUserWithWarning(t *testing.T) {
+	useTestStore(t)
+	useTimingOutOrderService(t)
+	setForTest(t, &ordersTimeoutPartial, true)
+	setForTest(t, &ordersTimeoutStatus, http.StatusOK)
+
+	resp, body := doRequest(t, newTestServer(t), http.MethodGet, "/users/user-001/orders", "")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200 with the user: %s", resp.StatusCode, body)
+	}
+	var partial struct {
+		User     *User           `json:"user"`
+		Orders   json.RawMessage `json:"orders"`
+		Warnings []string        `json:"warnings"`
+	}
+	if err := json.Unmarshal([]byte(body), &partial); err != nil {
+		t.Fatal(err)
+	}
+	if partial.User == nil || partial.User.ID != "user-001" || string(partial.Orders) != "null" || len(partial.Warnings) != 1 {
+		t.Errorf("body = %s, want the user, null orders and a warning", body)
+	}
+}
+
+func TestOrdersTimeoutStatus(t *testing.T) {
+	useTestStore(t)
+	useTimingOutOrderService(t)
+	setForTest(t, &ordersTimeoutPartial, true)
+	setForTest(t, &ordersTimeoutStatus, http.StatusGatewayTimeout)
+
+	resp, body := doRequest(t, newTestServer(t), http.MethodGet, "/users/user-001/orders", "")
+	if resp.StatusCode != http.StatusGatewayTimeout || !json.Valid([]byte(body)) {
+		t.Fatalf("status = %d, want 504 with the partial body: %s", resp.StatusCode, body)
+	}
+	var partial UserWithOrders
+	if err := json.Unmarshal([]byte(body), &partial); err != nil || partial.User == nil {
+		t.Errorf("body = %s, want the user", body)
+	}
+}
+
+func TestOrdersTimeoutWithoutPartial(t *testing.T) {
+	useTestStore(t)
+	useTimingOutOrderService(t)
+	setForTest(t, &ordersTimeoutPartial, false)
+
+	resp, body := doRequest(t, newTestServer(t), http.MethodGet, "/users/user-001/orders", "")
+	if resp.StatusCode != http.StatusGatewayTimeout {
+		t.Fatalf("status = %d, want 504: %s", resp.StatusCode, body)
+	}
+	var partial UserWithOrders
+	if err := json.Unmarshal([]byte(body), &partial); err != nil || partial.User != nil {
+		t.Errorf("body = %s, want no user with ORDERS_TIMEOUT_PARTIAL=false", body)
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// keyRecorder is a backend that records the Idempotency-Key of each request
+// and fails the first failFirst of them with 503
+type keyRecorder struct {
+	mu        sync.Mutex
+	keys      []string
+	failFirst int
+}
+
+func (k *keyRecorder) serve(w http.ResponseWriter, r *http.Request) {
+	k.mu.Lock()
+	k.keys = append(k.keys, r.Header.Get("Idempotency-Key"))
+	fail := len(k.keys) <= k.failFirst
+	k.mu.Unlock()
+	if fail {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}
+}
+
+func TestOutboundPOSTRetriesWithTheSameKey(t *testing.T) {
+	setForTest(t, &outboundIdempotencyKeys, true)
+	setForTest(t, &outboundMaxRetries, 2)
+	setForTest(t, &outboundRetryBaseDelay, time.Millisecond)
+	recorder := &keyRecorder{failFirst: 1}
+	backend := newBackend(t, recorder.serve)
+	useCachedIDToken(t, backend.URL)
+
+	status, _, err := makeAuthenticatedRequest(context.Background(), http.MethodPost, backend.URL+"/orders", strings.NewReader(`{"item":"Laptop"}`), nil)
+	if err != nil || status != http.StatusOK {
+		t.Fatalf("status = %d, err = %v; want the retry to succeed", status, err)
+	}
+	if len(recorder.keys) != 2 || recorder.keys[0] == "" || recorder.keys[0] != recorder.keys[1] {
+		t.Fatalf("keys = %v, want the same key on both attempts", recorder.keys)
+	}
+}
+
+func TestOutboundIdempotencyKeyIsStable(t *testing.T) {
+	ctx := context.WithValue(context.Background(), idempotencyKeyKey{}, "client-key")
+	body := []byte(`{"item":"Laptop"}`)
+
+	key := outboundIdempotencyKey(ctx, http.MethodPost, "https://orders.run.app/orders", body)
+	if again := outboundIdempotencyKey(ctx, http.MethodPost, "https://orders.run.app/orders", body); again != key {
+		t.Errorf("the client retrying the request got key %s, then %s", key, again)
+	}
+	if other := outboundIdempotencyKey(ctx, http.MethodPost, "https://orders.run.app/orders", []byte(`{"item":"Mouse"}`)); other == key {
+		t.Error("different calls for one request got the same key")
+	}
+	if other := outboundIdempotencyKey(context.WithValue(context.Background(), idempotencyKeyKey{}, "another-key"), http.MethodPost, "https://orders.run.app/orders", body); other == key {
+		t.Error("different client requests got the same key")
+	}
+}
+
+func TestOutboundIdempotencyKeysDisabled(t *testing.T) {
+	setForTest(t, &outboundIdempotencyKeys, false)
+	setForTest(t, &outboundMaxRetries, 2)
+	setForTest(t, &outboundRetryBaseDelay, time.Millisecond)
+	recorder := &keyRecorder{failFirst: 1}
+	backend := newBackend(t, recorder.serve)
+	useCachedIDToken(t, backend.URL)
+
+	// Without a key a POST isn't safe to retry
+	status, _, _ := makeAuthenticatedRequest(context.Background(), http.MethodPost, backend.URL+"/orders", strings.NewReader(`{}`), nil)
+	if status != http.StatusServiceUnavailable || len(recorder.keys) != 1 || recorder.keys[0] != "" {
+		t.Fatalf("status = %d, keys %q; want one unkeyed attempt", status, recorder.keys)
+	}
+}
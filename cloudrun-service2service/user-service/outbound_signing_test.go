@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestOutboundHMACSigning(t *testing.T) {
+	const secret = "shared-secret"
+	var mu sync.Mutex
+	var nonces []string
+	partner := newBackend(t, func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.Header.Get("Authorization") != "" {
+			t.Error("HMAC backend was sent an OIDC token")
+		}
+		nonce := r.Header.Get("X-Signature-Nonce")
+		want := "v1=" + computeHMACSignature(secret, r.Method, r.URL.RequestURI(), r.Header.Get("X-Signature-Timestamp"), nonce, body)
+		if !hmac.Equal([]byte(r.Header.Get("X-Signature")), []byte(want)) {
+			http.Error(w, "bad signature", http.StatusUnauthorized)
+			return
+		}
+		mu.Lock()
+		nonces = append(nonces, nonce)
+		mu.Unlock()
+	})
+	setForTest(t, &outboundAuthModes, map[string]string{partner.URL: authModeHMAC})
+	setForTest(t, &outboundHMACSecret, secret)
+
+	for i := 0; i < 2; i++ {
+		status, _, err := makeAuthenticatedRequest(context.Background(), http.MethodPost, partner.URL+"/events?source=users", strings.NewReader(`{"id":"user-001"}`), nil)
+		if err != nil || status != http.StatusOK {
+			t.Fatalf("status = %d, err = %v; want a verified signature", status, err)
+		}
+	}
+	if len(nonces) != 2 || nonces[0] == nonces[1] {
+		t.Errorf("nonces = %v, want a fresh one per request", nonces)
+	}
+}
+
+func TestSignRequestHMACNeedsSecret(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, "https://partner.example.com/", nil)
+	if err := signRequestHMAC(req, nil, ""); err == nil {
+		t.Fatal("signed without a secret")
+	}
+}
+
+func TestParseOutboundAuthModes(t *testing.T) {
+	modes := parseOutboundAuthModes("https://partner.example.com/=HMAC, https://orders.run.app=oidc,https://x=basic,broken")
+	if len(modes) != 2 || modes["https://partner.example.com"] != authModeHMAC || modes["https://orders.run.app"] != authModeOIDC {
+		t.Fatalf("modes = %v", modes)
+	}
+	if outboundAuthMode("https://unlisted.example.com") != authModeOIDC {
+		t.Error("unlisted backends should use OIDC")
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Field projection.
+// GET /users and GET /users/{id} accept ?fields=id,name to return only those
+// fields of each user. Any of User's JSON fields can be named, so new fields
+// are covered without changes here; unknown names get 400 fields_invalid.
+// Envelope fields (service, count, pagination, ...) are always returned.
+
+// userFieldNames are User's JSON field names in declaration order, the
+// order projected users are written in
+var userFieldNames = func() []string {
+	indexes := jsonFieldIndexes(reflect.TypeOf(User{}))
+	names := make([]string, 0, len(indexes))
+	for name := range indexes {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return indexes[names[i]] < indexes[names[j]] })
+	return names
+}()
+
+// userProjection is the set of User fields a response includes
+type userProjection struct {
+	fields map[string]bool
+}
+
+// MarshalJSON writes the user, or only its projected fields when it has a
+// projection
+func (u User) MarshalJSON() ([]byte, error) {
+	// userJSON has User's fields but not this method
+	type userJSON User
+	data, err := json.Marshal(userJSON(u))
+	if err != nil || u.projection == nil {
+		return data, err
+	}
+
+	var all map[string]json.RawMessage
+	if err := json.Unmarshal(data, &all); err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for _, name := range userFieldNames {
+		value, ok := all[name]
+		if !ok || !u.projection.fields[name] {
+			continue
+		}
+		if buf.Len() > 1 {
+			buf.WriteByte(',')
+		}
+		key, _ := json.Marshal(name)
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// parseFieldsParam reads ?fields= (comma-separated User field names). It
+// returns nil without one, and the first unknown name as invalid.
+func parseFieldsParam(r *http.Request) (projection *userProjection, invalid string) {
+	raw := strings.TrimSpace(r.URL.Query().Get("fields"))
+	if raw == "" {
+		return nil, ""
+	}
+	known := jsonFieldIndexes(reflect.TypeOf(User{}))
+	fields := make(map[string]bool)
+	for _, name := range strings.Split(raw, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if _, ok := known[name]; !ok {
+			return nil, name
+		}
+		fields[name] = true
+	}
+	if len(fields) == 0 {
+		return nil, ""
+	}
+	return &userProjection{fields: fields}, ""
+}
+
+// projectionWriter carries a request's field projection to writeJSON
+type projectionWriter struct {
+	http.ResponseWriter
+	projection *userProjection
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (pw *projectionWriter) Unwrap() http.ResponseWriter {
+	return pw.ResponseWriter
+}
+
+// Flush passes flushes through so NDJSON listings stay streamed
+func (pw *projectionWriter) Flush() {
+	if flusher, ok := pw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// withFieldProjection applies the request's ?fields= to users written
+// through the returned writer. An unknown field gets 400 and ok is false.
+func withFieldProjection(w http.ResponseWriter, r *http.Request) (http.ResponseWriter, bool) {
+	projection, invalid := parseFieldsParam(r)
+	if invalid != "" {
+		writeError(w, r, http.StatusBadRequest, "fields_invalid", invalid, strings.Join(userFieldNames, ", "))
+		return w, false
+	}
+	if projection == nil {
+		return w, true
+	}
+	return &projectionWriter{ResponseWriter: w, projection: projection}, true
+}
+
+// projectedUser returns user limited to the fields projected for the
+// request w responds to
+func projectedUser(w http.ResponseWriter, user User) User {
+	if pw := findResponseWriter[*projectionWriter](w); pw != nil {
+		user.projection = pw.projection
+	}
+	return user
+}
+
+// projectedBody returns data with its users limited to the fields projected
+// for the request w responds to
+func projectedBody(w http.ResponseWriter, data interface{}) interface{} {
+	if findResponseWriter[*projectionWriter](w) == nil {
+		return data
+	}
+	return mapResponseUsers(data, func(user User) User { return projectedUser(w, user) })
+}
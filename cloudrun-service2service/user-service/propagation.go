@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Context propagation configuration.
+// PROPAGATED_HEADERS lists incoming headers (e.g. "Accept-Language,X-Tenant-Id")
+// whose values are carried through the request and sent on every outbound
+// call, including proxied ones. Unset, only Accept-Language is propagated so
+// the Order Service can localize too; set it to "" to propagate nothing.
+// Values over PROPAGATED_HEADER_MAX_BYTES or containing control characters are dropped.
+var (
+	propagatedHeaders        = parsePropagatedHeaders(propagatedHeadersSetting())
+	propagatedHeaderMaxBytes = envInt("PROPAGATED_HEADER_MAX_BYTES", 256)
+)
+
+// defaultPropagatedHeaders is propagated when PROPAGATED_HEADERS isn't set
+const defaultPropagatedHeaders = "Accept-Language"
+
+// propagatedHeadersSetting returns PROPAGATED_HEADERS, or the default if it
+// isn't set at all (an empty value turns propagation off)
+func propagatedHeadersSetting() string {
+	if raw, ok := os.LookupEnv("PROPAGATED_HEADERS"); ok {
+		return raw
+	}
+	return defaultPropagatedHeaders
+}
+
+// headerNamePattern matches a valid HTTP header field name
+var headerNamePattern = regexp.MustCompile("^[A-Za-z0-9!#$%&'*+.^_`|~-]+$")
+
+// reservedHeaders are never propagated: they carry credentials, are set per
+// hop, or are already forwarded by other means
+var reservedHeaders = map[string]bool{
+	"Authorization":              true,
+	"Proxy-Authorization":        true,
+	"Cookie":                     true,
+	"Host":                       true,
+	"Content-Length":             true,
+	"Content-Type":               true,
+	"Connection":                 true,
+	"Keep-Alive":                 true,
+	"Proxy-Connection":           true,
+	"Te":                         true,
+	"Trailer":                    true,
+	"Transfer-Encoding":          true,
+	"Upgrade":                    true,
+	"X-Admin-Token":              true,
+	"X-Serverless-Authorization": true,
+	"X-Cloud-Trace-Context":      true,
+	"Traceparent":                true,
+	"Tracestate":                 true,
+}
+
+// propagatedValuesKey is the context key for the values to send downstream
+type propagatedValuesKey struct{}
+
+// withPropagatedValues is a middleware that captures the configured headers
+// from the incoming request so makeAuthenticatedRequest can forward them
+func withPropagatedValues(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(propagatedHeaders) == 0 {
+			handler.ServeHTTP(w, r)
+			return
+		}
+
+		// Headers the client's Connection header names are hop-by-hop too
+		hopByHop := make(map[string]bool)
+		for _, field := range r.Header.Values("Connection") {
+			for _, name := range strings.Split(field, ",") {
+				hopByHop[http.CanonicalHeaderKey(strings.TrimSpace(name))] = true
+			}
+		}
+
+		values := make(http.Header)
+		for _, name := range propagatedHeaders {
+			value := r.Header.Get(name)
+			if value == "" || hopByHop[name] {
+				continue
+			}
+			if !validPropagatedValue(value) {
+				logger.DebugContext(r.Context(), "Not propagating invalid header value", "header", name)
+				continue
+			}
+			values.Set(name, value)
+		}
+		if len(values) > 0 {
+			r = r.WithContext(context.WithValue(r.Context(), propagatedValuesKey{}, values))
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// validPropagatedValue reports whether value is small enough and free of control characters
+func validPropagatedValue(value string) bool {
+	if len(value) > propagatedHeaderMaxBytes {
+		return false
+	}
+	for i := 0; i < len(value); i++ {
+		if c := value[i]; c < 0x20 || c == 0x7f {
+			return false
+		}
+	}
+	return true
+}
+
+// applyPropagatedValues sets the propagated values stored in ctx on an outbound request
+func applyPropagatedValues(ctx context.Context, req *http.Request) {
+	values, _ := ctx.Value(propagatedValuesKey{}).(http.Header)
+	for name, v := range values {
+		req.Header[name] = v
+	}
+}
+
+// parsePropagatedHeaders parses a comma-separated list of header names,
+// ignoring invalid and reserved ones
+func parsePropagatedHeaders(raw string) []string {
+	var names []string
+	for _, name := range strings.Split(raw, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if !headerNamePattern.MatchString(name) {
+			logger.Warn("Ignoring invalid propagated header name", "value", name)
+			continue
+		}
+		name = http.CanonicalHeaderKey(name)
+		if reservedHeaders[name] {
+			logger.Warn("Ignoring reserved header in PROPAGATED_HEADERS", "value", name)
+			continue
+		}
+		names = append(names, name)
+	}
+	return names
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"testing"
+)
+
+func TestPropagatedHeadersDefault(t *testing.T) {
+	t.Setenv("PROPAGATED_HEADERS", "")
+	if got := propagatedHeadersSetting(); got != "" {
+		t.Errorf("PROPAGATED_HEADERS set empty: setting = %q, want propagation off", got)
+	}
+
+	os.Unsetenv("PROPAGATED_HEADERS")
+	names := parsePropagatedHeaders(propagatedHeadersSetting())
+	if len(names) != 1 || names[0] != "Accept-Language" {
+		t.Errorf("PROPAGATED_HEADERS unset: names = %v, want [Accept-Language]", names)
+	}
+}
+
+func TestParsePropagatedHeadersSkipsHopByHop(t *testing.T) {
+	names := parsePropagatedHeaders("Connection, keep-alive, Proxy-Authorization, Proxy-Connection, TE, Trailer, Transfer-Encoding, Upgrade, X-Tenant-Id")
+	if len(names) != 1 || names[0] != "X-Tenant-Id" {
+		t.Fatalf("names = %v, want only X-Tenant-Id", names)
+	}
+}
+
+func TestConnectionNamedHeadersNotPropagated(t *testing.T) {
+	useTestStore(t)
+	setForTest(t, &propagatedHeaders, parsePropagatedHeaders("Accept-Language, X-Tenant-Id"))
+	received := make(chan http.Header, 1)
+	useOrderService(t, func(w http.ResponseWriter, r *http.Request) {
+		received <- r.Header.Clone()
+		w.Write([]byte(testOrdersJSON("user-001")))
+	})
+
+	resp, body := doRequest(t, newTestServer(t), http.MethodGet, "/users/user-001/orders", "",
+		"Connection", "keep-alive, x-tenant-id",
+		"X-Tenant-Id", "acme",
+		"Accept-Language", "fr")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", resp.StatusCode, body)
+	}
+	headers := <-received
+	if got := headers.Get("X-Tenant-Id"); got != "" {
+		t.Errorf("X-Tenant-Id named in Connection was propagated: %q", got)
+	}
+	if got := headers.Get("Accept-Language"); got != "fr" {
+		t.Errorf("Accept-Language = %q, want fr", got)
+	}
+}
+
+func TestPropagatedHeadersReachProxiedRequests(t *testing.T) {
+	setForTest(t, &allowOrdersProxy, true)
+	setForTest(t, &propagatedHeaders, parsePropagatedHeaders(defaultPropagatedHeaders))
+	received := make(chan http.Header, 1)
+	useOrderService(t, func(w http.ResponseWriter, r *http.Request) {
+		received <- r.Header.Clone()
+		w.Write([]byte(testOrdersJSON("user-001")))
+	})
+
+	resp, body := doRequest(t, newTestServer(t), http.MethodGet, "/proxy/orders/user/user-001", "", "Accept-Language", "es")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", resp.StatusCode, body)
+	}
+	if got := (<-received).Get("Accept-Language"); got != "es" {
+		t.Errorf("proxied Accept-Language = %q, want es", got)
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestPropagatedHeadersReachBackend(t *testing.T) {
+	useTestStore(t)
+	setForTest(t, &propagatedHeaders, parsePropagatedHeaders("x-tenant-id, X-Experiment, Accept-Language"))
+	received := make(chan http.Header, 1)
+	useOrderService(t, func(w http.ResponseWriter, r *http.Request) {
+		received <- r.Header.Clone()
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(testOrdersJSON("user-001")))
+	})
+	server := newTestServer(t)
+
+	resp, body := doRequest(t, server, http.MethodGet, "/users/user-001/orders", "",
+		"X-Tenant-Id", "acme",
+		"X-Experiment", strings.Repeat("x", propagatedHeaderMaxBytes+1),
+		"Accept-Language", "de",
+		"X-Unlisted", "secret")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", resp.StatusCode, body)
+	}
+
+	headers := <-received
+	if got := headers.Get("X-Tenant-Id"); got != "acme" {
+		t.Errorf("X-Tenant-Id = %q, want acme", got)
+	}
+	if got := headers.Get("Accept-Language"); got != "de" {
+		t.Errorf("Accept-Language = %q, want de", got)
+	}
+	if got := headers.Get("X-Experiment"); got != "" {
+		t.Errorf("oversized X-Experiment was propagated (%d bytes)", len(got))
+	}
+	if got := headers.Get("X-Unlisted"); got != "" {
+		t.Errorf("unlisted X-Unlisted was propagated: %q", got)
+	}
+}
+
+func TestParsePropagatedHeadersSkipsReserved(t *testing.T) {
+	names := parsePropagatedHeaders("authorization, x-tenant-id, bad header, Cookie, traceparent, x-admin-token")
+	if len(names) != 1 || names[0] != "X-Tenant-Id" {
+		t.Fatalf("names = %v, want only X-Tenant-Id", names)
+	}
+}
+
+func TestValidPropagatedValue(t *testing.T) {
+	for value, want := range map[string]bool{
+		"acme":             true,
+		"line\r\nbreak":    false,
+		"tab\tinside":      false,
+		"del\x7fcharacter": false,
+		strings.Repeat("a", propagatedHeaderMaxBytes): true,
+	} {
+		if got := validPropagatedValue(value); got != want {
+			t.Errorf("validPropagatedValue(%q) = %v, want %v", value, got, want)
+		}
+	}
+}
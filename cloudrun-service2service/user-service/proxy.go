@@ -0,0 +1,216 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// proxyForwardedHeaders are the client headers an AuthenticatedProxy passes
+// on. Everything else - in particular the client's own Authorization - is
+// dropped; the proxy attaches its own credentials.
+var proxyForwardedHeaders = []string{
+	"Accept",
+	"Accept-Language",
+	"Content-Type",
+	"Idempotency-Key",
+	"If-Match",
+	"If-None-Match",
+}
+
+// AuthenticatedProxy forwards requests under a path prefix to an internal
+// service, authenticated the same way as the service's own outbound calls
+// (an OIDC ID token, or HMAC for hmac backends), and streams the response
+// back. Calls go through the backend's circuit breaker, concurrency cap,
+// metrics and tracing. They aren't retried, since the request body is
+// forwarded as-is and the response is streamed.
+type AuthenticatedProxy struct {
+	// Name identifies the target in logs and upstream errors
+	Name string
+	// Prefix is stripped from the incoming path, so with Prefix "/proxy"
+	// a request for /proxy/orders/123 is sent to {target}/orders/123
+	Prefix string
+	// Target returns the base URL to forward to ("" = not configured). It's
+	// called per request so reloaded config takes effect.
+	Target func() string
+
+	proxy *httputil.ReverseProxy
+}
+
+// NewAuthenticatedProxy returns a proxy forwarding requests under prefix to target()
+func NewAuthenticatedProxy(name, prefix string, target func() string) *AuthenticatedProxy {
+	p := &AuthenticatedProxy{Name: name, Prefix: prefix, Target: target}
+	p.proxy = &httputil.ReverseProxy{
+		Rewrite:      p.rewrite,
+		Transport:    proxyTransport{},
+		ErrorHandler: p.writeProxyError,
+		// Flush as the backend writes so streamed responses stay streamed
+		FlushInterval: -1,
+	}
+	return p
+}
+
+// orderServiceProxy serves /proxy/orders/..., forwarding to the Order Service's /orders/...
+var orderServiceProxy = NewAuthenticatedProxy("order-service", "/proxy", getOrderServiceURL)
+
+// ALLOW_ORDERS_PROXY enables /proxy/orders. It's off by default: the proxy
+// lends this service's identity to its callers, so any method on any Order
+// Service path is open to whoever can reach it unless ENFORCE_ROLES is on.
+var allowOrdersProxy = envBool("ALLOW_ORDERS_PROXY", false)
+
+// proxyTargetKey is the context key for the base URL a proxied request goes to
+type proxyTargetKey struct{}
+
+func (p *AuthenticatedProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	target := strings.TrimRight(p.Target(), "/")
+	if target == "" {
+		writeErrorResponse(w, r, http.StatusServiceUnavailable, newErrorResponse("not_configured", fmt.Sprintf("%s is not configured - cannot proxy", p.Name)))
+		return
+	}
+	targetURL, err := url.Parse(target)
+	if err != nil {
+		logger.ErrorContext(r.Context(), "Invalid proxy target", "name", p.Name, "target", target, "error", err)
+		writeErrorResponse(w, r, http.StatusInternalServerError, newErrorResponse("internal_error", fmt.Sprintf("%s URL is invalid", p.Name)))
+		return
+	}
+	p.proxy.ServeHTTP(w, r.WithContext(contextWithProxyTarget(r.Context(), targetURL)))
+}
+
+// rewrite builds the outbound request: the target's scheme and host, the
+// incoming path without Prefix, and only proxyForwardedHeaders
+func (p *AuthenticatedProxy) rewrite(pr *httputil.ProxyRequest) {
+	target := proxyTargetFromContext(pr.In.Context())
+	pr.Out.URL.Scheme = target.Scheme
+	pr.Out.URL.Host = target.Host
+	pr.Out.URL.Path = strings.TrimRight(target.Path, "/") + strings.TrimPrefix(pr.In.URL.Path, p.Prefix)
+	pr.Out.URL.RawPath = ""
+	pr.Out.URL.RawQuery = pr.In.URL.RawQuery
+	pr.Out.Host = target.Host
+
+	pr.Out.Header = make(http.Header)
+	for _, name := range proxyForwardedHeaders {
+		if values := pr.In.Header.Values(name); len(values) > 0 {
+			pr.Out.Header[http.CanonicalHeaderKey(name)] = values
+		}
+	}
+}
+
+// writeProxyError responds to a proxied call that got no response, with the
+// same statuses the orders endpoint uses for Order Service failures
+func (p *AuthenticatedProxy) writeProxyError(w http.ResponseWriter, r *http.Request, err error) {
+	logger.ErrorContext(r.Context(), "Error proxying request", "name", p.Name, "path", r.URL.Path, "error", err)
+	var openErr *circuitOpenError
+	switch {
+	case errors.As(err, &openErr):
+		w.Header().Set("Retry-After", strconv.Itoa(int(openErr.RetryAfter.Round(time.Second)/time.Second)+1))
+		writeErrorResponse(w, r, http.StatusServiceUnavailable, upstreamFailure(p.Name, fmt.Sprintf("%s is unavailable (circuit open) - try again shortly", p.Name), err))
+	case errors.Is(err, errBackendBusy):
+		writeErrorResponse(w, r, http.StatusServiceUnavailable, upstreamFailure(p.Name, fmt.Sprintf("%s is at its concurrency limit - try again shortly", p.Name), err))
+	case clientDeadlineExceeded(r.Context()):
+		writeClientDeadlineExceeded(w, r)
+	case isTimeout(err):
+		writeErrorResponse(w, r, http.StatusGatewayTimeout, upstreamFailure(p.Name, fmt.Sprintf("%s timed out", p.Name), err))
+	default:
+		writeErrorResponse(w, r, http.StatusBadGateway, upstreamFailure(p.Name, clientErrorMessage(fmt.Sprintf("Failed to reach %s", p.Name), err), err))
+	}
+}
+
+// proxyTransport sends proxied requests through the backend's circuit
+// breaker and concurrency cap, with credentials attached
+type proxyTransport struct{}
+
+func (proxyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	backend := req.URL.Scheme + "://" + req.URL.Host
+
+	// HMAC signatures cover the body, so it's read up front (the inbound body
+	// is already capped by limitRequestBody)
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read request body: %w", err)
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		req.ContentLength = int64(len(body))
+	}
+
+	ctx, endSpan := startOutboundSpan(ctx, req.Method, req.URL.String(), backend)
+	req = req.WithContext(ctx)
+
+	done, err := outboundBreakers.allow(backend)
+	if err != nil {
+		endSpan(err)
+		return nil, err
+	}
+	release, err := outboundLimiter.acquire(ctx, backend)
+	if err != nil {
+		done(breakerResultFor(ctx, err))
+		endSpan(err)
+		return nil, err
+	}
+
+	resp, err := func() (*http.Response, error) {
+		if err := authorizeOutboundRequest(ctx, req, backend, body); err != nil {
+			return nil, err
+		}
+		resp, err := outboundClient.Transport.RoundTrip(req)
+		if err != nil {
+			return nil, fmt.Errorf("request failed: %w", err)
+		}
+		return resp, nil
+	}()
+
+	// 5xx counts against the backend like any other outbound call; the body
+	// itself is still passed through to the client
+	result := err
+	if err == nil && resp.StatusCode >= 500 {
+		result = &statusError{StatusCode: resp.StatusCode}
+	}
+	observeOutbound(backend, result)
+	done(breakerResultFor(ctx, result))
+	endSpan(result)
+	if err != nil {
+		release()
+		return nil, err
+	}
+
+	// The call holds its concurrency slot until the response is streamed
+	resp.Body = &releasingBody{ReadCloser: resp.Body, release: release}
+	return resp, nil
+}
+
+// releasingBody calls release once the response body is closed
+type releasingBody struct {
+	io.ReadCloser
+	release func()
+	once    sync.Once
+}
+
+func (b *releasingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
+
+// contextWithProxyTarget and proxyTargetFromContext carry a proxied request's
+// target from ServeHTTP to rewrite
+func contextWithProxyTarget(ctx context.Context, target *url.URL) context.Context {
+	return context.WithValue(ctx, proxyTargetKey{}, target)
+}
+
+func proxyTargetFromContext(ctx context.Context) *url.URL {
+	target, _ := ctx.Value(proxyTargetKey{}).(*url.URL)
+	return target
+}
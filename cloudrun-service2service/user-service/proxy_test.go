@@ -0,0 +1,37 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestOrdersProxyDisabledByDefault(t *testing.T) {
+	setForTest(t, &allowOrdersProxy, false)
+	useOrderService(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("%s %s reached the Order Service", r.Method, r.URL.Path)
+	})
+	server := newTestServer(t)
+
+	for _, path := range []string{"/proxy/orders", "/proxy/orders/user/user-001"} {
+		if resp, body := doRequest(t, server, http.MethodGet, path, ""); resp.StatusCode != http.StatusNotFound {
+			t.Errorf("GET %s: status = %d, want 404: %s", path, resp.StatusCode, body)
+		}
+	}
+}
+
+func TestOrdersProxyForwardsWhenEnabled(t *testing.T) {
+	setForTest(t, &allowOrdersProxy, true)
+	received := make(chan string, 1)
+	useOrderService(t, func(w http.ResponseWriter, r *http.Request) {
+		received <- r.URL.Path
+		w.Write([]byte(testOrdersJSON("user-001")))
+	})
+
+	resp, body := doRequest(t, newTestServer(t), http.MethodGet, "/proxy/orders/user/user-001", "")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", resp.StatusCode, body)
+	}
+	if got := <-received; got != "/orders/user/user-001" {
+		t.Errorf("Order Service path = %q, want /orders/user/user-001", got)
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// READINESS_CACHE_TTL controls how long a dependency check result is reused,
+// so frequent probes don't turn into a steady stream of calls to the Order Service
+var readinessCacheTTL = envDuration("READINESS_CACHE_TTL", 10*time.Second)
+
+// ReadinessResponse represents the response for GET /readyz
+type ReadinessResponse struct {
+	Service      string          `json:"service"`
+	Status       string          `json:"status"`
+	CheckedAt    time.Time       `json:"checked_at"`
+	Cached       bool            `json:"cached"`
+	Dependencies []ServiceHealth `json:"dependencies"`
+	// DrainingSince is set once POST /admin/drain or SIGTERM started draining
+	DrainingSince *time.Time `json:"draining_since,omitempty"`
+	ErrorRate     ErrorRate  `json:"error_rate"`
+
+	// CircuitBreakers is each backend's circuit state (closed, open or half_open)
+	CircuitBreakers map[string]string `json:"circuit_breakers,omitempty"`
+}
+
+// readinessCache holds the last readiness result for readinessCacheTTL
+var readinessCache struct {
+	sync.Mutex
+	response *ReadinessResponse
+}
+
+// readyzHandler handles GET /readyz. Unlike /health (a cheap liveness check),
+// it returns 503 when a required dependency is unreachable or the recent
+// error rate is above ERROR_RATE_THRESHOLD, and once the instance is draining.
+func readyzHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		methodNotAllowed(w, r, http.MethodGet, http.MethodHead)
+		return
+	}
+
+	// A draining instance is leaving rotation whatever its dependencies say
+	if since := drainingSince(); since != nil {
+		writeHealth(w, http.StatusServiceUnavailable, ReadinessResponse{
+			Service:         "user-service (Go)",
+			Status:          "draining",
+			CheckedAt:       time.Now().UTC(),
+			Dependencies:    []ServiceHealth{},
+			DrainingSince:   since,
+			ErrorRate:       requestOutcomes.rate(time.Now()),
+			CircuitBreakers: outboundBreakers.states(),
+		})
+		return
+	}
+
+	readinessCache.Lock()
+	cached := readinessCache.response
+	readinessCache.Unlock()
+
+	var response ReadinessResponse
+	if cached != nil && time.Since(cached.CheckedAt) < readinessCacheTTL {
+		response = *cached
+		response.Cached = true
+	} else {
+		response = checkReadiness(r.Context())
+
+		fresh := response
+		readinessCache.Lock()
+		readinessCache.response = &fresh
+		readinessCache.Unlock()
+	}
+
+	// The error rate and circuit states move with every request, so they're never served from the cache
+	response.ErrorRate = requestOutcomes.rate(time.Now())
+	response.CircuitBreakers = outboundBreakers.states()
+	if response.ErrorRate.Exceeded {
+		response.Status = "not_ready"
+	}
+
+	status := http.StatusOK
+	if response.Status != "ready" {
+		status = http.StatusServiceUnavailable
+	}
+	writeHealth(w, status, response)
+}
+
+// checkReadiness checks the dependencies this service needs to serve traffic.
+// Only the Order Service is required; it's skipped when not configured.
+func checkReadiness(ctx context.Context) ReadinessResponse {
+	response := ReadinessResponse{
+		Service:      "user-service (Go)",
+		Status:       "ready",
+		Dependencies: []ServiceHealth{},
+	}
+
+	if orderURL := getOrderServiceURL(); orderURL != "" {
+		health, _ := checkServiceHealth(ctx, meshDependency{Name: "order-service", URL: orderURL})
+		if health.Status != "healthy" {
+			response.Status = "not_ready"
+		}
+		response.Dependencies = append(response.Dependencies, health)
+	}
+
+	response.CheckedAt = time.Now().UTC()
+	return response
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"runtime/debug"
+)
+
+// recoverPanics turns a handler panic into a logged stack trace and a 500
+// ErrorResponse, instead of net/http's unstructured stack dump and dropped
+// connection. It's at the top of serverMiddleware so panics in any
+// middleware are caught too. Panics in goroutines a handler starts aren't:
+// those still crash the process.
+//
+// If the response had already started, a 500 can't be sent any more, so the
+// connection is aborted rather than leaving the client with a truncated body
+// that looks complete. http.ErrAbortHandler is a deliberate abort and is
+// passed on as is.
+func recoverPanics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		recorder := &statusRecorder{ResponseWriter: w}
+		defer func() {
+			recovered := recover()
+			if recovered == nil {
+				return
+			}
+			if recovered == http.ErrAbortHandler {
+				panic(recovered)
+			}
+
+			// logRequest, further in, has echoed the request ID in the
+			// response headers but its context is gone with the panic
+			ctx := r.Context()
+			if requestID := w.Header().Get("X-Request-Id"); requestID != "" {
+				ctx = withRequestID(ctx, requestID)
+			}
+			logger.ErrorContext(ctx, "Recovered from handler panic",
+				"method", r.Method,
+				"path", r.URL.Path,
+				"panic", fmt.Sprint(recovered),
+				"stack", string(debug.Stack()),
+				"response_started", recorder.status != 0,
+			)
+			if recorder.status != 0 {
+				panic(http.ErrAbortHandler)
+			}
+			writeErrorResponse(w, r.WithContext(ctx), http.StatusInternalServerError, newErrorResponse("internal_error", "Internal server error"))
+		}()
+		next.ServeHTTP(recorder, r)
+	})
+}
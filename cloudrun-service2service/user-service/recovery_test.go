@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newPanickingServer serves handler at /panic behind the server middleware
+func newPanickingServer(t *testing.T, handler http.HandlerFunc) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/panic", handler)
+	server := httptest.NewServer(Chain(serverMiddleware...)(mux))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestPanicRecoveredAs500(t *testing.T) {
+	logs := captureLogs(t, slog.LevelInfo)
+	server := newPanickingServer(t, func(w http.ResponseWriter, r *http.Request) {
+		var users map[string]User
+		users["boom"] = User{}
+	})
+
+	resp, body := doRequest(t, server, http.MethodGet, "/panic", "")
+	if resp.StatusCode != http.StatusInternalServerError {
+		t.Fatalf("status = %d, want 500: %s", resp.StatusCode, body)
+	}
+	var errResp ErrorResponse
+	if err := json.Unmarshal([]byte(body), &errResp); err != nil || errResp.Code != "internal_error" {
+		t.Fatalf("body = %s, want an internal_error ErrorResponse", body)
+	}
+	// The panic itself stays in the logs, not the response
+	if strings.Contains(body, "nil map") {
+		t.Errorf("the panic leaked into the response: %s", body)
+	}
+
+	requestID := resp.Header.Get("X-Request-Id")
+	for _, want := range []string{"Recovered from handler panic", "assignment to entry in nil map", `"stack":"goroutine`, `"request_id":"` + requestID + `"`} {
+		if !strings.Contains(logs.String(), want) {
+			t.Errorf("logs don't contain %s:\n%s", want, logs)
+		}
+	}
+}
+
+func TestPanicAfterResponseStartedAborts(t *testing.T) {
+	captureLogs(t, slog.LevelInfo)
+	server := newPanickingServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"users":[`))
+		w.(http.Flusher).Flush()
+		panic("halfway through")
+	})
+
+	resp, err := http.Get(server.URL + "/panic")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	// The client sees the body cut off, not a complete-looking response
+	if _, err := io.ReadAll(resp.Body); err == nil {
+		t.Error("read the whole body, want the connection aborted")
+	}
+}
+
+func TestAbortHandlerPassedThrough(t *testing.T) {
+	handler := recoverPanics(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+	defer func() {
+		if recovered := recover(); recovered != http.ErrAbortHandler {
+			t.Errorf("recovered %v, want http.ErrAbortHandler re-raised", recovered)
+		}
+	}()
+	serveHandler(handler.ServeHTTP, http.MethodGet, "/", "")
+}
+
+func TestMiddlewarePanicRecovered(t *testing.T) {
+	captureLogs(t, slog.LevelInfo)
+	panicking := func(http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { panic("middleware bug") })
+	}
+	handler := Chain(recoverPanics, panicking)(http.NotFoundHandler())
+
+	rec := serveHandler(handler.ServeHTTP, http.MethodGet, "/", "")
+	if rec.Code != http.StatusInternalServerError || !strings.Contains(rec.Body.String(), `"internal_error"`) {
+		t.Errorf("status = %d, want 500 internal_error: %s", rec.Code, rec.Body)
+	}
+}
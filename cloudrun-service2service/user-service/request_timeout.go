@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Clients can cap how long a request may take with an X-Request-Timeout
+// header (a Go duration such as "2s" or "500ms"). The deadline covers the
+// store and any Order Service calls made for the request, and a request that
+// runs out of time gets 504. It can only shorten the route's own Timeout,
+// which stays the server-side maximum.
+const requestTimeoutHeader = "X-Request-Timeout"
+
+// clientDeadlineKey is the context key marking a deadline set by X-Request-Timeout
+type clientDeadlineKey struct{}
+
+// parseRequestTimeout reads X-Request-Timeout. It returns 0 if the header is
+// absent and ok=false if it isn't a positive duration.
+func parseRequestTimeout(r *http.Request) (time.Duration, bool) {
+	raw := strings.TrimSpace(r.Header.Get(requestTimeoutHeader))
+	if raw == "" {
+		return 0, true
+	}
+	timeout, err := time.ParseDuration(raw)
+	if err != nil || timeout <= 0 {
+		return 0, false
+	}
+	return timeout, true
+}
+
+// withClientDeadline returns ctx with the client's deadline if it's sooner
+// than routeTimeout (0 = the route has none), marked so timeouts can be
+// reported as 504 rather than degraded. Otherwise ctx has routeTimeout.
+func withClientDeadline(ctx context.Context, clientTimeout, routeTimeout time.Duration) (context.Context, context.CancelFunc) {
+	if clientTimeout > 0 && (routeTimeout <= 0 || clientTimeout < routeTimeout) {
+		ctx = context.WithValue(ctx, clientDeadlineKey{}, true)
+		return context.WithTimeout(ctx, clientTimeout)
+	}
+	if routeTimeout > 0 {
+		return context.WithTimeout(ctx, routeTimeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+// clientDeadlineExceeded reports whether ctx's X-Request-Timeout deadline has passed
+func clientDeadlineExceeded(ctx context.Context) bool {
+	marked, _ := ctx.Value(clientDeadlineKey{}).(bool)
+	return marked && ctx.Err() == context.DeadlineExceeded
+}
+
+// writeClientDeadlineExceeded writes the 504 for a request that ran past its
+// X-Request-Timeout
+func writeClientDeadlineExceeded(w http.ResponseWriter, r *http.Request) {
+	writeError(w, r, http.StatusGatewayTimeout, "request_timeout_exceeded", r.Header.Get(requestTimeoutHeader))
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRequestTimeoutExceeded(t *testing.T) {
+	useTestStore(t)
+	useOrderService(t, func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	})
+	// Even where an orders timeout would return the user with a warning
+	setForTest(t, &ordersTimeoutPartial, true)
+	setForTest(t, &ordersTimeoutStatus, http.StatusOK)
+
+	start := time.Now()
+	resp, body := doRequest(t, newTestServer(t), http.MethodGet, "/users/user-001/orders", "", requestTimeoutHeader, "100ms")
+	if resp.StatusCode != http.StatusGatewayTimeout || !strings.Contains(body, `"request_timeout_exceeded"`) {
+		t.Fatalf("status = %d, want 504 request_timeout_exceeded: %s", resp.StatusCode, body)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("request took %v, want it cut off near its 100ms deadline", elapsed)
+	}
+}
+
+func TestRequestTimeoutNotReached(t *testing.T) {
+	useTestStore(t)
+	useOrderService(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(testOrdersJSON("user-001")))
+	})
+
+	resp, body := doRequest(t, newTestServer(t), http.MethodGet, "/users/user-001/orders", "", requestTimeoutHeader, "5s")
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("status = %d, want 200: %s", resp.StatusCode, body)
+	}
+}
+
+func TestRequestTimeoutInvalid(t *testing.T) {
+	useTestStore(t)
+	server := newTestServer(t)
+
+	for _, value := range []string{"soon", "0s", "-1s", "5"} {
+		resp, body := doRequest(t, server, http.MethodGet, "/users/user-001", "", requestTimeoutHeader, value)
+		if resp.StatusCode != http.StatusBadRequest || !strings.Contains(body, `"invalid_request_timeout"`) {
+			t.Errorf("%s = %q: status = %d, want 400 invalid_request_timeout: %s", requestTimeoutHeader, value, resp.StatusCode, body)
+		}
+	}
+}
+
+func TestWithClientDeadline(t *testing.T) {
+	tests := []struct {
+		name                        string
+		clientTimeout, routeTimeout time.Duration
+		wantDeadline                time.Duration // 0 = none
+		wantClient                  bool
+	}{
+		{"client sooner", time.Second, time.Minute, time.Second, true},
+		{"no route timeout", time.Second, 0, time.Second, true},
+		{"clamped to route", time.Hour, time.Minute, time.Minute, false},
+		{"no client timeout", 0, time.Minute, time.Minute, false},
+		{"neither", 0, 0, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := time.Now()
+			ctx, cancel := withClientDeadline(context.Background(), tt.clientTimeout, tt.routeTimeout)
+			defer cancel()
+
+			deadline, ok := ctx.Deadline()
+			if ok != (tt.wantDeadline > 0) {
+				t.Fatalf("has deadline = %v, want %v", ok, tt.wantDeadline > 0)
+			}
+			if ok {
+				if got := deadline.Sub(start); got < tt.wantDeadline || got > tt.wantDeadline+time.Second/10 {
+					t.Errorf("deadline in %v, want %v", got, tt.wantDeadline)
+				}
+			}
+			marked, _ := ctx.Value(clientDeadlineKey{}).(bool)
+			if marked != tt.wantClient {
+				t.Errorf("marked as the client's deadline = %v, want %v", marked, tt.wantClient)
+			}
+		})
+	}
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// errorVerbosity controls how much upstream error detail reaches clients
+// (ERROR_VERBOSITY). In "production" (the default) clients get a generic
+// message and the detail is only logged; "debug" includes the detail.
+var errorVerbosity = strings.ToLower(envString("ERROR_VERBOSITY", "production"))
+
+// clientErrorMessage returns the message to show a client for err. The full
+// error is always logged by the caller; it's only echoed back in debug mode
+// because upstream bodies can leak internal details.
+func clientErrorMessage(generic string, err error) string {
+	if errorVerbosity == "debug" && err != nil {
+		return generic + ": " + err.Error()
+	}
+	return generic
+}
+
+// JSON response buffering configuration.
+// json.Encoder streams straight to the client, so responses go out chunked
+// without a Content-Length. BUFFER_JSON_RESPONSES=true encodes into memory
+// first so an accurate Content-Length can be set. Bodies larger than
+// JSON_BUFFER_MAX_BYTES fall back to streaming to keep memory bounded.
+var (
+	bufferJSONResponses = envBool("BUFFER_JSON_RESPONSES", false)
+	jsonBufferMaxBytes  = envInt("JSON_BUFFER_MAX_BYTES", 1<<20)
+)
+
+// spillWriter buffers a response body up to max bytes and switches to
+// streaming (without Content-Length) once the body grows past that
+type spillWriter struct {
+	w         http.ResponseWriter
+	status    int
+	max       int
+	buf       bytes.Buffer
+	streaming bool
+}
+
+func (s *spillWriter) Write(p []byte) (int, error) {
+	if s.streaming {
+		return s.w.Write(p)
+	}
+	if s.buf.Len()+len(p) <= s.max {
+		return s.buf.Write(p)
+	}
+
+	// Too large to buffer: send what we have and stream the rest
+	s.streaming = true
+	s.w.WriteHeader(s.status)
+	if _, err := s.w.Write(s.buf.Bytes()); err != nil {
+		return 0, err
+	}
+	s.buf.Reset()
+	return s.w.Write(p)
+}
+
+// finish writes the buffered body with an accurate Content-Length
+func (s *spillWriter) finish() {
+	if s.streaming {
+		return
+	}
+	s.w.Header().Set("Content-Length", strconv.Itoa(s.buf.Len()))
+	s.w.WriteHeader(s.status)
+	if _, err := s.w.Write(s.buf.Bytes()); err != nil {
+		logger.Error("Error writing JSON response", "error", err)
+	}
+}
+
+// writeBufferedJSON encodes data into memory and writes it with a Content-Length header
+func writeBufferedJSON(w http.ResponseWriter, status int, data interface{}) {
+	sw := &spillWriter{w: w, status: status, max: jsonBufferMaxBytes}
+	if err := json.NewEncoder(sw).Encode(data); err != nil {
+		logger.Error("Error encoding JSON response", "error", err)
+	}
+	sw.finish()
+}
+
+// Response size configuration.
+// MAX_RESPONSE_BYTES caps list and orders responses (0 = unlimited). When a
+// list page would be too large, RESPONSE_SIZE_MODE decides what happens:
+// "error" (default) returns 413, "paginate" shrinks the page until it fits
+// and points the client at the next page.
+var (
+	maxResponseBytes = envInt("MAX_RESPONSE_BYTES", 0)
+	responseSizeMode = strings.ToLower(envString("RESPONSE_SIZE_MODE", "error"))
+)
+
+// encodeWithinLimit encodes data as JSON and reports whether it fits within
+// MAX_RESPONSE_BYTES. The encoded bytes are returned so they aren't encoded twice.
+func encodeWithinLimit(data interface{}) ([]byte, bool, error) {
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(data); err != nil {
+		return nil, false, err
+	}
+	return buf.Bytes(), maxResponseBytes <= 0 || buf.Len() <= maxResponseBytes, nil
+}
+
+// writeEncodedJSON writes an already-encoded JSON body, as application/json
+// unless a Content-Type has been set
+func writeEncodedJSON(w http.ResponseWriter, status int, body []byte) {
+	if w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", "application/json")
+	}
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(status)
+	if _, err := w.Write(body); err != nil {
+		logger.Error("Error writing JSON response", "error", err)
+	}
+}
+
+// writeResponseTooLarge writes the error returned when a response exceeds MAX_RESPONSE_BYTES
+func writeResponseTooLarge(w http.ResponseWriter, r *http.Request, hint string) {
+	writeErrorResponse(w, r, http.StatusRequestEntityTooLarge, newErrorResponse("response_too_large",
+		fmt.Sprintf("Response exceeds the maximum size of %d bytes - %s", maxResponseBytes, hint)))
+}
+
+// mapResponseUsers returns a copy of the response data with fn applied to
+// every user in it. Responses without users are returned as is.
+func mapResponseUsers(data interface{}, fn func(User) User) interface{} {
+	mapUser := func(user *User) *User {
+		if user == nil {
+			return nil
+		}
+		mapped := fn(*user)
+		return &mapped
+	}
+	mapUsers := func(users []User) []User {
+		if users == nil {
+			return nil
+		}
+		mapped := make([]User, len(users))
+		for i, user := range users {
+			mapped[i] = fn(user)
+		}
+		return mapped
+	}
+
+	switch resp := data.(type) {
+	case UsersResponse:
+		resp.User = mapUser(resp.User)
+		resp.Users = mapUsers(resp.Users)
+		return resp
+	case UserListResponse:
+		resp.Users = mapUsers(resp.Users)
+		return resp
+	case UsersByIDsResponse:
+		resp.Users = mapUsers(resp.Users)
+		return resp
+	case UserGroupsResponse:
+		groups := make(map[string][]User, len(resp.Groups))
+		for role, users := range resp.Groups {
+			groups[role] = mapUsers(users)
+		}
+		resp.Groups = groups
+		return resp
+	case UserWithOrders:
+		resp.User = mapUser(resp.User)
+		return resp
+	case BulkCreateResponse:
+		resp.Users = mapUsers(resp.Users)
+		return resp
+	case OrdersBatchResponse:
+		results := make([]UserOrdersResult, len(resp.Results))
+		for i, result := range resp.Results {
+			result.User = mapUser(result.User)
+			results[i] = result
+		}
+		resp.Results = results
+		return resp
+	default:
+		return data
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"crypto/hmac"
+	"net/http"
+	"testing"
+)
+
+func TestResponsesSigned(t *testing.T) {
+	useTestStore(t)
+	setForTest(t, &signResponses, true)
+	setForTest(t, &responseSigningKey, "response-key")
+	server := newTestServer(t)
+
+	for _, tc := range []struct {
+		path string
+		want int
+	}{
+		{"/users/user-001", http.StatusOK},
+		// Error responses are signed too
+		{"/users/user-404", http.StatusNotFound},
+	} {
+		resp, body := doRequest(t, server, http.MethodGet, tc.path, "")
+		if resp.StatusCode != tc.want {
+			t.Fatalf("GET %s: status = %d, want %d", tc.path, resp.StatusCode, tc.want)
+		}
+		want := "v1=" + computeResponseSignature("response-key", []byte(body))
+		if got := resp.Header.Get("X-Signature"); !hmac.Equal([]byte(got), []byte(want)) {
+			t.Errorf("GET %s: X-Signature = %q, want %q", tc.path, got, want)
+		}
+		if resp.ContentLength != int64(len(body)) {
+			t.Errorf("GET %s: Content-Length = %d, want %d", tc.path, resp.ContentLength, len(body))
+		}
+	}
+}
+
+func TestResponsesUnsignedWithoutKey(t *testing.T) {
+	useTestStore(t)
+	setForTest(t, &signResponses, true)
+	setForTest(t, &responseSigningKey, "")
+	server := newTestServer(t)
+
+	if resp, _ := doRequest(t, server, http.MethodGet, "/users/user-001", ""); resp.Header.Get("X-Signature") != "" {
+		t.Errorf("X-Signature = %q without RESPONSE_SIGNING_KEY, want none", resp.Header.Get("X-Signature"))
+	}
+}
+
+func TestComputeResponseSignature(t *testing.T) {
+	// HMAC-SHA256("key", "The quick brown fox jumps over the lazy dog")
+	const want = "f7bc83f430538424b13298e6aa6fb143ef4d59a14946175997479dbc2d1a3cd8"
+	if got := computeResponseSignature("key", []byte("The quick brown fox jumps over the lazy dog")); got != want {
+		t.Errorf("signature = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// fullListingSize returns the size of an uncapped GET /users?limit=100 body
+func fullListingSize(t *testing.T) int {
+	t.Helper()
+	setForTest(t, &maxResponseBytes, 0)
+	rec := serveHandler(getAllUsers, http.MethodGet, "/users?limit=100", "")
+	return rec.Body.Len()
+}
+
+func TestMaxResponseBytesErrorMode(t *testing.T) {
+	useTestStore(t)
+	size := fullListingSize(t)
+	setForTest(t, &maxResponseBytes, size-1)
+	setForTest(t, &responseSizeMode, "error")
+
+	rec := serveHandler(getAllUsers, http.MethodGet, "/users?limit=100", "")
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("status = %d, want 413: %s", rec.Code, rec.Body)
+	}
+
+	// A response that fits is sent as usual, with its length
+	setForTest(t, &maxResponseBytes, size)
+	rec = serveHandler(getAllUsers, http.MethodGet, "/users?limit=100", "")
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Length") == "" {
+		t.Fatalf("status = %d, Content-Length %q at exactly the cap; want 200 with a length", rec.Code, rec.Header().Get("Content-Length"))
+	}
+}
+
+func TestMaxResponseBytesPaginateMode(t *testing.T) {
+	useTestStore(t)
+	all := listUsersForTest(t, "limit=100")
+	setForTest(t, &maxResponseBytes, fullListingSize(t)-1)
+	setForTest(t, &responseSizeMode, "paginate")
+
+	resp := listUsersForTest(t, "limit=100")
+	if resp.Count == 0 || resp.Count >= all.Count {
+		t.Fatalf("count = %d, want a smaller page than all %d users", resp.Count, all.Count)
+	}
+	if resp.Pagination == nil || resp.Pagination.NextOffset == nil || *resp.Pagination.NextOffset != resp.Count {
+		t.Fatalf("pagination = %+v, want next_offset after the shrunk page", resp.Pagination)
+	}
+
+	// A single user too large for the cap can't be paginated smaller
+	setForTest(t, &maxResponseBytes, 10)
+	rec := serveHandler(getAllUsers, http.MethodGet, "/users?limit=100", "")
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("status = %d, want 413 once a page can't shrink further", rec.Code)
+	}
+}
+
+func TestMaxResponseBytesOrders(t *testing.T) {
+	useTestStore(t)
+	useOrderService(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(testOrdersJSON("user-001")))
+	})
+	setForTest(t, &maxResponseBytes, 200)
+	server := newTestServer(t)
+
+	resp, body := doRequest(t, server, http.MethodGet, "/users/user-001/orders", "")
+	if resp.StatusCode != http.StatusRequestEntityTooLarge || !strings.Contains(body, "too many orders") {
+		t.Fatalf("status = %d, want 413: %s", resp.StatusCode, body)
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// jsonServer serves data with writeJSON
+func jsonServer(t *testing.T, data interface{}) *httptest.Server {
+	t.Helper()
+	return newBackend(t, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, data)
+	})
+}
+
+func TestBufferedJSONResponsesSetContentLength(t *testing.T) {
+	// Large enough that net/http wouldn't set Content-Length by itself
+	data := map[string]string{"padding": strings.Repeat("x", 16<<10)}
+	setForTest(t, &bufferJSONResponses, true)
+	setForTest(t, &jsonBufferMaxBytes, 1<<20)
+
+	resp, body := doRequest(t, jsonServer(t, data), http.MethodGet, "/", "")
+	if resp.ContentLength != int64(len(body)) || len(resp.TransferEncoding) != 0 {
+		t.Fatalf("Content-Length = %d, Transfer-Encoding %v for a %d byte body; want the length", resp.ContentLength, resp.TransferEncoding, len(body))
+	}
+}
+
+func TestBufferedJSONResponsesSpillOverMax(t *testing.T) {
+	data := map[string]string{"padding": strings.Repeat("x", 16<<10)}
+	setForTest(t, &bufferJSONResponses, true)
+	setForTest(t, &jsonBufferMaxBytes, 1024)
+
+	resp, body := doRequest(t, jsonServer(t, data), http.MethodGet, "/", "")
+	if resp.ContentLength != -1 {
+		t.Errorf("Content-Length = %d past JSON_BUFFER_MAX_BYTES, want streaming", resp.ContentLength)
+	}
+	if len(body) < 16<<10 || !strings.HasSuffix(body, "\"}\n") {
+		t.Errorf("streamed body is incomplete (%d bytes)", len(body))
+	}
+}
+
+func TestUnbufferedJSONResponsesStream(t *testing.T) {
+	data := map[string]string{"padding": strings.Repeat("x", 16<<10)}
+	setForTest(t, &bufferJSONResponses, false)
+
+	resp, _ := doRequest(t, jsonServer(t, data), http.MethodGet, "/", "")
+	if resp.ContentLength != -1 {
+		t.Errorf("Content-Length = %d without BUFFER_JSON_RESPONSES, want streaming", resp.ContentLength)
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"time"
+)
+
+// Outbound retry configuration.
+// OUTBOUND_MAX_RETRIES is the number of extra attempts made for a GET that
+// fails with a 5xx or a network error (0 disables retries). Backoff starts at
+// OUTBOUND_RETRY_BASE_DELAY and doubles each attempt up to OUTBOUND_RETRY_MAX_DELAY,
+// with full jitter so cold-starting backends aren't hit in lockstep.
+var (
+	outboundMaxRetries     = envInt("OUTBOUND_MAX_RETRIES", 3)
+	outboundRetryBaseDelay = envDuration("OUTBOUND_RETRY_BASE_DELAY", 100*time.Millisecond)
+	outboundRetryMaxDelay  = envDuration("OUTBOUND_RETRY_MAX_DELAY", 2*time.Second)
+)
+
+// statusError is returned when a backend answers with a non-200 status
+type statusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("service returned %d: %s", e.StatusCode, e.Body)
+}
+
+// retryable reports whether a failed attempt is worth retrying: network
+// errors and 5xx responses are, 4xx responses and cancellations are not
+func retryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	if se, ok := err.(*statusError); ok {
+		return se.StatusCode >= 500
+	}
+	return true
+}
+
+// isTimeout reports whether err is a deadline or network timeout, as opposed
+// to the backend answering with an error
+func isTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// retryBackoff returns the jittered delay before retry number attempt (1-based)
+func retryBackoff(attempt int) time.Duration {
+	if outboundRetryBaseDelay <= 0 {
+		return 0
+	}
+	delay := outboundRetryBaseDelay << (attempt - 1)
+	if delay <= 0 || delay > outboundRetryMaxDelay {
+		delay = outboundRetryMaxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(delay) + 1))
+}
+
+// sleepContext waits for d or until ctx is done, whichever comes first
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestOutboundGETRetriesServerErrors(t *testing.T) {
+	useTestStore(t)
+	setForTest(t, &outboundMaxRetries, 3)
+	setForTest(t, &outboundRetryBaseDelay, time.Millisecond)
+	var calls atomic.Int32
+	useOrderService(t, func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) <= 2 {
+			http.Error(w, "cold start", http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(testOrdersJSON("user-001")))
+	})
+
+	resp, body := doRequest(t, newTestServer(t), http.MethodGet, "/users/user-001/orders", "")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200 after retries: %s", resp.StatusCode, body)
+	}
+	if got := calls.Load(); got != 3 {
+		t.Errorf("backend called %d times, want 3 (two 503s, then success)", got)
+	}
+}
+
+func TestOutboundGETDoesNotRetryClientErrors(t *testing.T) {
+	useTestStore(t)
+	setForTest(t, &outboundMaxRetries, 3)
+	setForTest(t, &outboundRetryBaseDelay, time.Millisecond)
+	var calls atomic.Int32
+	useOrderService(t, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		http.Error(w, "no such user", http.StatusNotFound)
+	})
+
+	doRequest(t, newTestServer(t), http.MethodGet, "/users/user-001/orders", "")
+	if got := calls.Load(); got != 1 {
+		t.Errorf("backend called %d times for a 404, want 1", got)
+	}
+}
+
+func TestOutboundRetriesGiveUp(t *testing.T) {
+	useTestStore(t)
+	setForTest(t, &outboundMaxRetries, 2)
+	setForTest(t, &outboundRetryBaseDelay, time.Millisecond)
+	var calls atomic.Int32
+	useOrderService(t, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		http.Error(w, "down", http.StatusInternalServerError)
+	})
+
+	doRequest(t, newTestServer(t), http.MethodGet, "/users/user-001/orders", "")
+	if got := calls.Load(); got != 3 {
+		t.Errorf("backend called %d times, want 3 (OUTBOUND_MAX_RETRIES=2 plus the first attempt)", got)
+	}
+}
+
+func TestRetryBackoffIsCapped(t *testing.T) {
+	setForTest(t, &outboundRetryBaseDelay, 100*time.Millisecond)
+	setForTest(t, &outboundRetryMaxDelay, 300*time.Millisecond)
+	for attempt := 1; attempt <= 70; attempt++ {
+		if delay := retryBackoff(attempt); delay < 0 || delay > outboundRetryMaxDelay {
+			t.Fatalf("attempt %d: backoff %v outside [0, %v]", attempt, delay, outboundRetryMaxDelay)
+		}
+	}
+
+	setForTest(t, &outboundRetryBaseDelay, 0)
+	if delay := retryBackoff(1); delay != 0 {
+		t.Errorf("backoff = %v with no base delay, want 0", delay)
+	}
+}
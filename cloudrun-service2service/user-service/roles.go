@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// Role mapping configuration.
+// GROUP_ROLE_MAPPING maps group claims on the caller's token to internal roles,
+// e.g. "platform-admins@example.com=admin,engineering@example.com=developer".
+// ENFORCE_ROLES=true makes admin endpoints require the admin role; it needs
+// REQUIRE_AUTH=true, since roles are only resolved for verified callers.
+var (
+	groupRoleMapping = parseGroupRoleMapping(os.Getenv("GROUP_ROLE_MAPPING"))
+	enforceRoles     = envBool("ENFORCE_ROLES", false)
+)
+
+// rolePrecedence ranks roles so the most privileged mapped role wins
+var rolePrecedence = map[string]int{
+	"viewer":    1,
+	"developer": 2,
+	"admin":     3,
+}
+
+// callerClaims are the token claims we use to work out the caller's role
+type callerClaims struct {
+	Email  string   `json:"email"`
+	Groups []string `json:"groups"`
+}
+
+// callerRoleKey is the context key for the caller's resolved role
+type callerRoleKey struct{}
+
+// callerRoleFromContext returns the role resolved for the current caller, or "" if unknown
+func callerRoleFromContext(ctx context.Context) string {
+	role, _ := ctx.Value(callerRoleKey{}).(string)
+	return role
+}
+
+// resolveCallerRole maps the caller's groups to the most privileged internal
+// role. When no group maps to a role, it falls back to the role stored for the
+// user whose email matches the token.
+func resolveCallerRole(ctx context.Context, claims callerClaims) string {
+	best := ""
+	for _, group := range claims.Groups {
+		if role, ok := groupRoleMapping[strings.ToLower(group)]; ok && rolePrecedence[role] > rolePrecedence[best] {
+			best = role
+		}
+	}
+	if best != "" {
+		return best
+	}
+
+	if claims.Email == "" {
+		return ""
+	}
+	all, err := userStore.List(ctx)
+	if err != nil {
+		logger.WarnContext(ctx, "Failed to look up caller's stored role", "error", err)
+		return ""
+	}
+	for _, user := range all {
+		if !user.Deleted && strings.EqualFold(user.Email, claims.Email) {
+			return user.Role
+		}
+	}
+	return ""
+}
+
+// withCallerRole is a middleware that resolves the caller's role and stores it
+// in the request context. Only a caller verified by authenticate gets a
+// role: without REQUIRE_AUTH the bearer token's claims could say anything.
+func withCallerRole(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if caller := callerFromContext(r.Context()); caller != nil {
+			if role := resolveCallerRole(r.Context(), claimsFromCaller(caller)); role != "" {
+				r = r.WithContext(context.WithValue(r.Context(), callerRoleKey{}, role))
+			}
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// checkRoleEnforcement reports ENFORCE_ROLES without REQUIRE_AUTH: no caller
+// would have a verified role, so role-gated endpoints would refuse everyone
+// while looking protected in the configuration
+func checkRoleEnforcement() error {
+	if enforceRoles && !requireAuth {
+		return fmt.Errorf("ENFORCE_ROLES=true needs REQUIRE_AUTH=true, so callers' roles come from verified tokens")
+	}
+	return nil
+}
+
+// claimsFromCaller extracts the role-relevant claims from a verified caller
+func claimsFromCaller(caller *Caller) callerClaims {
+	claims := callerClaims{Email: caller.Email}
+	if groups, ok := caller.Claims["groups"].([]interface{}); ok {
+		for _, group := range groups {
+			if name, ok := group.(string); ok {
+				claims.Groups = append(claims.Groups, name)
+			}
+		}
+	}
+	return claims
+}
+
+// requireRole wraps a handler so that, when ENFORCE_ROLES is enabled, only
+// callers with at least the given role can use it
+func requireRole(role string, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if enforceRoles && rolePrecedence[callerRoleFromContext(r.Context())] < rolePrecedence[role] {
+			writeError(w, r, http.StatusForbidden, "role_required", role)
+			return
+		}
+		handler(w, r)
+	}
+}
+
+// requireTrustedAdmin writes 403 and returns false unless the caller is a
+// trusted admin (see isTrustedCaller). Unlike requireRole it applies whether
+// or not ENFORCE_ROLES is on, for endpoints whose effects can't be undone.
+func requireTrustedAdmin(w http.ResponseWriter, r *http.Request) bool {
+	if isTrustedCaller(r) {
+		return true
+	}
+	writeError(w, r, http.StatusForbidden, "role_required", "admin")
+	return false
+}
+
+// parseGroupRoleMapping parses "group=role" pairs separated by commas
+func parseGroupRoleMapping(raw string) map[string]string {
+	mapping := make(map[string]string)
+	for _, pair := range strings.Split(raw, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		group, role, ok := strings.Cut(pair, "=")
+		role = strings.ToLower(strings.TrimSpace(role))
+		if !ok || group == "" || rolePrecedence[role] == 0 {
+			logger.Warn("Ignoring invalid group role mapping", "value", pair)
+			continue
+		}
+		mapping[strings.ToLower(strings.TrimSpace(group))] = role
+	}
+	return mapping
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestRolesFromVerifiedTokens(t *testing.T) {
+	useTestStore(t)
+	useAuth(t)
+	setForTest(t, &enforceRoles, true)
+	setForTest(t, &allowDrain, true)
+	setForTest(t, &groupRoleMapping, parseGroupRoleMapping("Platform-Admins@example.com=admin,eng@example.com=developer"))
+	server := newTestServer(t)
+
+	for _, tc := range []struct {
+		name   string
+		claims map[string]interface{}
+		want   int
+	}{
+		{"mapped admin group", map[string]interface{}{"email": "svc@example.com", "groups": []string{"eng@example.com", "platform-admins@example.com"}}, http.StatusOK},
+		{"mapped developer group", map[string]interface{}{"email": "svc@example.com", "groups": []string{"eng@example.com"}}, http.StatusForbidden},
+		// No group maps to a role: the stored user with that email decides
+		{"stored admin", map[string]interface{}{"email": "alice@example.com"}, http.StatusOK},
+		{"stored viewer", map[string]interface{}{"email": "carol@example.com"}, http.StatusForbidden},
+		{"unknown caller", map[string]interface{}{"email": "mallory@example.com"}, http.StatusForbidden},
+	} {
+		resp, body := doRequest(t, server, http.MethodGet, "/admin/drain", "", "Authorization", bearer(tc.claims))
+		if resp.StatusCode != tc.want {
+			t.Errorf("%s: status = %d, want %d: %s", tc.name, resp.StatusCode, tc.want, body)
+		}
+	}
+}
+
+func TestRolesIgnoreUnverifiedTokens(t *testing.T) {
+	useTestStore(t)
+	setForTest(t, &requireAuth, false)
+	setForTest(t, &enforceRoles, true)
+	setForTest(t, &allowDrain, true)
+	server := newTestServer(t)
+
+	// Without REQUIRE_AUTH nothing checks the token, so its claims grant nothing
+	resp, _ := doRequest(t, server, http.MethodGet, "/admin/drain", "", "Authorization", bearer(map[string]interface{}{"email": "alice@example.com"}))
+	if resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("status = %d, want 403", resp.StatusCode)
+	}
+}
+
+func TestCheckRoleEnforcement(t *testing.T) {
+	setForTest(t, &enforceRoles, true)
+	setForTest(t, &requireAuth, false)
+	if checkRoleEnforcement() == nil {
+		t.Error("ENFORCE_ROLES without REQUIRE_AUTH was accepted")
+	}
+	setForTest(t, &requireAuth, true)
+	if err := checkRoleEnforcement(); err != nil {
+		t.Errorf("ENFORCE_ROLES with REQUIRE_AUTH: %v", err)
+	}
+}
+
+func TestParseGroupRoleMapping(t *testing.T) {
+	mapping := parseGroupRoleMapping("Admins@example.com=ADMIN, devs@example.com=developer,x=superuser,broken")
+	if len(mapping) != 2 || mapping["admins@example.com"] != "admin" || mapping["devs@example.com"] != "developer" {
+		t.Fatalf("mapping = %v", mapping)
+	}
+}
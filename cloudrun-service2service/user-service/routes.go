@@ -0,0 +1,121 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"golang.org/x/time/rate"
+)
+
+// route declares a handler together with the policy that applies to it, so
+// each endpoint's auth, role, timeout and rate limit are visible in one place
+type route struct {
+	Pattern string
+	Handler http.HandlerFunc
+
+	// Public routes stay reachable without a token when REQUIRE_AUTH is on
+	// (health checks, so platform probes keep working)
+	Public bool
+	// Role is the minimum caller role when ENFORCE_ROLES is on ("" = any caller)
+	Role string
+	// Timeout bounds the request's context, so outbound calls, retries and
+	// store queries are cancelled once it passes (0 = no route deadline).
+	// Clients can shorten it with X-Request-Timeout, never lengthen it.
+	Timeout time.Duration
+	// RatePerSecond caps requests to the route across all clients (0 = unlimited)
+	RatePerSecond int
+	// Enabled, when set, switches the route on and off. A disabled route
+	// answers 404 before any other policy runs, so its rate limit and role
+	// check don't give away that it exists.
+	Enabled *bool
+}
+
+// routes is every endpoint the service serves
+var routes = []route{
+	{Pattern: "/", Handler: healthHandler, Public: true, Timeout: 2 * time.Second},
+	{Pattern: "/health", Handler: healthHandler, Public: true, Timeout: 2 * time.Second},
+	{Pattern: "/readyz", Handler: readyzHandler, Public: true, Timeout: 10 * time.Second},
+	{Pattern: "/users", Handler: usersHandler, Timeout: 30 * time.Second},
+	// More specific than /users/, so it's matched before the {id} routes
+	{Pattern: "/users/count", Handler: usersCountHandler, Timeout: 10 * time.Second},
+	{Pattern: "/users/orders/batch", Handler: ordersBatchHandler, Timeout: 60 * time.Second},
+	// Covers /users/{id}/orders, which calls the Order Service with retries
+	{Pattern: "/users/", Handler: userByIDHandler, Timeout: 60 * time.Second},
+	{Pattern: "/users:byName", Handler: usersByNameHandler, Timeout: 10 * time.Second},
+	{Pattern: "/version", Handler: versionHandler, Timeout: 2 * time.Second},
+	{Pattern: "/capabilities", Handler: capabilitiesHandler, Timeout: 2 * time.Second},
+	{Pattern: "/openapi.json", Handler: openAPIHandler, Timeout: 2 * time.Second},
+	{Pattern: "/docs", Handler: docsHandler, Timeout: 2 * time.Second},
+	{Pattern: "/mesh/health", Handler: meshHealthHandler, Timeout: 15 * time.Second},
+	{Pattern: "/status", Handler: statusHandler, Timeout: 15 * time.Second},
+	// Forwards anything under /orders to the Order Service with this service's identity
+	{Pattern: "/proxy/orders", Handler: orderServiceProxy.ServeHTTP, Role: "developer", Timeout: 30 * time.Second, Enabled: &allowOrdersProxy},
+	{Pattern: "/proxy/orders/", Handler: orderServiceProxy.ServeHTTP, Role: "developer", Timeout: 30 * time.Second, Enabled: &allowOrdersProxy},
+	{Pattern: "/stats", Handler: statsHandler, Timeout: 2 * time.Second},
+	{Pattern: "/metrics", Handler: promhttp.Handler().ServeHTTP, Timeout: 10 * time.Second},
+	{Pattern: "/admin/counters", Handler: countersHandler, Role: "admin", Timeout: 2 * time.Second, Enabled: &allowAdminCounters},
+	{Pattern: "/admin/snapshot", Handler: snapshotHandler, Role: "admin", Timeout: 30 * time.Second, RatePerSecond: 1, Enabled: &allowSnapshot},
+	{Pattern: "/admin/reset", Handler: demoResetHandler, Role: "admin", Timeout: 10 * time.Second, RatePerSecond: 1, Enabled: &allowDemoReset},
+	{Pattern: "/admin/drain", Handler: drainHandler, Role: "admin", Timeout: 2 * time.Second, Enabled: &allowDrain},
+	{Pattern: "/admin/migrate/ids", Handler: idMigrationHandler, Role: "admin", Timeout: 30 * time.Second, RatePerSecond: 1, Enabled: &allowIDMigration},
+}
+
+// registerRoutes registers each route on mux wrapped in its policy, and marks
+// public routes as exempt from token verification
+func registerRoutes(mux *http.ServeMux, routes []route) {
+	for _, rt := range routes {
+		handler := withRouteSpan(rt.Pattern, rt.Handler)
+		handler = withRouteTimeout(rt.Timeout, handler)
+		if rt.RatePerSecond > 0 {
+			handler = withRouteRateLimit(rate.NewLimiter(rate.Limit(rt.RatePerSecond), rt.RatePerSecond), handler)
+		}
+		if rt.Role != "" {
+			handler = requireRole(rt.Role, handler)
+		}
+		if rt.Enabled != nil {
+			handler = withRouteEnabled(rt.Enabled, handler)
+		}
+		if rt.Public {
+			authExemptPaths[rt.Pattern] = true
+		}
+		mux.HandleFunc(rt.Pattern, handler)
+	}
+}
+
+// withRouteEnabled answers 404 while *enabled is false, as for a route that
+// doesn't exist
+func withRouteEnabled(enabled *bool, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !*enabled {
+			writeError(w, r, http.StatusNotFound, "route_not_found", r.URL.Path)
+			return
+		}
+		handler(w, r)
+	}
+}
+
+// withRouteTimeout gives the request's context a deadline of timeout, or the
+// client's X-Request-Timeout if that's sooner
+func withRouteTimeout(timeout time.Duration, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		clientTimeout, ok := parseRequestTimeout(r)
+		if !ok {
+			writeError(w, r, http.StatusBadRequest, "invalid_request_timeout", r.Header.Get(requestTimeoutHeader))
+			return
+		}
+		ctx, cancel := withClientDeadline(r.Context(), clientTimeout, timeout)
+		defer cancel()
+		handler(w, r.WithContext(ctx))
+	}
+}
+
+// withRouteRateLimit rejects requests over the route's rate with 429
+func withRouteRateLimit(limiter *rate.Limiter, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !allowRate(w, r, limiter, "route_rate_limited") {
+			return
+		}
+		handler(w, r)
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// newRouteServer serves routes behind the full middleware chain
+func newRouteServer(t *testing.T, routes []route) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	registerRoutes(mux, routes)
+	t.Cleanup(func() {
+		for _, rt := range routes {
+			delete(authExemptPaths, rt.Pattern)
+		}
+	})
+	server := httptest.NewServer(Chain(serverMiddleware...)(mux))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func okHandler(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+}
+
+func TestRoutePublicAndRole(t *testing.T) {
+	useTestStore(t)
+	useAuth(t)
+	setForTest(t, &enforceRoles, true)
+	server := newRouteServer(t, []route{
+		{Pattern: "/test/public", Handler: okHandler, Public: true},
+		{Pattern: "/test/private", Handler: okHandler},
+		{Pattern: "/test/admin", Handler: okHandler, Role: "admin"},
+	})
+	viewer := bearer(map[string]interface{}{"email": "carol@example.com"})
+	admin := bearer(map[string]interface{}{"email": "alice@example.com"})
+
+	for _, tc := range []struct {
+		path, authorization string
+		want                int
+	}{
+		{"/test/public", "", http.StatusOK},
+		{"/test/private", "", http.StatusUnauthorized},
+		{"/test/private", viewer, http.StatusOK},
+		{"/test/admin", viewer, http.StatusForbidden},
+		{"/test/admin", admin, http.StatusOK},
+	} {
+		var headers []string
+		if tc.authorization != "" {
+			headers = []string{"Authorization", tc.authorization}
+		}
+		if resp, body := doRequest(t, server, http.MethodGet, tc.path, "", headers...); resp.StatusCode != tc.want {
+			t.Errorf("GET %s: status = %d, want %d: %s", tc.path, resp.StatusCode, tc.want, body)
+		}
+	}
+}
+
+func TestRouteTimeout(t *testing.T) {
+	deadlines := make(chan time.Duration, 1)
+	server := newRouteServer(t, []route{{
+		Pattern: "/test/timeout",
+		Timeout: time.Minute,
+		Handler: func(w http.ResponseWriter, r *http.Request) {
+			deadline, _ := r.Context().Deadline()
+			deadlines <- time.Until(deadline)
+		},
+	}})
+
+	doRequest(t, server, http.MethodGet, "/test/timeout", "")
+	if remaining := <-deadlines; remaining <= 50*time.Second || remaining > time.Minute {
+		t.Errorf("deadline in %v, want the route's minute", remaining)
+	}
+
+	// X-Request-Timeout can shorten the route's timeout but not lengthen it
+	doRequest(t, server, http.MethodGet, "/test/timeout", "", requestTimeoutHeader, "2s")
+	if remaining := <-deadlines; remaining > 2*time.Second {
+		t.Errorf("deadline in %v with X-Request-Timeout: 2s, want at most 2s", remaining)
+	}
+	doRequest(t, server, http.MethodGet, "/test/timeout", "", requestTimeoutHeader, "1h")
+	if remaining := <-deadlines; remaining > time.Minute {
+		t.Errorf("deadline in %v with X-Request-Timeout: 1h, want at most the route's minute", remaining)
+	}
+
+	if resp, _ := doRequest(t, server, http.MethodGet, "/test/timeout", "", requestTimeoutHeader, "soon"); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("invalid X-Request-Timeout: status = %d, want 400", resp.StatusCode)
+	}
+}
+
+func TestRouteRateLimit(t *testing.T) {
+	server := newRouteServer(t, []route{{Pattern: "/test/limited", Handler: okHandler, RatePerSecond: 1}})
+
+	if resp, _ := doRequest(t, server, http.MethodGet, "/test/limited", ""); resp.StatusCode != http.StatusOK {
+		t.Fatalf("first request: status = %d, want 200", resp.StatusCode)
+	}
+	if resp, _ := doRequest(t, server, http.MethodGet, "/test/limited", ""); resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("second request within the second: status = %d, want 429", resp.StatusCode)
+	}
+}
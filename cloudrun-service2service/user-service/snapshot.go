@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Snapshot configuration.
+// SNAPSHOT_DIR can be a local path or a Cloud Storage bucket mounted into the
+// container (Cloud Run volume mounts), which is how snapshots end up in GCS.
+var (
+	snapshotDir      = os.Getenv("SNAPSHOT_DIR")
+	snapshotInterval = envDuration("SNAPSHOT_INTERVAL", 0)
+)
+
+// ALLOW_SNAPSHOT (or ENABLE_ADMIN) enables POST /admin/snapshot. Off by
+// default, since a snapshot writes every user to SNAPSHOT_DIR. Periodic
+// snapshots (SNAPSHOT_INTERVAL) don't need it.
+var allowSnapshot = envBool("ALLOW_SNAPSHOT", envBool("ENABLE_ADMIN", false))
+
+// Snapshot is the on-disk representation of the in-memory user store
+type Snapshot struct {
+	TakenAt time.Time `json:"taken_at"`
+	Count   int       `json:"count"`
+	Users   []User    `json:"users"`
+}
+
+// SnapshotResponse represents the response for the snapshot endpoint
+type SnapshotResponse struct {
+	Service  string `json:"service"`
+	Location string `json:"location"`
+	Count    int    `json:"count"`
+	Message  string `json:"message"`
+}
+
+// takeSnapshot writes the current store contents to a new file in dir and
+// returns its path. The store returns a copy of its users, so the snapshot
+// is consistent even while writes are happening.
+func takeSnapshot(ctx context.Context, dir string) (string, int, error) {
+	list, err := userStore.List(ctx)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to list users: %v", err)
+	}
+	snapshot := Snapshot{
+		TakenAt: time.Now().UTC(),
+		Count:   len(list),
+		Users:   list,
+	}
+
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to encode snapshot: %v", err)
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", 0, fmt.Errorf("failed to create snapshot directory: %v", err)
+	}
+
+	// Write to a temp file and rename so readers never see a partial snapshot
+	name := fmt.Sprintf("users-%s.json", snapshot.TakenAt.Format("20060102T150405.000000000Z"))
+	location := filepath.Join(dir, name)
+	tmp := location + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return "", 0, fmt.Errorf("failed to write snapshot: %v", err)
+	}
+	if err := os.Rename(tmp, location); err != nil {
+		os.Remove(tmp)
+		return "", 0, fmt.Errorf("failed to finalize snapshot: %v", err)
+	}
+
+	return location, snapshot.Count, nil
+}
+
+// runPeriodicSnapshots takes a snapshot every interval until ctx is cancelled
+func runPeriodicSnapshots(ctx context.Context, dir string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			location, count, err := takeSnapshot(ctx, dir)
+			if err != nil {
+				logger.Error("Periodic snapshot failed", "error", err)
+				continue
+			}
+			logger.Info("Periodic snapshot written", "users", count, "location", location)
+		}
+	}
+}
+
+// snapshotHandler handles POST /admin/snapshot to take a snapshot on demand
+func snapshotHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, r, http.MethodPost)
+		return
+	}
+
+	if snapshotDir == "" {
+		writeErrorResponse(w, r, http.StatusServiceUnavailable, newErrorResponse("not_configured", "SNAPSHOT_DIR not configured - snapshots are disabled"))
+		return
+	}
+
+	location, count, err := takeSnapshot(r.Context(), snapshotDir)
+	if err != nil {
+		logger.ErrorContext(r.Context(), "Error taking snapshot", "error", err)
+		writeErrorResponse(w, r, http.StatusInternalServerError, newErrorResponse("internal_error", "Failed to take snapshot"))
+		return
+	}
+
+	logger.InfoContext(r.Context(), "Snapshot written", "users", count, "location", location)
+	writeJSON(w, http.StatusOK, SnapshotResponse{
+		Service:  "user-service (Go)",
+		Location: location,
+		Count:    count,
+		Message:  "Snapshot taken successfully",
+	})
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"testing"
+)
+
+func TestSnapshotDisabledByDefault(t *testing.T) {
+	setForTest(t, &allowSnapshot, false)
+	setForTest(t, &snapshotDir, t.TempDir())
+
+	resp, _ := doRequest(t, newTestServer(t), http.MethodPost, "/admin/snapshot", "")
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("status = %d, want 404", resp.StatusCode)
+	}
+}
+
+func TestSnapshotMethodNotAllowed(t *testing.T) {
+	setForTest(t, &allowSnapshot, true)
+
+	rec := serveHandler(snapshotHandler, http.MethodGet, "/admin/snapshot", "")
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("status = %d, want 405", rec.Code)
+	}
+	if allow := rec.Header().Get("Allow"); allow != http.MethodPost {
+		t.Errorf("Allow = %q, want POST", allow)
+	}
+}
+
+func TestSnapshotNotConfigured(t *testing.T) {
+	setForTest(t, &allowSnapshot, true)
+	setForTest(t, &snapshotDir, "")
+
+	rec := serveHandler(snapshotHandler, http.MethodPost, "/admin/snapshot", "")
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d, want 503", rec.Code)
+	}
+}
+
+func TestSnapshotWritesStore(t *testing.T) {
+	useTestStore(t)
+	setForTest(t, &allowSnapshot, true)
+	setForTest(t, &snapshotDir, t.TempDir())
+
+	rec := serveHandler(snapshotHandler, http.MethodPost, "/admin/snapshot", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body)
+	}
+	var resp SnapshotResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(resp.Location)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var snapshot Snapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		t.Fatal(err)
+	}
+	if snapshot.Count != 3 || len(snapshot.Users) != 3 || resp.Count != 3 {
+		t.Fatalf("snapshot has %d users (response says %d), want 3", len(snapshot.Users), resp.Count)
+	}
+}
+
+func TestSnapshotCapability(t *testing.T) {
+	setForTest(t, &snapshotDir, t.TempDir())
+	setForTest(t, &allowSnapshot, false)
+	if currentCapabilities()["snapshots"].Parameters["on_demand"] != false {
+		t.Error("on-demand snapshots advertised while ALLOW_SNAPSHOT is off")
+	}
+	setForTest(t, &allowSnapshot, true)
+	if currentCapabilities()["snapshots"].Parameters["on_demand"] != true {
+		t.Error("on-demand snapshots not advertised with ALLOW_SNAPSHOT on")
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// Users are soft-deleted by default: DELETE /users/{id} marks them deleted
+// (keeping the record for auditing) and hides them from listings, lookups
+// and every other endpoint, and POST /users/{id}/restore brings them back.
+// DELETE /users/{id}?hard=true removes the record for good, deleted or not.
+
+// includeDeleted reports whether the request asked to see soft-deleted users
+func includeDeleted(r *http.Request) bool {
+	include, _ := strconv.ParseBool(r.URL.Query().Get("include_deleted"))
+	return include
+}
+
+// hardDelete reports whether a DELETE asked to remove the record for good
+func hardDelete(r *http.Request) bool {
+	hard, _ := strconv.ParseBool(r.URL.Query().Get("hard"))
+	return hard
+}
+
+// lookupUser returns the user for a GET: like findUser, but soft-deleted users
+// are returned too with ?include_deleted=true
+func lookupUser(r *http.Request, userID string) (User, error) {
+	if includeDeleted(r) {
+		return userStore.Get(r.Context(), userID)
+	}
+	return findUser(r.Context(), userID)
+}
+
+// softDeleteUser marks the user deleted, keeping the record so it can be restored
+func softDeleteUser(w http.ResponseWriter, r *http.Request, userID string) {
+	deleted, err := userStore.SetDeleted(r.Context(), userID, true)
+	if errors.Is(err, errUserNotFound) {
+		writeError(w, r, http.StatusNotFound, "user_not_found", userID)
+		return
+	}
+	if err != nil {
+		writeStoreFailure(w, r, err)
+		return
+	}
+
+	usersDeletedTotal.Add(1)
+	w.Header().Set("ETag", userETag(deleted))
+	writeJSON(w, http.StatusOK, UsersResponse{
+		Service: "user-service (Go)",
+		User:    &deleted,
+		Message: fmt.Sprintf("User '%s' deleted successfully - restore with POST %s", userID, selfURL("/users/"+userID+"/restore")),
+	})
+}
+
+// restoreUser handles POST /users/{id}/restore, undoing a soft delete
+func restoreUser(w http.ResponseWriter, r *http.Request, userID string) {
+	if !allowStoreMutation(w, r) {
+		return
+	}
+
+	restored, err := userStore.SetDeleted(r.Context(), userID, false)
+	if errors.Is(err, errUserNotFound) {
+		writeError(w, r, http.StatusNotFound, "user_not_found", userID)
+		return
+	}
+	if errors.Is(err, errUserNotDeleted) {
+		writeError(w, r, http.StatusConflict, "user_not_deleted", userID)
+		return
+	}
+	if err != nil {
+		writeStoreFailure(w, r, err)
+		return
+	}
+
+	logger.InfoContext(r.Context(), "User restored", "user_id", userID)
+	w.Header().Set("ETag", userETag(restored))
+	writeJSON(w, http.StatusOK, UsersResponse{
+		Service: "user-service (Go)",
+		User:    &restored,
+		Message: fmt.Sprintf("User '%s' restored successfully", userID),
+	})
+}
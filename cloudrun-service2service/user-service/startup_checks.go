@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+
+	"golang.org/x/oauth2/google"
+)
+
+// Startup check configuration.
+// A misconfigured revision otherwise only shows up as 500s on the first
+// request that needs the setting, so main validates configuration before
+// serving and exits non-zero if something required is missing or invalid;
+// Cloud Run then keeps traffic on the previous revision. STARTUP_CHECKS=false
+// skips them (e.g. locally without credentials).
+var startupChecks = envBool("STARTUP_CHECKS", true)
+
+// runStartupChecks returns every configuration problem that would make
+// requests fail, so they can all be fixed in one deploy
+func runStartupChecks(ctx context.Context) []error {
+	var problems []error
+
+	orderURL := getOrderServiceURL()
+	if orderURL == "" {
+		return problems
+	}
+	if err := validateServiceURL(orderURL); err != nil {
+		return append(problems, fmt.Errorf("ORDER_SERVICE_URL: %v", err))
+	}
+
+	u, _ := url.Parse(orderURL)
+	backend := u.Scheme + "://" + u.Host
+	switch outboundAuthMode(backend) {
+	case authModeHMAC:
+		if outboundHMACSecret == "" {
+			problems = append(problems, errors.New("OUTBOUND_AUTH_MODES signs Order Service calls with HMAC but OUTBOUND_HMAC_SECRET is empty"))
+		}
+	case authModeOIDC:
+		// ID tokens come from the metadata server, which is also where Cloud
+		// Run's default credentials come from; without any credentials every
+		// Order Service call would fail
+		if _, err := google.FindDefaultCredentials(ctx); err != nil {
+			problems = append(problems, fmt.Errorf("no Google credentials to authenticate Order Service calls: %v", err))
+		}
+	}
+	return problems
+}
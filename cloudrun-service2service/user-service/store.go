@@ -0,0 +1,345 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Store backend configuration.
+// STORE_BACKEND selects where users are kept: "memory" (the default, seeded
+// with demo users and lost on restart) or "firestore".
+var storeBackend = strings.ToLower(envString("STORE_BACKEND", "memory"))
+
+// Errors returned by UserStore implementations. Handlers map them to statuses;
+// anything else is a backend failure.
+var (
+	errUserNotFound   = errors.New("user not found")
+	errUserExists     = errors.New("user ID already exists")
+	errEmailTaken     = errors.New("email already in use")
+	errNameTaken      = errors.New("name already in use")
+	errUserNotDeleted = errors.New("user is not deleted")
+	errStoreFull      = errors.New("user store is full")
+)
+
+// UserStore persists users. Implementations must be safe for concurrent use
+// and enforce uniqueness atomically with the write, so two concurrent requests
+// can't both claim the same email (or name).
+type UserStore interface {
+	// List returns every user in creation order
+	List(ctx context.Context) ([]User, error)
+	// Get returns the user with the given ID, or errUserNotFound
+	Get(ctx context.Context, id string) (User, error)
+	// Create stores user, generating an ID if it has none, and returns the stored user
+	Create(ctx context.Context, user User, opts WriteOptions) (User, error)
+	// Update applies patch to the user with the given ID and returns it before
+	// and after. Soft-deleted users can't be updated (errUserNotFound).
+	Update(ctx context.Context, id string, patch UserPatch, opts WriteOptions) (User, User, error)
+	// Delete removes the user with the given ID, or returns errUserNotFound
+	Delete(ctx context.Context, id string) error
+	// SetDeleted soft-deletes (deleted=true) or restores the user with the
+	// given ID and returns it. Soft-deleting a deleted user returns
+	// errUserNotFound; restoring one that isn't deleted, errUserNotDeleted.
+	// Soft-deleted users keep their email and name, so a restore can't collide.
+	SetDeleted(ctx context.Context, id string, deleted bool) (User, error)
+}
+
+// WriteOptions are the per-request rules a store checks on Create and Update
+type WriteOptions struct {
+	// UniqueNames rejects a name another user already has with errNameTaken
+	UniqueNames bool
+	// IfMatch, if set, is the request's If-Match header. Update fails with
+	// errPreconditionFailed unless it matches the user's current ETag.
+	IfMatch string
+}
+
+// userStore is the store every handler reads and writes through.
+// It's replaced at startup when STORE_BACKEND selects another backend.
+var userStore UserStore = newMemoryStore(seedUsers())
+
+// newUserStore creates the store selected by STORE_BACKEND
+func newUserStore(ctx context.Context) (UserStore, error) {
+	switch storeBackend {
+	case "memory":
+		return newMemoryStore(seedUsers()), nil
+	case "firestore":
+		return newFirestoreStore(ctx, firestoreProjectID, firestoreCollection)
+	default:
+		return nil, fmt.Errorf("unknown STORE_BACKEND %q (want memory or firestore)", storeBackend)
+	}
+}
+
+// findUser returns the user with the given ID, or errUserNotFound if there's
+// none or it's soft-deleted
+func findUser(ctx context.Context, userID string) (User, error) {
+	user, err := userStore.Get(ctx, userID)
+	if err == nil && user.Deleted {
+		return User{}, errUserNotFound
+	}
+	return user, err
+}
+
+// setDeletedState returns user soft-deleted (or restored) at now
+func setDeletedState(user User, deleted bool, now time.Time) User {
+	user.Deleted = deleted
+	user.DeletedAt = nil
+	if deleted {
+		user.DeletedAt = &now
+	}
+	user.UpdatedAt = &now
+	return user
+}
+
+// writeStoreFailure logs a backend error and reports it to the client without
+// leaking details (unless ERROR_VERBOSITY=debug)
+func writeStoreFailure(w http.ResponseWriter, r *http.Request, err error) {
+	logger.ErrorContext(r.Context(), "User store error", "backend", storeBackend, "error", err)
+	if clientDeadlineExceeded(r.Context()) {
+		writeClientDeadlineExceeded(w, r)
+		return
+	}
+	writeErrorResponse(w, r, http.StatusServiceUnavailable, newErrorResponse("store_unavailable", clientErrorMessage("User store unavailable - try again shortly", err)))
+}
+
+// applyPatch returns user with the patch's fields set, preserving CreatedAt
+func applyPatch(user User, patch UserPatch, now time.Time) User {
+	if patch.Name != nil {
+		user.Name = *patch.Name
+	}
+	if patch.Email != nil {
+		user.Email = *patch.Email
+	}
+	if patch.Role != nil {
+		user.Role = *patch.Role
+	}
+	user.UpdatedAt = &now
+	return user
+}
+
+// sameName reports whether two names collide under UNIQUE_NAMES_IGNORE_CASE
+func sameName(a, b string) bool {
+	return a == b || (uniqueNamesIgnoreCase && strings.EqualFold(a, b))
+}
+
+// memoryStore keeps users in a slice (simulating a database).
+// Cloud Run serves up to 80 concurrent requests per instance by default, so
+// every read holds the read lock and every mutation the write lock.
+type memoryStore struct {
+	mu    sync.RWMutex
+	users []User
+
+	// uuidIDs is set once sequential IDs have been migrated, after which
+	// new users get UUIDs too
+	uuidIDs bool
+
+	// seedIDs are the demo users, which MAX_USERS eviction never removes
+	seedIDs map[string]bool
+
+	// lastModified is when users last changed (Last-Modified on GET /users)
+	lastModified time.Time
+}
+
+func newMemoryStore(seed []User) *memoryStore {
+	return &memoryStore{users: seed, seedIDs: userIDSet(seed), lastModified: time.Now().UTC()}
+}
+
+// LastModified returns when a user was last created, changed or removed
+func (s *memoryStore) LastModified() time.Time {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.lastModified
+}
+
+// userIDSet returns the IDs of users as a set
+func userIDSet(users []User) map[string]bool {
+	ids := make(map[string]bool, len(users))
+	for _, user := range users {
+		ids[user.ID] = true
+	}
+	return ids
+}
+
+func (s *memoryStore) List(ctx context.Context) ([]User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]User(nil), s.users...), nil
+}
+
+// Get returns a copy of the user, so callers can never observe or cause
+// changes to the stored record outside the lock
+func (s *memoryStore) Get(ctx context.Context, id string) (User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if i := s.indexLocked(id); i >= 0 {
+		return s.users[i], nil
+	}
+	return User{}, errUserNotFound
+}
+
+func (s *memoryStore) Create(ctx context.Context, user User, opts WriteOptions) (User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if user.ID != "" && s.indexLocked(user.ID) >= 0 {
+		return User{}, errUserExists
+	}
+	if emailTaken(s.users, user.Email, "") {
+		return User{}, errEmailTaken
+	}
+	if opts.UniqueNames && nameTaken(s.users, user.Name, "") {
+		return User{}, errNameTaken
+	}
+	if err := s.makeRoomLocked(ctx); err != nil {
+		return User{}, err
+	}
+
+	if user.ID == "" {
+		id, err := s.newIDLocked()
+		if err != nil {
+			return User{}, fmt.Errorf("failed to generate user ID: %v", err)
+		}
+		user.ID = id
+	}
+	user.CreatedAt = time.Now().UTC()
+	user.UpdatedAt = nil
+	user.LastAccessedAt = nil
+	user.Deleted, user.DeletedAt = false, nil
+	s.users = append(s.users, user)
+	s.lastModified = user.CreatedAt
+	return user, nil
+}
+
+func (s *memoryStore) Update(ctx context.Context, id string, patch UserPatch, opts WriteOptions) (User, User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.indexLocked(id)
+	if i < 0 || s.users[i].Deleted {
+		return User{}, User{}, errUserNotFound
+	}
+	if opts.IfMatch != "" && !etagListMatches(opts.IfMatch, userETag(s.users[i]), false) {
+		return User{}, User{}, errPreconditionFailed
+	}
+	if opts.UniqueNames && patch.Name != nil && nameTaken(s.users, *patch.Name, id) {
+		return User{}, User{}, errNameTaken
+	}
+	if patch.Email != nil && emailTaken(s.users, *patch.Email, id) {
+		return User{}, User{}, errEmailTaken
+	}
+
+	before := s.users[i]
+	now := time.Now().UTC()
+	s.users[i] = applyPatch(before, patch, now)
+	s.lastModified = now
+	return before, s.users[i], nil
+}
+
+func (s *memoryStore) Delete(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.indexLocked(id)
+	if i < 0 {
+		return errUserNotFound
+	}
+	s.users = append(s.users[:i], s.users[i+1:]...)
+	s.lastModified = time.Now().UTC()
+	return nil
+}
+
+func (s *memoryStore) SetDeleted(ctx context.Context, id string, deleted bool) (User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.indexLocked(id)
+	switch {
+	case i < 0, deleted && s.users[i].Deleted:
+		return User{}, errUserNotFound
+	case !deleted && !s.users[i].Deleted:
+		return User{}, errUserNotDeleted
+	}
+	now := time.Now().UTC()
+	s.users[i] = setDeletedState(s.users[i], deleted, now)
+	s.lastModified = now
+	return s.users[i], nil
+}
+
+// indexLocked returns the position of the user with the given ID, or -1.
+// Caller must hold s.mu.
+func (s *memoryStore) indexLocked(id string) int {
+	for i := range s.users {
+		if s.users[i].ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// newIDLocked generates an ID for a new user. Caller must hold s.mu.
+// Deletes and evictions leave gaps, so it skips sequential IDs still in use.
+func (s *memoryStore) newIDLocked() (string, error) {
+	if s.uuidIDs {
+		return newUUID()
+	}
+	for n := len(s.users) + 1; ; n++ {
+		if id := fmt.Sprintf("user-%03d", n); s.indexLocked(id) < 0 {
+			return id, nil
+		}
+	}
+}
+
+// reset replaces every user with seed in one step, so concurrent requests see
+// either the old store or the seeded one, never a mix. It returns the IDs
+// that were in the store before.
+func (s *memoryStore) reset(seed []User) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	previous := make([]string, len(s.users))
+	for i, user := range s.users {
+		previous[i] = user.ID
+	}
+	s.users = seed
+	s.uuidIDs = false
+	s.seedIDs = userIDSet(seed)
+	s.lastModified = time.Now().UTC()
+	return previous
+}
+
+// migrateSequentialIDs gives every user with a sequential ID a UUID and
+// returns the old → new mapping. New users get UUIDs from then on.
+func (s *memoryStore) migrateSequentialIDs() (map[string]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Generate every ID first so a failure leaves the store untouched
+	mappings := make(map[string]string)
+	for _, user := range s.users {
+		if !sequentialIDPattern.MatchString(user.ID) {
+			continue
+		}
+		newID, err := newUUID()
+		if err != nil {
+			return nil, err
+		}
+		mappings[user.ID] = newID
+	}
+	for i := range s.users {
+		if newID, ok := mappings[s.users[i].ID]; ok {
+			if s.seedIDs[s.users[i].ID] {
+				delete(s.seedIDs, s.users[i].ID)
+				s.seedIDs[newID] = true
+			}
+			s.users[i].ID = newID
+		}
+	}
+	if len(mappings) > 0 {
+		s.lastModified = time.Now().UTC()
+	}
+	s.uuidIDs = true
+	return mappings, nil
+}
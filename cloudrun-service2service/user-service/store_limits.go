@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// Store mutation rate configuration.
+// STORE_MUTATIONS_PER_SECOND caps creates/updates/deletes across all clients
+// (0 = unlimited) so runaway automation can't churn the store. Reads are exempt.
+var (
+	storeMutationsPerSecond = envInt("STORE_MUTATIONS_PER_SECOND", 0)
+	storeMutationBurst      = envInt("STORE_MUTATION_BURST", storeMutationsPerSecond)
+)
+
+// Store capacity configuration.
+// MAX_USERS caps how many users the memory store holds (0 = unlimited), so a
+// long-running instance taking creates during a load test doesn't grow without
+// bound. Soft-deleted users count towards it. MAX_USERS_POLICY picks what a
+// create past the cap does: "reject" it with 507 Insufficient Storage (the
+// default), or "evict" the oldest user that isn't one of the demo users.
+var (
+	maxUsers       = envInt("MAX_USERS", 0)
+	maxUsersPolicy = strings.ToLower(envString("MAX_USERS_POLICY", "reject"))
+)
+
+// checkMaxUsersPolicy reports an unknown MAX_USERS_POLICY
+func checkMaxUsersPolicy() error {
+	if maxUsersPolicy != "reject" && maxUsersPolicy != "evict" {
+		return fmt.Errorf("unknown MAX_USERS_POLICY %q (want reject or evict)", maxUsersPolicy)
+	}
+	return nil
+}
+
+// makeRoomLocked makes sure one more user fits under MAX_USERS, evicting the
+// oldest non-seed users if the policy allows, or returns errStoreFull. Caller
+// must hold s.mu for writing.
+func (s *memoryStore) makeRoomLocked(ctx context.Context) error {
+	if maxUsers <= 0 {
+		return nil
+	}
+	for len(s.users) >= maxUsers {
+		if maxUsersPolicy != "evict" {
+			return errStoreFull
+		}
+		// Users are kept in creation order, so the first non-seed user is the oldest
+		i := 0
+		for i < len(s.users) && s.seedIDs[s.users[i].ID] {
+			i++
+		}
+		if i == len(s.users) {
+			return errStoreFull
+		}
+		evicted := s.users[i]
+		s.users = append(s.users[:i], s.users[i+1:]...)
+		s.lastModified = time.Now().UTC()
+		forgetAccess(evicted.ID)
+		usersEvictedTotal.Add(1)
+		logger.InfoContext(ctx, "Evicted user to stay under MAX_USERS", "user_id", evicted.ID, "max_users", maxUsers)
+	}
+	return nil
+}
+
+// storeMutationLimiter is shared by every mutating handler
+var storeMutationLimiter = newStoreMutationLimiter(storeMutationsPerSecond, storeMutationBurst)
+
+func newStoreMutationLimiter(perSecond, burst int) *rate.Limiter {
+	if perSecond <= 0 {
+		return rate.NewLimiter(rate.Inf, 0)
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	return rate.NewLimiter(rate.Limit(perSecond), burst)
+}
+
+// allowStoreMutation reports whether a mutation may proceed, writing a 429
+// with Retry-After when the store-wide mutation rate has been exceeded
+func allowStoreMutation(w http.ResponseWriter, r *http.Request) bool {
+	return allowRate(w, r, storeMutationLimiter, "rate_limited")
+}
+
+// allowRate reports whether limiter has a token for this request, writing a
+// 429 (with the given error code) and Retry-After when it doesn't
+func allowRate(w http.ResponseWriter, r *http.Request, limiter *rate.Limiter, code string) bool {
+	reservation := limiter.Reserve()
+	if !reservation.OK() {
+		writeError(w, r, http.StatusTooManyRequests, code)
+		return false
+	}
+
+	delay := reservation.Delay()
+	if delay == 0 {
+		return true
+	}
+
+	// Over the limit: give the token back and tell the client when to retry
+	reservation.Cancel()
+	w.Header().Set("Retry-After", strconv.Itoa(int(delay.Round(time.Second)/time.Second)+1))
+	writeError(w, r, http.StatusTooManyRequests, code)
+	return false
+}
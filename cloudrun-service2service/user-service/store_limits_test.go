@@ -0,0 +1,38 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestStoreMutationRateLimit(t *testing.T) {
+	useTestStore(t)
+	setForTest(t, &storeMutationLimiter, newStoreMutationLimiter(1, 2))
+
+	for i, want := range []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests} {
+		rec := serveHandler(userByIDHandler, http.MethodPatch, "/users/user-002", `{"role":"viewer"}`, "Content-Type", "application/merge-patch+json")
+		if rec.Code != want {
+			t.Fatalf("mutation %d: status = %d, want %d", i+1, rec.Code, want)
+		}
+		if want == http.StatusTooManyRequests && rec.Header().Get("Retry-After") == "" {
+			t.Error("429 without Retry-After")
+		}
+	}
+
+	// Reads don't spend the mutation budget
+	if rec := serveHandler(userByIDHandler, http.MethodGet, "/users/user-002", ""); rec.Code != http.StatusOK {
+		t.Errorf("read while mutations are limited: status = %d, want 200", rec.Code)
+	}
+}
+
+func TestStoreMutationRateUnlimitedByDefault(t *testing.T) {
+	useTestStore(t)
+	setForTest(t, &storeMutationLimiter, newStoreMutationLimiter(0, 0))
+
+	for i := 0; i < 20; i++ {
+		rec := serveHandler(userByIDHandler, http.MethodPatch, "/users/user-002", `{"role":"viewer"}`, "Content-Type", "application/merge-patch+json")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("mutation %d: status = %d, want 200", i+1, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+)
+
+// TestConcurrentReadsAndWrites drives every kind of read alongside creates,
+// updates and deletes. Run it with -race: any read of the store that skips
+// the lock shows up as a data race.
+func TestConcurrentReadsAndWrites(t *testing.T) {
+	useTestStore(t)
+	setForTest(t, &uniqueNames, true)
+
+	const writers = 8
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			id := fmt.Sprintf("race-%d", i)
+			body := fmt.Sprintf(`{"id":%q,"name":"Racer %d","email":"racer%d@example.com","role":"viewer"}`, id, i, i)
+			if rec := serveHandler(usersHandler, http.MethodPost, "/users", body, "Content-Type", "application/json"); rec.Code != http.StatusCreated {
+				t.Errorf("create %s: status = %d: %s", id, rec.Code, rec.Body)
+				return
+			}
+			serveHandler(userByIDHandler, http.MethodPatch, "/users/"+id, `{"role":"developer"}`, "Content-Type", "application/merge-patch+json")
+			serveHandler(userByIDHandler, http.MethodPatch, "/users/user-002", fmt.Sprintf(`{"name":"Bob %d"}`, i), "Content-Type", "application/merge-patch+json")
+			if i%2 == 0 {
+				serveHandler(userByIDHandler, http.MethodDelete, "/users/"+id+"?hard=true", "")
+			}
+		}(i)
+		go func(i int) {
+			defer wg.Done()
+			for _, path := range []string{"/users", "/users?role=viewer&q=racer", "/users?sort=-created_at&limit=2", "/users?ids=user-001,race-1"} {
+				if rec := serveHandler(usersHandler, http.MethodGet, path, ""); rec.Code != http.StatusOK {
+					t.Errorf("GET %s: status = %d", path, rec.Code)
+				}
+			}
+			serveHandler(userByIDHandler, http.MethodGet, "/users/user-002", "")
+			serveHandler(userByIDHandler, http.MethodGet, fmt.Sprintf("/users/race-%d", i), "")
+		}(i)
+	}
+	wg.Wait()
+
+	resp := listUsersForTest(t, "limit=100")
+	if want := 3 + writers/2; resp.Pagination == nil || resp.Pagination.Total != want {
+		t.Fatalf("%d users after the run, want %d", resp.Count, want)
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestStoreReturnsCopies(t *testing.T) {
+	store := useTestStore(t)
+	ctx := context.Background()
+
+	user, err := store.Get(ctx, "user-001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	user.Name = "Changed outside the lock"
+	list, err := store.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	list[1].Role = "admin"
+
+	if stored, _ := store.Get(ctx, "user-001"); stored.Name != "Alice Johnson" {
+		t.Errorf("changing a returned user changed the store: %q", stored.Name)
+	}
+	if stored, _ := store.Get(ctx, "user-002"); stored.Role != "developer" {
+		t.Errorf("changing a returned list changed the store: %q", stored.Role)
+	}
+}
+
+func TestGetUserByIDReturnsThatUser(t *testing.T) {
+	useTestStore(t)
+
+	for _, id := range []string{"user-001", "user-002", "user-003"} {
+		rec := serveHandler(userByIDHandler, http.MethodGet, "/users/"+id, "")
+		var resp UsersResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || resp.User == nil {
+			t.Fatalf("GET /users/%s: %v %s", id, err, rec.Body)
+		}
+		if resp.User.ID != id {
+			t.Errorf("GET /users/%s returned %s", id, resp.User.ID)
+		}
+	}
+}
+
+func TestFindUserHidesSoftDeleted(t *testing.T) {
+	store := useTestStore(t)
+	ctx := context.Background()
+	if _, err := store.SetDeleted(ctx, "user-003", true); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := findUser(ctx, "user-003"); !errors.Is(err, errUserNotFound) {
+		t.Errorf("findUser(soft-deleted) err = %v, want errUserNotFound", err)
+	}
+	if _, err := findUser(ctx, "user-404"); !errors.Is(err, errUserNotFound) {
+		t.Errorf("findUser(missing) err = %v, want errUserNotFound", err)
+	}
+	if user, err := store.Get(ctx, "user-003"); err != nil || !user.Deleted {
+		t.Errorf("Get(soft-deleted) = %+v, %v; want the deleted record", user, err)
+	}
+}
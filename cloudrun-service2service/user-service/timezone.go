@@ -0,0 +1,114 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	// The runtime image (alpine) has no zoneinfo database, so embed one
+	_ "time/tzdata"
+)
+
+// Response timezones.
+// Timestamps are stored in UTC, so every deployment returns the same times
+// whatever the server's local zone. Clients can have user timestamps
+// rendered in an IANA timezone instead with ?tz=Europe/Paris or an
+// X-Timezone header (the query parameter wins); an unknown zone gets 400.
+
+// timezoneHeader names the request header that picks a response timezone
+const timezoneHeader = "X-Timezone"
+
+// timezoneWriter carries a request's response timezone to writeJSON
+type timezoneWriter struct {
+	http.ResponseWriter
+	loc *time.Location
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (tw *timezoneWriter) Unwrap() http.ResponseWriter {
+	return tw.ResponseWriter
+}
+
+// Flush passes flushes through so NDJSON listings stay streamed
+func (tw *timezoneWriter) Flush() {
+	if flusher, ok := tw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// parseTimezone loads an IANA timezone name. "Local" is refused: it's
+// whatever zone the server runs in, which is what clients are avoiding.
+func parseTimezone(name string) (*time.Location, bool) {
+	if name == "Local" {
+		return nil, false
+	}
+	loc, err := time.LoadLocation(name)
+	return loc, err == nil
+}
+
+// withResponseTimezone reads ?tz or X-Timezone, rejecting unknown zones with
+// 400 and passing the zone on to writeJSON
+func withResponseTimezone(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimSpace(r.URL.Query().Get("tz"))
+		if name == "" {
+			name = strings.TrimSpace(r.Header.Get(timezoneHeader))
+		}
+		if name == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		loc, ok := parseTimezone(name)
+		if !ok {
+			writeError(w, r, http.StatusBadRequest, "timezone_invalid", name)
+			return
+		}
+		w.Header().Add("Vary", timezoneHeader)
+		next.ServeHTTP(&timezoneWriter{ResponseWriter: w, loc: loc}, r)
+	})
+}
+
+// responseTimezoneOf returns the timezone negotiated for the request w
+// responds to, or nil for UTC
+func responseTimezoneOf(w http.ResponseWriter) *time.Location {
+	if tw := findResponseWriter[*timezoneWriter](w); tw != nil {
+		return tw.loc
+	}
+	return nil
+}
+
+// zonedBody returns data with its users' timestamps in the timezone
+// negotiated for the request w responds to
+func zonedBody(w http.ResponseWriter, data interface{}) interface{} {
+	loc := responseTimezoneOf(w)
+	if loc == nil {
+		return data
+	}
+	return mapResponseUsers(data, func(user User) User { return zonedUser(user, loc) })
+}
+
+// zonedUser returns user with its timestamps in loc
+func zonedUser(user User, loc *time.Location) User {
+	user.CreatedAt = user.CreatedAt.In(loc)
+	user.UpdatedAt = zonedTime(user.UpdatedAt, loc)
+	user.LastAccessedAt = zonedTime(user.LastAccessedAt, loc)
+	user.DeletedAt = zonedTime(user.DeletedAt, loc)
+	return user
+}
+
+func zonedTime(t *time.Time, loc *time.Location) *time.Time {
+	if t == nil {
+		return nil
+	}
+	zoned := t.In(loc)
+	return &zoned
+}
+
+// utcTime returns t in UTC, or nil
+func utcTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	utc := t.UTC()
+	return &utc
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// tokenRefreshMargin is how long before expiry a cached ID token is refreshed
+const tokenRefreshMargin = 60 * time.Second
+
+// cachedToken is an ID token and the expiry from its "exp" claim
+type cachedToken struct {
+	token  string
+	expiry time.Time
+}
+
+// idTokenCache holds ID tokens per audience. Metadata-server tokens are valid
+// for about an hour, so fetching one per outbound call is wasted latency.
+// Different target services need different audiences, hence the per-audience key.
+var idTokenCache = struct {
+	sync.Mutex
+	tokens map[string]cachedToken
+}{tokens: make(map[string]cachedToken)}
+
+// cachedIDToken returns a cached token for audience if it isn't close to expiring
+func cachedIDToken(audience string) (string, bool) {
+	idTokenCache.Lock()
+	defer idTokenCache.Unlock()
+
+	cached, ok := idTokenCache.tokens[audience]
+	if !ok || time.Until(cached.expiry) < tokenRefreshMargin {
+		return "", false
+	}
+	return cached.token, true
+}
+
+// evictIDToken drops the cached token for audience, so the next call fetches
+// a fresh one
+func evictIDToken(audience string) {
+	idTokenCache.Lock()
+	defer idTokenCache.Unlock()
+	delete(idTokenCache.tokens, audience)
+}
+
+// storeIDToken caches token for audience using the expiry in its "exp" claim.
+// Tokens whose expiry can't be read are not cached.
+func storeIDToken(audience, token string) {
+	var claims struct {
+		Exp int64 `json:"exp"`
+	}
+	if err := decodeJWTPayload(token, &claims); err != nil || claims.Exp == 0 {
+		logger.Debug("Not caching ID token: no readable exp claim", "audience", audience)
+		return
+	}
+
+	idTokenCache.Lock()
+	defer idTokenCache.Unlock()
+	idTokenCache.tokens[audience] = cachedToken{
+		token:  token,
+		expiry: time.Unix(claims.Exp, 0),
+	}
+}
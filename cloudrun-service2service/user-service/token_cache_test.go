@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestIDTokensCachedPerAudience(t *testing.T) {
+	var minted atomic.Int32
+	useMetadataServer(t, func(audience string) string {
+		minted.Add(1)
+		return testIDToken(map[string]interface{}{"aud": audience})
+	})
+	t.Cleanup(func() {
+		evictIDToken("https://a.run.app")
+		evictIDToken("https://b.run.app")
+	})
+
+	ctx := context.Background()
+	first, source, err := getIDToken(ctx, "https://a.run.app")
+	if err != nil || source != "metadata" {
+		t.Fatalf("first token: source %q, err %v; want a metadata token", source, err)
+	}
+	again, source, _ := getIDToken(ctx, "https://a.run.app")
+	if source != "cache" || again != first {
+		t.Errorf("second token: source %q, want the cached one", source)
+	}
+	if _, source, _ := getIDToken(ctx, "https://b.run.app"); source != "metadata" {
+		t.Errorf("other audience: source %q, want its own metadata token", source)
+	}
+	if minted.Load() != 2 {
+		t.Errorf("metadata server minted %d tokens, want 2", minted.Load())
+	}
+}
+
+func TestIDTokensRefreshedBeforeExpiry(t *testing.T) {
+	var minted atomic.Int32
+	useMetadataServer(t, func(audience string) string {
+		minted.Add(1)
+		// Inside the refresh margin, so never served from the cache
+		return testIDToken(map[string]interface{}{"exp": time.Now().Add(tokenRefreshMargin / 2).Unix()})
+	})
+	t.Cleanup(func() { evictIDToken("https://a.run.app") })
+
+	for i := 0; i < 2; i++ {
+		if _, source, err := getIDToken(context.Background(), "https://a.run.app"); err != nil || source != "metadata" {
+			t.Fatalf("token %d: source %q, err %v; want a fresh one", i+1, source, err)
+		}
+	}
+	if minted.Load() != 2 {
+		t.Errorf("metadata server minted %d tokens, want 2", minted.Load())
+	}
+}
+
+func TestIDTokensWithoutExpiryNotCached(t *testing.T) {
+	storeIDToken("https://a.run.app", "not-a-jwt")
+	t.Cleanup(func() { evictIDToken("https://a.run.app") })
+	if _, ok := cachedIDToken("https://a.run.app"); ok {
+		t.Fatal("a token without a readable exp was cached")
+	}
+}
+
+func TestOutboundCallsReuseCachedToken(t *testing.T) {
+	var minted atomic.Int32
+	useMetadataServer(t, func(audience string) string {
+		minted.Add(1)
+		return testIDToken(map[string]interface{}{"aud": audience})
+	})
+	backend := newBackend(t, func(w http.ResponseWriter, r *http.Request) {})
+	t.Cleanup(func() { evictIDToken(backend.URL) })
+
+	for i := 0; i < 3; i++ {
+		if _, err := makeAuthenticatedGet(context.Background(), backend.URL+"/health"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if minted.Load() != 1 {
+		t.Errorf("metadata server minted %d tokens for 3 calls, want 1", minted.Load())
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestIDTokenFetchStopsWhenCallerGivesUp(t *testing.T) {
+	setForTest(t, &impersonateServiceAccount, "")
+	// A metadata server that never answers
+	setForTest(t, &metadataClient, &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		<-req.Context().Done()
+		return nil, req.Context().Err()
+	})})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, _, err := fetchIDToken(ctx, "https://orders.run.app")
+	// Without the check, the access token fallback would run after the caller's deadline
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want the caller's deadline", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("fetch took %v after a 50ms deadline", elapsed)
+	}
+}
+
+func TestIDTokenFetchCancelled(t *testing.T) {
+	setForTest(t, &impersonateServiceAccount, "")
+	ctx, cancel := context.WithCancel(context.Background())
+	setForTest(t, &metadataClient, &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		cancel()
+		<-req.Context().Done()
+		return nil, req.Context().Err()
+	})})
+
+	if _, _, err := fetchIDToken(ctx, "https://orders.run.app"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want context.Canceled", err)
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// tokenRecorder is a backend that rejects the tokens accept refuses with
+// status, recording the token of every call
+type tokenRecorder struct {
+	mu     sync.Mutex
+	tokens []string
+	accept func(token string) bool
+	status int
+}
+
+func (tr *tokenRecorder) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	tr.mu.Lock()
+	tr.tokens = append(tr.tokens, token)
+	tr.mu.Unlock()
+	if !tr.accept(token) {
+		http.Error(w, "token rejected", tr.status)
+		return
+	}
+	w.Write([]byte(`{}`))
+}
+
+func (tr *tokenRecorder) calls() []string {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	return append([]string(nil), tr.tokens...)
+}
+
+// useFreshTokens serves metadata server tokens that differ from any cached
+// in the test, counting how many are minted
+func useFreshTokens(t *testing.T) *int {
+	t.Helper()
+	minted := 0
+	setForTest(t, &impersonateServiceAccount, "")
+	useMetadataServer(t, func(audience string) string {
+		minted++
+		return testIDToken(map[string]interface{}{"aud": audience, "fresh": minted})
+	})
+	return &minted
+}
+
+func TestRejectedTokenRefreshedAndRetried(t *testing.T) {
+	for _, status := range []int{http.StatusUnauthorized, http.StatusForbidden} {
+		t.Run(http.StatusText(status), func(t *testing.T) {
+			recorder := &tokenRecorder{status: status}
+			backend := newBackend(t, recorder.serveHTTP)
+			stale := useCachedIDToken(t, backend.URL)
+			recorder.accept = func(token string) bool { return token != stale }
+			minted := useFreshTokens(t)
+
+			// A POST without an idempotency key: the backend refused it
+			// before doing anything, so it's safe to send again
+			got, _, err := makeAuthenticatedRequest(context.Background(), http.MethodPost, backend.URL+"/orders", strings.NewReader(`{}`), nil)
+			if err != nil || got != http.StatusOK {
+				t.Fatalf("status = %d, err = %v; want 200 after refreshing the token", got, err)
+			}
+			calls := recorder.calls()
+			if len(calls) != 2 || calls[0] != stale || *minted != 1 {
+				t.Fatalf("backend saw %d calls after %d tokens were minted, want the stale token then one fresh one", len(calls), *minted)
+			}
+			// The fresh token replaces the rejected one in the cache
+			if token, ok := cachedIDToken(backend.URL); !ok || token != calls[1] {
+				t.Error("the fresh token wasn't cached")
+			}
+		})
+	}
+}
+
+func TestRejectedTokenRetriedOnlyOnce(t *testing.T) {
+	setForTest(t, &outboundMaxRetries, 3)
+	recorder := &tokenRecorder{status: http.StatusUnauthorized, accept: func(string) bool { return false }}
+	backend := newBackend(t, recorder.serveHTTP)
+	useCachedIDToken(t, backend.URL)
+	minted := useFreshTokens(t)
+
+	status, _, err := makeAuthenticatedRequest(context.Background(), http.MethodGet, backend.URL+"/orders", nil, nil)
+	if err == nil || status != http.StatusUnauthorized {
+		t.Fatalf("status = %d, err = %v; want the 401", status, err)
+	}
+	// One refresh, outside OUTBOUND_MAX_RETRIES, and no more
+	if calls := len(recorder.calls()); calls != 2 || *minted != 1 {
+		t.Errorf("backend called %d times with %d tokens minted, want 2 and 1", calls, *minted)
+	}
+}
+
+func TestRejectedHMACSignatureNotRetried(t *testing.T) {
+	var calls int
+	backend := newBackend(t, func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		http.Error(w, "bad signature", http.StatusUnauthorized)
+	})
+	setForTest(t, &outboundAuthModes, map[string]string{backend.URL: authModeHMAC})
+	setForTest(t, &outboundHMACSecret, "secret")
+
+	if _, _, err := makeAuthenticatedRequest(context.Background(), http.MethodPost, backend.URL+"/events", strings.NewReader(`{}`), nil); err == nil {
+		t.Fatal("err = nil, want the 401")
+	}
+	if calls != 1 {
+		t.Errorf("backend called %d times, want 1: a new signature wouldn't fare better", calls)
+	}
+}
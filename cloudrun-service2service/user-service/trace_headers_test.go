@@ -0,0 +1,46 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestOutboundCallsForwardTraceHeaders(t *testing.T) {
+	useTestStore(t)
+	received := make(chan http.Header, 1)
+	useOrderService(t, func(w http.ResponseWriter, r *http.Request) {
+		received <- r.Header.Clone()
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(testOrdersJSON("user-001")))
+	})
+	server := newTestServer(t)
+
+	const (
+		cloudTrace  = "105445aa7843bc8bf206b12000100000/1;o=1"
+		traceparent = "00-105445aa7843bc8bf206b12000100000-00f067aa0ba902b7-01"
+	)
+	resp, body := doRequest(t, server, http.MethodGet, "/users/user-001/orders", "",
+		"X-Cloud-Trace-Context", cloudTrace, "Traceparent", traceparent, "Tracestate", "vendor=value")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", resp.StatusCode, body)
+	}
+
+	headers := <-received
+	for name, want := range map[string]string{
+		"X-Cloud-Trace-Context": cloudTrace,
+		"Traceparent":           traceparent,
+		"Tracestate":            "vendor=value",
+	} {
+		if got := headers.Get(name); got != want {
+			t.Errorf("outbound %s = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestWithTraceHeadersWithoutTrace(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, "http://order-service/", nil)
+	propagateTraceHeaders(withTraceHeaders(req.Context(), http.Header{"Accept": {"*/*"}}), req)
+	if len(req.Header) != 0 {
+		t.Errorf("outbound headers = %v, want none without incoming trace headers", req.Header)
+	}
+}
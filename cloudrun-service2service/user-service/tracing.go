@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	texporter "github.com/GoogleCloudPlatform/opentelemetry-operations-go/exporter/trace"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Tracing configuration.
+// TRACE_EXPORTER selects where OpenTelemetry spans go: "none" (the default),
+// "otlp" (OTLP over HTTP to OTEL_EXPORTER_OTLP_ENDPOINT, e.g. a collector
+// sidecar) or "gcp" (straight to Cloud Trace with the service's credentials).
+// TRACE_SAMPLE_RATIO is the fraction of new traces recorded; requests that
+// arrive with a traceparent follow the caller's sampling decision.
+var (
+	traceExporter    = strings.ToLower(envString("TRACE_EXPORTER", "none"))
+	traceSampleRatio = envFloat("TRACE_SAMPLE_RATIO", 1)
+)
+
+// tracer creates this service's spans. It delegates to whatever provider
+// setupTracing installs, and records nothing until then.
+var tracer = otel.Tracer("user-service")
+
+// tracingEnabled reports whether spans are exported
+func tracingEnabled() bool {
+	return traceExporter != "none"
+}
+
+// setupTracing installs the tracer provider for TRACE_EXPORTER and the W3C
+// trace context propagator. The returned function flushes buffered spans and
+// must be called on shutdown.
+func setupTracing(ctx context.Context) (func(context.Context) error, error) {
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch traceExporter {
+	case "none":
+		return func(context.Context) error { return nil }, nil
+	case "otlp":
+		exporter, err = otlptracehttp.New(ctx)
+	case "gcp":
+		exporter, err = texporter.New()
+	default:
+		return nil, fmt.Errorf("unknown TRACE_EXPORTER %q (want none, otlp or gcp)", traceExporter)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s trace exporter: %v", traceExporter, err)
+	}
+
+	res, err := traceResource()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build trace resource: %v", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(traceSampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	return provider.Shutdown, nil
+}
+
+// traceResource describes this service on every span, with the version it
+// was built as (see version.go)
+func traceResource() (*resource.Resource, error) {
+	return resource.Merge(resource.Default(), resource.NewSchemaless(
+		attribute.String("service.name", "user-service"),
+		attribute.String("service.version", version),
+	))
+}
+
+// traceRequests is a middleware that starts a server span for each request,
+// continuing the caller's trace from its traceparent header
+func traceRequests(handler http.Handler) http.Handler {
+	if !tracingEnabled() {
+		return handler
+	}
+	return otelhttp.NewHandler(handler, "user-service",
+		// Paths contain user IDs, so spans are named by method; the handler
+		// span below carries the route
+		otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+			return "HTTP " + r.Method
+		}),
+	)
+}
+
+// withRouteSpan wraps a route's handler in a span named after its pattern
+func withRouteSpan(pattern string, handler http.HandlerFunc) http.HandlerFunc {
+	if !tracingEnabled() {
+		return handler
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, span := tracer.Start(r.Context(), "handler "+pattern,
+			trace.WithAttributes(attribute.String("http.route", pattern)))
+		defer span.End()
+		handler(w, r.WithContext(ctx))
+	}
+}
+
+// startOutboundSpan starts a client span for a call to backend. Pass the
+// call's error to the returned function to end the span.
+func startOutboundSpan(ctx context.Context, method, url, backend string) (context.Context, func(error)) {
+	ctx, span := tracer.Start(ctx, method+" "+backend,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("http.request.method", method),
+			attribute.String("url.full", url),
+			attribute.String("server.address", strings.TrimPrefix(strings.TrimPrefix(backend, "https://"), "http://")),
+		))
+	return ctx, func(err error) {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(otelcodes.Error, err.Error())
+		}
+		span.End()
+	}
+}
+
+// injectTraceContext sets traceparent (and tracestate) on an outbound request
+// from the current span, so the downstream service's spans join this trace.
+// It's a no-op unless tracing is enabled.
+func injectTraceContext(ctx context.Context, req *http.Request) {
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+}
@@ -0,0 +1,19 @@
+package main
+
+import (
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+func TestTraceResourceReportsBuildVersion(t *testing.T) {
+	setForTest(t, &version, "1.4.0")
+
+	res, err := traceResource()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := res.Set().Value(attribute.Key("service.version")); got.AsString() != "1.4.0" {
+		t.Errorf("service.version = %q, want the build version 1.4.0", got.AsString())
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestUniqueNamesOffByDefault(t *testing.T) {
+	useTestStore(t)
+	setForTest(t, &uniqueNames, false)
+
+	createUserForTest(t, `{"name":"Alice Johnson","email":"alice2@example.com","role":"viewer"}`, http.StatusCreated)
+}
+
+func TestUniqueNamesOnCreate(t *testing.T) {
+	useTestStore(t)
+	setForTest(t, &uniqueNames, true)
+	setForTest(t, &uniqueNamesIgnoreCase, true)
+
+	rec := serveHandler(usersHandler, http.MethodPost, "/users", `{"name":"alice JOHNSON","email":"alice2@example.com"}`, "Content-Type", "application/json")
+	if rec.Code != http.StatusConflict || !strings.Contains(rec.Body.String(), `"code":"name_taken"`) {
+		t.Fatalf("status = %d, body %s; want 409 name_taken", rec.Code, rec.Body)
+	}
+
+	setForTest(t, &uniqueNamesIgnoreCase, false)
+	createUserForTest(t, `{"name":"alice JOHNSON","email":"alice2@example.com","role":"viewer"}`, http.StatusCreated)
+}
+
+func TestUniqueNamesOnUpdate(t *testing.T) {
+	useTestStore(t)
+	setForTest(t, &uniqueNames, true)
+
+	rec := serveHandler(userByIDHandler, http.MethodPatch, "/users/user-002", `{"name":"Carol Williams"}`, "Content-Type", "application/merge-patch+json")
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("renaming to a taken name: status = %d, want 409: %s", rec.Code, rec.Body)
+	}
+
+	// Keeping your own name isn't a conflict
+	rec = serveHandler(userByIDHandler, http.MethodPatch, "/users/user-002", `{"name":"Bob Smith","role":"admin"}`, "Content-Type", "application/merge-patch+json")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("keeping the same name: status = %d, want 200: %s", rec.Code, rec.Body)
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// UpstreamError reports one failed dependency call with a stable code, so a
+// response that aggregates several dependencies can say what went wrong with
+// each of them instead of only the first failure
+type UpstreamError struct {
+	Dependency string `json:"dependency"`
+	// Code is one of circuit_open, backend_busy, timeout, cancelled,
+	// upstream_status, bad_response or unreachable
+	Code string `json:"code"`
+	// Status is the dependency's HTTP status for upstream_status errors
+	Status int    `json:"status,omitempty"`
+	Error  string `json:"error"`
+}
+
+// errBadUpstreamResponse marks a dependency response we couldn't use
+// (unparseable or failing its contract)
+var errBadUpstreamResponse = errors.New("unexpected response")
+
+// newUpstreamError classifies err from a call to dependency. Messages only
+// include the error's detail when ERROR_VERBOSITY=debug, since upstream bodies
+// can leak internals.
+func newUpstreamError(dependency string, err error) UpstreamError {
+	upstreamErr := UpstreamError{Dependency: dependency}
+
+	var openErr *circuitOpenError
+	var statusErr *statusError
+	switch {
+	case errors.As(err, &openErr):
+		upstreamErr.Code = "circuit_open"
+		upstreamErr.Error = dependency + " is unavailable (circuit open)"
+	case errors.Is(err, errBackendBusy):
+		upstreamErr.Code = "backend_busy"
+		upstreamErr.Error = dependency + " is at its concurrency limit"
+	case errors.Is(err, context.Canceled):
+		upstreamErr.Code = "cancelled"
+		upstreamErr.Error = dependency + " call was cancelled"
+	case isTimeout(err):
+		upstreamErr.Code = "timeout"
+		upstreamErr.Error = dependency + " timed out"
+	case errors.As(err, &statusErr):
+		upstreamErr.Code = "upstream_status"
+		upstreamErr.Status = statusErr.StatusCode
+		upstreamErr.Error = clientErrorMessage(fmt.Sprintf("%s returned HTTP %d", dependency, statusErr.StatusCode), err)
+	case errors.Is(err, errBadUpstreamResponse):
+		upstreamErr.Code = "bad_response"
+		upstreamErr.Error = clientErrorMessage(dependency+" returned an unexpected response", err)
+	default:
+		upstreamErr.Code = "unreachable"
+		upstreamErr.Error = clientErrorMessage(dependency+" could not be reached", err)
+	}
+	return upstreamErr
+}
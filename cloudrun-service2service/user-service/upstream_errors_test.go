@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestNewUpstreamErrorCodes(t *testing.T) {
+	for _, tc := range []struct {
+		err  error
+		want string
+	}{
+		{&circuitOpenError{Backend: "https://orders.run.app", RetryAfter: time.Second}, "circuit_open"},
+		{fmt.Errorf("acquire: %w", errBackendBusy), "backend_busy"},
+		{fmt.Errorf("request failed: %w", context.Canceled), "cancelled"},
+		{fmt.Errorf("request failed: %w", context.DeadlineExceeded), "timeout"},
+		{&statusError{StatusCode: http.StatusServiceUnavailable}, "upstream_status"},
+		{fmt.Errorf("%w: missing orders", errBadUpstreamResponse), "bad_response"},
+		{&net.OpError{Op: "dial", Err: errors.New("connection refused")}, "unreachable"},
+	} {
+		got := newUpstreamError("order-service", tc.err)
+		if got.Code != tc.want || got.Dependency != "order-service" || got.Error == "" {
+			t.Errorf("newUpstreamError(%v) = %+v, want code %s", tc.err, got, tc.want)
+		}
+	}
+
+	if got := newUpstreamError("order-service", &statusError{StatusCode: http.StatusTeapot}); got.Status != http.StatusTeapot {
+		t.Errorf("status = %d, want the dependency's 418", got.Status)
+	}
+}
+
+func TestMeshHealthReportsEveryFailure(t *testing.T) {
+	setForTest(t, &meshHealthCacheTTL, 0)
+	setForTest(t, &outboundMaxRetries, 0)
+	keepOrderServiceURL(t)
+	setOrderServiceURL("")
+	billing := newBackend(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "down", http.StatusInternalServerError)
+	})
+	audit := newBackend(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<html>not json</html>"))
+	})
+	useCachedIDToken(t, billing.URL)
+	useCachedIDToken(t, audit.URL)
+	setForTest(t, &meshDependencies, []meshDependency{{Name: "billing", URL: billing.URL}, {Name: "audit", URL: audit.URL}})
+
+	rec := serveHandler(meshHealthHandler, http.MethodGet, "/mesh/health", "")
+	var resp MeshHealthResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	codes := make(map[string]string)
+	for _, upstreamErr := range resp.Errors {
+		codes[upstreamErr.Dependency] = upstreamErr.Code
+	}
+	if len(codes) != 2 || codes["billing"] != "upstream_status" || codes["audit"] != "bad_response" {
+		t.Fatalf("errors = %+v, want billing's upstream_status and audit's bad_response", resp.Errors)
+	}
+}
+
+func TestOrdersFailureListsUpstreamError(t *testing.T) {
+	useTestStore(t)
+	setForTest(t, &outboundMaxRetries, 0)
+	useOrderService(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"unexpected":true}`))
+	})
+	setForTest(t, &strictContract, true)
+
+	resp, body := doRequest(t, newTestServer(t), http.MethodGet, "/users/user-001/orders", "")
+	if resp.StatusCode < 500 {
+		t.Fatalf("status = %d, want a 5xx: %s", resp.StatusCode, body)
+	}
+	var errResp ErrorResponse
+	if err := json.Unmarshal([]byte(body), &errResp); err != nil {
+		t.Fatal(err)
+	}
+	if len(errResp.Errors) != 1 || errResp.Errors[0].Dependency != "order-service" || errResp.Errors[0].Code != "bad_response" {
+		t.Errorf("errors = %+v, want order-service's bad_response", errResp.Errors)
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestParseUserPathEscaped(t *testing.T) {
+	for _, tc := range []struct {
+		path, wantID, wantSubresource string
+	}{
+		{"/users/user%2D001", "user-001", ""},
+		// An encoded slash stays in the ID rather than splitting the path
+		{"/users/a%2Fb/orders", "a/b", "orders"},
+		{"/users/bad%0Aid", "bad\nid", ""},
+		// A malformed escape is left as is, to fail validation
+		{"/users/bad%zz", "bad%zz", ""},
+	} {
+		id, subresource, ok := parseUserPath(tc.path)
+		if id != tc.wantID || subresource != tc.wantSubresource || !ok {
+			t.Errorf("parseUserPath(%q) = %q, %q, %v; want %q, %q, true", tc.path, id, subresource, ok, tc.wantID, tc.wantSubresource)
+		}
+	}
+}
+
+func TestInvalidPathUserIDsRejected(t *testing.T) {
+	useTestStore(t)
+	useOrderService(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("invalid ID reached the Order Service: %s", r.URL.EscapedPath())
+	})
+	server := newTestServer(t)
+
+	for _, path := range []string{
+		"/users/a%2Fb",
+		"/users/a%2Fb/orders",
+		"/users/..%2Fadmin/orders",
+		"/users/bad%0D%0Aid/orders",
+		"/users/" + strings.Repeat("a", maxUserIDLength+1),
+	} {
+		resp, body := doRequest(t, server, http.MethodGet, path, "")
+		if resp.StatusCode != http.StatusBadRequest || !strings.Contains(body, `"code":"user_id_invalid"`) {
+			t.Errorf("GET %s: status = %d, want 400 user_id_invalid: %s", path, resp.StatusCode, body)
+		}
+	}
+}
+
+func TestRejectedUserIDsLoggedEscaped(t *testing.T) {
+	useTestStore(t)
+	logs := captureLogs(t, slog.LevelInfo)
+
+	resp, body := doRequest(t, newTestServer(t), http.MethodGet, "/users/forged%0A%7B%22severity%22%3A%22ERROR%22%7D", "")
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400: %s", resp.StatusCode, body)
+	}
+	for _, line := range strings.Split(strings.TrimSpace(logs.String()), "\n") {
+		if strings.HasPrefix(line, `{"severity":"ERROR"}`) {
+			t.Errorf("the ID forged a log line:\n%s", logs)
+		}
+	}
+	for _, want := range []string{"Rejected invalid user ID", `"path":"/users/forged%0A%7B%22severity%22%3A%22ERROR%22%7D"`} {
+		if !strings.Contains(logs.String(), want) {
+			t.Errorf("logs don't contain %s:\n%s", want, logs)
+		}
+	}
+}
+
+func TestLoggableUserID(t *testing.T) {
+	for _, tc := range []struct{ id, want string }{
+		{"user-001", `"user-001"`},
+		{"bad\nid", `"bad\nid"`},
+		{"a\x1b[31mb", `"a\x1b[31mb"`},
+		{"ü", `"\u00fc"`},
+		{strings.Repeat("a", 100), `"` + strings.Repeat("a", 64) + `"...`},
+	} {
+		if got := loggableUserID(tc.id); got != tc.want {
+			t.Errorf("loggableUserID(%q) = %s, want %s", tc.id, got, tc.want)
+		}
+	}
+}
+
+func TestOrderServiceURLEscapesUserID(t *testing.T) {
+	useTestStore(t)
+	setForTest(t, &userIDPattern, compileUserIDPattern(`^[a-z ]+$`))
+	createUserForTest(t, `{"id":"team a","name":"Team A","email":"team-a@example.com","role":"viewer"}`, http.StatusCreated)
+	var gotPath string
+	useOrderService(t, func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.EscapedPath()
+		w.Write([]byte(testOrdersJSON("team a")))
+	})
+
+	resp, body := doRequest(t, newTestServer(t), http.MethodGet, "/users/team%20a/orders", "")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", resp.StatusCode, body)
+	}
+	if gotPath != "/orders/user/team%20a" {
+		t.Errorf("Order Service path = %s, want /orders/user/team%%20a", gotPath)
+	}
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestUserOrdersMethodNotAllowed(t *testing.T) {
+	useTestStore(t)
+	server := newTestServer(t)
+
+	for _, method := range []string{http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete} {
+		resp, body := doRequest(t, server, method, "/users/user-001/orders", "{}", "Content-Type", "application/json")
+		if resp.StatusCode != http.StatusMethodNotAllowed {
+			t.Errorf("%s: status = %d, want 405", method, resp.StatusCode)
+			continue
+		}
+		if allow := resp.Header.Get("Allow"); allow != "GET, OPTIONS" {
+			t.Errorf("%s: Allow = %q, want \"GET, OPTIONS\"", method, allow)
+		}
+		if !strings.Contains(body, `"code":"method_not_allowed"`) {
+			t.Errorf("%s: body = %s, want a method_not_allowed error", method, body)
+		}
+	}
+}
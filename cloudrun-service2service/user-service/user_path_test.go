@@ -0,0 +1,53 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestParseUserPath(t *testing.T) {
+	for _, tc := range []struct {
+		path, wantID, wantSubresource string
+		wantOK                        bool
+	}{
+		{"/users/user-001", "user-001", "", true},
+		{"/users/user-001/", "user-001", "", true},
+		{"/users/user-001/orders", "user-001", "orders", true},
+		{"/users/user-001/orders/", "user-001", "orders", true},
+		// IDs are matched by whole segment, not substring
+		{"/users/orders-team", "orders-team", "", true},
+		{"/users/user-001/orders-archive", "", "", false},
+		{"/users/user-001/unknown", "", "", false},
+		{"/users/user-001/orders/extra", "", "", false},
+		{"/users/", "", "", true},
+	} {
+		id, subresource, ok := parseUserPath(tc.path)
+		if id != tc.wantID || subresource != tc.wantSubresource || ok != tc.wantOK {
+			t.Errorf("parseUserPath(%q) = %q, %q, %v; want %q, %q, %v", tc.path, id, subresource, ok, tc.wantID, tc.wantSubresource, tc.wantOK)
+		}
+	}
+}
+
+func TestUserPathRouting(t *testing.T) {
+	useTestStore(t)
+	createUserForTest(t, `{"id":"orders-team","name":"Orders Team","email":"orders@example.com","role":"viewer"}`, http.StatusCreated)
+	server := newTestServer(t)
+
+	// Once matched by substring, this was routed to the orders endpoint
+	if resp, body := doRequest(t, server, http.MethodGet, "/users/orders-team", ""); resp.StatusCode != http.StatusOK || !strings.Contains(body, `"name":"Orders Team"`) {
+		t.Errorf("GET /users/orders-team: status = %d, want the user: %s", resp.StatusCode, body)
+	}
+
+	for _, tc := range []struct {
+		path string
+		want int
+	}{
+		{"/users/user-001/unknown", http.StatusNotFound},
+		{"/users/user-001/orders/extra", http.StatusNotFound},
+	} {
+		if resp, body := doRequest(t, server, http.MethodGet, tc.path, ""); resp.StatusCode != tc.want {
+			t.Errorf("GET %s: status = %d, want %d: %s", tc.path, resp.StatusCode, tc.want, body)
+		}
+	}
+}
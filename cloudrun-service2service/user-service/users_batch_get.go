@@ -0,0 +1,105 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// USERS_BATCH_GET_MAX_IDS caps how many IDs GET /users?ids= may name, so one
+// request can't turn into an unbounded number of store reads
+var usersBatchGetMaxIDs = envInt("USERS_BATCH_GET_MAX_IDS", 100)
+
+// UsersByIDsResponse represents the response for GET /users?ids=
+type UsersByIDsResponse struct {
+	Service string `json:"service"`
+	Count   int    `json:"count"`
+	// Users are the users found, in the order their IDs were requested
+	Users []User `json:"users"`
+	// NotFound lists the requested IDs with no user, in request order
+	NotFound []string `json:"not_found"`
+	// Moved maps requested sequential IDs that have been migrated to the
+	// UUIDs of the users returned for them
+	Moved map[string]string `json:"moved,omitempty"`
+}
+
+// parseIDsParam reads the comma-separated ?ids= list, dropping blanks and
+// repeats and keeping the order of first appearance
+func parseIDsParam(r *http.Request) []string {
+	var ids []string
+	seen := make(map[string]bool)
+	for _, raw := range r.URL.Query()["ids"] {
+		for _, id := range strings.Split(raw, ",") {
+			id = strings.TrimSpace(id)
+			if id != "" && !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+	}
+	return ids
+}
+
+// getUsersByIDs handles GET /users?ids=a,b,c: the listed users in one
+// response, with the IDs that don't exist in not_found rather than failing
+// the request. Migrated sequential IDs resolve to their users, as GET
+// /users/{id} redirects them. Listing parameters (pagination, filters, sort)
+// don't apply.
+func getUsersByIDs(w http.ResponseWriter, r *http.Request) {
+	ids := parseIDsParam(r)
+	if len(ids) == 0 {
+		writeError(w, r, http.StatusBadRequest, "ids_param_required")
+		return
+	}
+	if len(ids) > usersBatchGetMaxIDs {
+		writeError(w, r, http.StatusBadRequest, "batch_too_large", len(ids), usersBatchGetMaxIDs)
+		return
+	}
+	for _, id := range ids {
+		if err := validateUserID(id); err != nil {
+			writeError(w, r, http.StatusBadRequest, "user_id_invalid", loggableUserID(id), userIDPattern.String(), maxUserIDLength)
+			return
+		}
+	}
+
+	response := UsersByIDsResponse{
+		Service:  "user-service (Go)",
+		Users:    []User{},
+		NotFound: []string{},
+	}
+	now := time.Now()
+	returned := make(map[string]bool)
+	for _, id := range ids {
+		user, err := lookupUser(r, id)
+		if newID, ok := migratedID(id); ok && errors.Is(err, errUserNotFound) {
+			user, err = lookupUser(r, newID)
+			if err == nil {
+				if response.Moved == nil {
+					response.Moved = make(map[string]string)
+				}
+				response.Moved[id] = newID
+			}
+		}
+		if errors.Is(err, errUserNotFound) {
+			response.NotFound = append(response.NotFound, id)
+			continue
+		}
+		if err != nil {
+			writeStoreFailure(w, r, err)
+			return
+		}
+		// A user asked for by both its old and new ID is returned once
+		if returned[user.ID] {
+			continue
+		}
+		returned[user.ID] = true
+		recordAccess(user.ID, now)
+		if includeAccess(r) {
+			user.LastAccessedAt = lastAccessedAt(user.ID)
+		}
+		response.Users = append(response.Users, user)
+	}
+	response.Count = len(response.Users)
+	writeJSON(w, http.StatusOK, response)
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func getUsersByIDsForTest(t *testing.T, query string) UsersByIDsResponse {
+	t.Helper()
+	rec := serveHandler(getAllUsers, http.MethodGet, "/users?"+query, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body)
+	}
+	var resp UsersByIDsResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func TestGetUsersByIDs(t *testing.T) {
+	useTestStore(t)
+
+	resp := getUsersByIDsForTest(t, "ids=user-002,user-404,user-001,user-002")
+	if resp.Count != 2 || len(resp.Users) != 2 || resp.Users[0].ID != "user-002" || resp.Users[1].ID != "user-001" {
+		t.Fatalf("users = %+v, want user-002 then user-001", resp.Users)
+	}
+	if len(resp.NotFound) != 1 || resp.NotFound[0] != "user-404" {
+		t.Errorf("not_found = %v, want [user-404]", resp.NotFound)
+	}
+}
+
+func TestGetUsersByIDsResolvesMigratedIDs(t *testing.T) {
+	useTestStore(t)
+	useIDMigration(t)
+
+	rec := serveHandler(idMigrationHandler, http.MethodPost, "/admin/migrate/ids", "", "X-Admin-Token", "test-admin-token")
+	var migration IDMigrationResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &migration); err != nil {
+		t.Fatal(err)
+	}
+	aliceID := migration.Mappings["user-001"]
+
+	// The old and new IDs name the same user, who is returned once
+	resp := getUsersByIDsForTest(t, "ids=user-001,"+aliceID+",user-404")
+	if resp.Count != 1 || resp.Users[0].ID != aliceID {
+		t.Fatalf("users = %+v, want only %s", resp.Users, aliceID)
+	}
+	if resp.Moved["user-001"] != aliceID {
+		t.Errorf("moved = %v, want user-001 -> %s", resp.Moved, aliceID)
+	}
+	if len(resp.NotFound) != 1 || resp.NotFound[0] != "user-404" {
+		t.Errorf("not_found = %v, want [user-404]", resp.NotFound)
+	}
+}
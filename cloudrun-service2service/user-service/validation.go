@@ -0,0 +1,264 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/mail"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/text/language"
+)
+
+// defaultUserIDPattern keeps IDs to characters that are safe in a single path segment
+const defaultUserIDPattern = `^[A-Za-z0-9_-]+$`
+
+// userIDPattern is the format client-supplied user IDs must match (USER_ID_PATTERN)
+var userIDPattern = compileUserIDPattern(envString("USER_ID_PATTERN", defaultUserIDPattern))
+
+func compileUserIDPattern(pattern string) *regexp.Regexp {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		logger.Warn("Invalid USER_ID_PATTERN, using default", "value", pattern, "error", err, "default", defaultUserIDPattern)
+		return regexp.MustCompile(defaultUserIDPattern)
+	}
+	return re
+}
+
+// maxUserIDLength bounds user IDs, whatever USER_ID_PATTERN allows
+const maxUserIDLength = 128
+
+// reservedUserIDs are /users/ subpaths served by their own routes, so a user
+// with one of these IDs couldn't be reached at /users/{id}
+var reservedUserIDs = map[string]bool{"count": true}
+
+// validateUserID rejects IDs that would break /users/{id} path routing
+// (slashes, spaces, etc.) or otherwise don't match the configured format
+func validateUserID(id string) error {
+	if len(id) > maxUserIDLength || !userIDPattern.MatchString(id) {
+		return fmt.Errorf("User ID %s is invalid: must match %s and be at most %d characters", loggableUserID(id), userIDPattern.String(), maxUserIDLength)
+	}
+	if reservedUserIDs[id] {
+		return fmt.Errorf("User ID %s is reserved", loggableUserID(id))
+	}
+	return nil
+}
+
+// loggableUserID quotes an unvalidated ID for logs and error messages, with
+// control characters escaped (so it can't forge log lines) and long IDs cut short
+func loggableUserID(id string) string {
+	const max = 64
+	if len(id) > max {
+		return strconv.QuoteToASCII(id[:max]) + "..."
+	}
+	return strconv.QuoteToASCII(id)
+}
+
+// warningRule flags input that is valid but suspicious. It returns a
+// human-readable warning, or "" when the input looks fine.
+type warningRule func(user User) string
+
+// warningRules are the available non-fatal checks, keyed by the name used in VALIDATION_WARNINGS
+var warningRules = map[string]warningRule{
+	// Mailboxes like admin@ or noreply@ usually aren't a person's address
+	"role_email": func(user User) string {
+		local, _, _ := strings.Cut(strings.ToLower(user.Email), "@")
+		switch local {
+		case "admin", "administrator", "root", "support", "info", "noreply", "no-reply", "postmaster", "webmaster":
+			return fmt.Sprintf("email '%s' looks like a role mailbox rather than a personal address", user.Email)
+		}
+		return ""
+	},
+	"missing_role": func(user User) string {
+		if user.Role == "" {
+			return "role is empty - the user will have no permissions"
+		}
+		return ""
+	},
+	"name_is_email": func(user User) string {
+		if strings.Contains(user.Name, "@") {
+			return fmt.Sprintf("name '%s' looks like an email address", user.Name)
+		}
+		return ""
+	},
+	"name_whitespace": func(user User) string {
+		if user.Name != strings.TrimSpace(user.Name) {
+			return "name has leading or trailing whitespace"
+		}
+		return ""
+	},
+}
+
+// enabledWarningRules lists the rules to run (VALIDATION_WARNINGS, comma-separated
+// rule names, or "none"); all rules are enabled by default
+var enabledWarningRules = parseWarningRules(envString("VALIDATION_WARNINGS", "all"))
+
+// userWarnings runs the enabled warning rules against user
+func userWarnings(ctx context.Context, user User) []string {
+	if !featureEnabled(ctx, "warnings", true) {
+		return nil
+	}
+
+	var warnings []string
+	for _, name := range enabledWarningRules {
+		if warning := warningRules[name](user); warning != "" {
+			warnings = append(warnings, warning)
+		}
+	}
+	return warnings
+}
+
+func parseWarningRules(raw string) []string {
+	raw = strings.TrimSpace(strings.ToLower(raw))
+	if raw == "none" {
+		return nil
+	}
+
+	var names []string
+	if raw == "all" {
+		for name := range warningRules {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return names
+	}
+
+	for _, name := range strings.Split(raw, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if _, ok := warningRules[name]; !ok {
+			logger.Warn("Ignoring unknown validation warning rule", "rule", name)
+			continue
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+// Unique name configuration (UNIQUE_NAMES, UNIQUE_NAMES_IGNORE_CASE).
+// Names are not unique by default.
+var (
+	uniqueNames           = envBool("UNIQUE_NAMES", false)
+	uniqueNamesIgnoreCase = envBool("UNIQUE_NAMES_IGNORE_CASE", true)
+)
+
+// nameTaken reports whether a user in list (other than excludeID) already has
+// name. Stores call it under their write lock so the check and write are atomic.
+func nameTaken(list []User, name, excludeID string) bool {
+	for _, user := range list {
+		if user.ID != excludeID && sameName(user.Name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowedRoles lists the roles a user may be given, in the order shown in error messages
+var allowedRoles = []string{"admin", "developer", "viewer"}
+
+// fieldError is a validation failure for one field, as a message code and its arguments
+type fieldError struct {
+	Code string
+	Args []interface{}
+}
+
+// validateUserFields checks the fields being set on a user (nil = not being
+// set) and returns an error per invalid field. An empty role is allowed and
+// reported as a warning instead (see userWarnings).
+func validateUserFields(name, email, role *string) map[string]fieldError {
+	fields := make(map[string]fieldError)
+	if name != nil && *name == "" {
+		fields["name"] = fieldError{Code: "name_required"}
+	}
+	if email != nil {
+		if *email == "" {
+			fields["email"] = fieldError{Code: "email_required"}
+		} else if addr, err := mail.ParseAddress(*email); err != nil || addr.Address != *email {
+			fields["email"] = fieldError{Code: "email_invalid", Args: []interface{}{*email}}
+		}
+	}
+	if role != nil && *role != "" {
+		if _, ok := rolePrecedence[*role]; !ok {
+			fields["role"] = fieldError{Code: "role_invalid", Args: []interface{}{*role, strings.Join(allowedRoles, ", ")}}
+		}
+	}
+	return fields
+}
+
+// mergeFieldErrors adds the errors in extra for fields that don't already
+// have one in fields, and returns fields
+func mergeFieldErrors(fields, extra map[string]fieldError) map[string]fieldError {
+	for name, err := range extra {
+		if _, ok := fields[name]; !ok {
+			fields[name] = err
+		}
+	}
+	return fields
+}
+
+// writeFieldErrors writes a 400 listing a localized message per invalid field
+func writeFieldErrors(w http.ResponseWriter, r *http.Request, fields map[string]fieldError) {
+	lang := requestLanguage(r)
+	w.Header().Set("Content-Language", lang.String())
+	writeErrorResponse(w, r, http.StatusBadRequest, fieldErrorsResponse(lang, fields))
+}
+
+// fieldErrorsResponse builds the error for invalid fields, with a summary in
+// Error for clients that only read that
+func fieldErrorsResponse(lang language.Tag, fields map[string]fieldError) ErrorResponse {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	messages := make(map[string]string, len(fields))
+	summary := make([]string, len(names))
+	details := make([]ErrorDetail, len(names))
+	for i, name := range names {
+		messages[name] = localize(lang, fields[name].Code, fields[name].Args...)
+		summary[i] = messages[name]
+		details[i] = ErrorDetail{Field: name, Code: fields[name].Code, Message: messages[name]}
+	}
+
+	return ErrorResponse{
+		Code:    "validation_failed",
+		Error:   strings.Join(summary, "; "),
+		Fields:  messages,
+		Details: details,
+	}
+}
+
+// writeEmailTaken writes the 409 returned when an email belongs to another user
+func writeEmailTaken(w http.ResponseWriter, r *http.Request, email string) {
+	lang := requestLanguage(r)
+	w.Header().Set("Content-Language", lang.String())
+	writeErrorResponse(w, r, http.StatusConflict, emailTakenError(lang, email))
+}
+
+// emailTakenError builds the error for an email that belongs to another user
+func emailTakenError(lang language.Tag, email string) ErrorResponse {
+	message := localize(lang, "email_in_use")
+	return ErrorResponse{
+		Code:    "email_taken",
+		Error:   localize(lang, "email_taken", email),
+		Fields:  map[string]string{"email": message},
+		Details: []ErrorDetail{{Field: "email", Code: "email_in_use", Message: message}},
+	}
+}
+
+// emailTaken reports whether a user in list (other than excludeID) already
+// has email, ignoring case
+func emailTaken(list []User, email, excludeID string) bool {
+	for _, user := range list {
+		if user.ID != excludeID && strings.EqualFold(user.Email, email) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestValidateUserID(t *testing.T) {
+	for _, id := range []string{"user-001", "abc_DEF-9", strings.Repeat("a", maxUserIDLength)} {
+		if err := validateUserID(id); err != nil {
+			t.Errorf("validateUserID(%q) = %v, want nil", id, err)
+		}
+	}
+	for _, id := range []string{"", "a/b", "a b", "a\nb", "ü", "count", strings.Repeat("a", maxUserIDLength+1)} {
+		if err := validateUserID(id); err == nil {
+			t.Errorf("validateUserID(%q) = nil, want an error", id)
+		}
+	}
+}
+
+func TestUserIDPatternConfigurable(t *testing.T) {
+	setForTest(t, &userIDPattern, compileUserIDPattern(`^user-[0-9]+$`))
+	if err := validateUserID("user-42"); err != nil {
+		t.Errorf("user-42: %v", err)
+	}
+	if err := validateUserID("alice"); err == nil {
+		t.Error("alice accepted by ^user-[0-9]+$")
+	}
+
+	// An invalid pattern falls back to the default rather than failing open
+	if re := compileUserIDPattern(`(`); re.String() != defaultUserIDPattern {
+		t.Errorf("invalid pattern compiled to %s, want the default", re)
+	}
+}
+
+func TestInvalidUserIDsRejected(t *testing.T) {
+	useTestStore(t)
+	server := newTestServer(t)
+
+	resp, body := doRequest(t, server, http.MethodGet, "/users/bad%0Aid", "")
+	if resp.StatusCode != http.StatusBadRequest || !strings.Contains(body, `"code":"user_id_invalid"`) {
+		t.Fatalf("GET: status = %d, body %s; want 400 user_id_invalid", resp.StatusCode, body)
+	}
+	// The newline comes back escaped, as it's logged
+	if !strings.Contains(body, `bad\\nid`) {
+		t.Errorf("GET: the ID isn't escaped in the error: %s", body)
+	}
+
+	resp, body = doRequest(t, server, http.MethodPost, "/users",
+		`{"id":"a/b","name":"Dave","email":"dave@example.com"}`, "Content-Type", "application/json")
+	if resp.StatusCode != http.StatusBadRequest || !strings.Contains(body, `"code":"user_id_invalid"`) {
+		t.Fatalf("POST: status = %d, body %s; want 400 user_id_invalid", resp.StatusCode, body)
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// createUserForTest POSTs body to /users and decodes the response
+func createUserForTest(t *testing.T, body string, wantStatus int) UsersResponse {
+	t.Helper()
+	rec := serveHandler(usersHandler, http.MethodPost, "/users", body, "Content-Type", "application/json")
+	if rec.Code != wantStatus {
+		t.Fatalf("POST /users %s: status = %d, want %d: %s", body, rec.Code, wantStatus, rec.Body)
+	}
+	var resp UsersResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func TestCreateUserWarnings(t *testing.T) {
+	useTestStore(t)
+	setForTest(t, &enabledWarningRules, parseWarningRules("all"))
+
+	resp := createUserForTest(t, `{"name":" dave@example.com","email":"admin@example.com"}`, http.StatusCreated)
+	got := strings.Join(resp.Warnings, "\n")
+	for _, want := range []string{"role mailbox", "role is empty", "looks like an email address", "whitespace"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("warnings don't mention %q:\n%s", want, got)
+		}
+	}
+
+	resp = createUserForTest(t, `{"name":"Erin","email":"erin@example.com","role":"viewer"}`, http.StatusCreated)
+	if len(resp.Warnings) != 0 {
+		t.Errorf("warnings for an unremarkable user: %v", resp.Warnings)
+	}
+}
+
+func TestCreateUserWarningsConfigurable(t *testing.T) {
+	useTestStore(t)
+
+	setForTest(t, &enabledWarningRules, parseWarningRules("missing_role, no_such_rule"))
+	resp := createUserForTest(t, `{"name":"Dave","email":"admin@example.com"}`, http.StatusCreated)
+	if len(resp.Warnings) != 1 || !strings.Contains(resp.Warnings[0], "role is empty") {
+		t.Errorf("warnings = %v, want only missing_role's", resp.Warnings)
+	}
+
+	setForTest(t, &enabledWarningRules, parseWarningRules("none"))
+	resp = createUserForTest(t, `{"name":"Frank","email":"root@example.com"}`, http.StatusCreated)
+	if len(resp.Warnings) != 0 {
+		t.Errorf("warnings = %v with VALIDATION_WARNINGS=none", resp.Warnings)
+	}
+}
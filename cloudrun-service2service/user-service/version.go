@@ -0,0 +1,44 @@
+package main
+
+import (
+	"net/http"
+	"runtime"
+)
+
+// Build information, set at build time with
+//
+//	go build -ldflags "-X main.version=1.4.0 -X main.commit=$(git rev-parse --short HEAD) -X main.buildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+//
+// (the Dockerfile passes them through from VERSION, COMMIT and BUILD_TIME
+// build args). Local builds report "dev".
+var (
+	version   = "dev"
+	commit    = "dev"
+	buildTime = "dev"
+)
+
+// VersionResponse represents the response for GET /version
+type VersionResponse struct {
+	Service   string `json:"service"`
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildTime string `json:"build_time"`
+	GoVersion string `json:"go_version"`
+}
+
+// versionHandler handles GET /version, so operators can confirm which build
+// a revision is running
+func versionHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		methodNotAllowed(w, r, http.MethodGet, http.MethodHead)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, VersionResponse{
+		Service:   "user-service (Go)",
+		Version:   version,
+		Commit:    commit,
+		BuildTime: buildTime,
+		GoVersion: runtime.Version(),
+	})
+}
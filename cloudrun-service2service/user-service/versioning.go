@@ -0,0 +1,227 @@
+package main
+
+import (
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Response versioning.
+// User responses default to the v1 shape (UsersResponse). Clients opt into
+// another shape with Accept: application/vnd.userservice.v2+json, which
+// wraps users in a {meta, data, pagination} envelope with page links.
+// Asking only for a version the service doesn't have gets 406; other Accept
+// values (including none) get v1, so existing clients are unaffected.
+const (
+	mediaTypeVendorPrefix = "application/vnd.userservice.v"
+	mediaTypeVendorSuffix = "+json"
+	mediaTypeUsersV2      = "application/vnd.userservice.v2+json"
+
+	latestResponseVersion = 2
+)
+
+// UsersResponseV2 is the v2 envelope for UsersResponse and UserListResponse
+type UsersResponseV2 struct {
+	Meta ResponseMeta `json:"meta"`
+	// Data is the user, or the page of users for lists
+	Data       interface{}            `json:"data,omitempty"`
+	Pagination *PaginationV2          `json:"pagination,omitempty"`
+	Changes    map[string]FieldChange `json:"changes,omitempty"`
+}
+
+// ResponseMeta describes a v2 response
+type ResponseMeta struct {
+	Service  string   `json:"service"`
+	Language string   `json:"language"`
+	Version  string   `json:"version"`
+	Count    *int     `json:"count,omitempty"`
+	Message  string   `json:"message,omitempty"`
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// PaginationV2 is Pagination with links to the neighbouring pages
+type PaginationV2 struct {
+	Pagination
+	Links PageLinks `json:"links"`
+}
+
+// PageLinks are the URLs of a list's current, next and previous pages
+type PageLinks struct {
+	Self string `json:"self"`
+	Next string `json:"next,omitempty"`
+	Prev string `json:"prev,omitempty"`
+}
+
+// versionedWriter carries a request's negotiated response version to writeJSON
+type versionedWriter struct {
+	http.ResponseWriter
+	version int
+	request *http.Request
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (vw *versionedWriter) Unwrap() http.ResponseWriter {
+	return vw.ResponseWriter
+}
+
+// Flush passes flushes through so NDJSON listings stay streamed
+func (vw *versionedWriter) Flush() {
+	if flusher, ok := vw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// parseResponseVersion picks the response version for an Accept header: the
+// most preferred of the vendor types it lists, or 1 for application/json,
+// wildcards and anything else. ok is false if the only vendor types
+// acceptable are versions that don't exist.
+func parseResponseVersion(accept string) (version int, ok bool) {
+	version, bestQ := 0, -1.0
+	unknown := false
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if raw, set := params["q"]; set {
+			if q, err = strconv.ParseFloat(raw, 64); err != nil || q <= 0 {
+				continue
+			}
+		}
+
+		candidate := 1
+		if rest, isVendor := strings.CutPrefix(mediaType, mediaTypeVendorPrefix); isVendor {
+			n, err := strconv.Atoi(strings.TrimSuffix(rest, mediaTypeVendorSuffix))
+			if err != nil || !strings.HasSuffix(rest, mediaTypeVendorSuffix) || n < 1 || n > latestResponseVersion {
+				unknown = true
+				continue
+			}
+			candidate = n
+		}
+		if q > bestQ {
+			version, bestQ = candidate, q
+		}
+	}
+	if version == 0 {
+		return 1, !unknown
+	}
+	return version, true
+}
+
+// negotiateResponseVersion reads the Accept header, rejecting unknown
+// versions with 406 and passing the version on to writeJSON
+func negotiateResponseVersion(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept")
+		version, ok := parseResponseVersion(r.Header.Get("Accept"))
+		if !ok {
+			writeError(w, r, http.StatusNotAcceptable, "unsupported_response_version", r.Header.Get("Accept"), mediaTypeUsersV2)
+			return
+		}
+		if version == 1 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(&versionedWriter{ResponseWriter: w, version: version, request: r}, r)
+	})
+}
+
+// findResponseWriter finds the writer of type T among w and the writers it
+// wraps, or returns T's zero value
+func findResponseWriter[T http.ResponseWriter](w http.ResponseWriter) T {
+	for {
+		if t, ok := w.(T); ok {
+			return t
+		}
+		unwrapper, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			var zero T
+			return zero
+		}
+		w = unwrapper.Unwrap()
+	}
+}
+
+// versionedBody converts data to the shape, timezone and fields negotiated
+// for the request w responds to, returning it with its Content-Type. Only user
+// responses have versions; anything else is returned as is.
+func versionedBody(w http.ResponseWriter, data interface{}) (interface{}, string) {
+	data = projectedBody(w, zonedBody(w, data))
+	vw := findResponseWriter[*versionedWriter](w)
+	if vw == nil || vw.version != 2 {
+		return data, "application/json"
+	}
+	switch resp := data.(type) {
+	case UsersResponse:
+		return usersResponseV2(vw.request, resp), mediaTypeUsersV2
+	case UserListResponse:
+		return usersResponseV2(vw.request, UsersResponse{
+			Service:    resp.Service,
+			Count:      resp.Count,
+			Users:      resp.Users,
+			Pagination: resp.Pagination,
+		}), mediaTypeUsersV2
+	default:
+		return data, "application/json"
+	}
+}
+
+// usersResponseV2 builds the v2 envelope for a v1 response to r
+func usersResponseV2(r *http.Request, resp UsersResponse) UsersResponseV2 {
+	v2 := UsersResponseV2{
+		Meta: ResponseMeta{
+			Service:  "user-service",
+			Language: "Go",
+			Version:  "v2",
+			Message:  resp.Message,
+			Warnings: resp.Warnings,
+		},
+		Changes: resp.Changes,
+	}
+	switch {
+	case resp.User != nil:
+		v2.Data = resp.User
+	case resp.Users != nil || resp.Pagination != nil:
+		users := resp.Users
+		if users == nil {
+			users = []User{}
+		}
+		count := len(users)
+		v2.Meta.Count = &count
+		v2.Data = users
+	}
+	if resp.Pagination != nil {
+		v2.Pagination = &PaginationV2{Pagination: *resp.Pagination, Links: pageLinks(r, *resp.Pagination)}
+	}
+	return v2
+}
+
+// pageLinks returns the URLs of a list page and its neighbours, keeping the
+// request's other query parameters. Cursor pages link forward only.
+func pageLinks(r *http.Request, p Pagination) PageLinks {
+	if r.URL.Query().Get("cursor") != "" {
+		links := PageLinks{Self: selfURL(r.URL.Path) + "?" + r.URL.RawQuery}
+		if p.NextCursor != "" {
+			query := r.URL.Query()
+			query.Set("cursor", p.NextCursor)
+			links.Next = selfURL(r.URL.Path) + "?" + query.Encode()
+		}
+		return links
+	}
+	link := func(offset int) string {
+		query := r.URL.Query()
+		query.Set("limit", strconv.Itoa(p.Limit))
+		query.Set("offset", strconv.Itoa(offset))
+		return selfURL(r.URL.Path) + "?" + query.Encode()
+	}
+	links := PageLinks{Self: link(p.Offset)}
+	if p.NextOffset != nil {
+		links.Next = link(*p.NextOffset)
+	}
+	if p.Offset > 0 {
+		links.Prev = link(max(p.Offset-p.Limit, 0))
+	}
+	return links
+}